@@ -257,6 +257,44 @@ func TestAsyncProducer(t *testing.T) {
 	})
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...Option) {
+		broker := newMockBroker(t)
+		defer broker.Close()
+
+		cfg := sarama.NewConfig()
+		cfg.Producer.Return.Successes = true
+
+		producer, err := sarama.NewSyncProducer([]string{broker.Addr()}, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		producer = WrapSyncProducer(cfg, producer, opts...)
+		producer.SendMessage(&sarama.ProducerMessage{
+			Topic: "my_topic",
+			Value: sarama.StringEncoder("test 1"),
+		})
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, WithAnalyticsRate(0.23))
+	})
+}
+
 func newMockBroker(t *testing.T) *sarama.MockBroker {
 	broker := sarama.NewMockBroker(t, 1)
 
@@ -2,6 +2,8 @@ package aws
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -75,3 +77,28 @@ func TestAWS(t *testing.T) {
 		assert.Equal(t, "http://ec2.us-west-2.amazonaws.com/", s.Tag(ext.HTTPURL))
 	})
 }
+
+func TestAnalyticsSettings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cfg := aws.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint(server.URL).
+		WithCredentials(credentials.AnonymousCredentials)
+
+	assertRate := func(t *testing.T, rate interface{}, opts ...Option) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		sess := WrapSession(session.Must(session.NewSession(cfg)), opts...)
+		ec2.New(sess).DescribeInstances(&ec2.DescribeInstancesInput{})
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) { assertRate(t, nil) })
+	t.Run("rate", func(t *testing.T) { assertRate(t, 0.23, WithAnalyticsRate(0.23)) })
+}
@@ -1,7 +1,10 @@
 package aws
 
+import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
 type config struct {
 	serviceName string
+	analytics   analytics.Config
 }
 
 // Option represents an option that can be passed to Dial.
@@ -15,3 +18,18 @@ func WithServiceName(name string) Option {
 		cfg.serviceName = name
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) Option {
+	return func(cfg *config) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) Option {
+	return func(cfg *config) {
+		cfg.analytics.SetRate(rate)
+	}
+}
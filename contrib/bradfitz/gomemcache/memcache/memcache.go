@@ -8,6 +8,7 @@ package memcache // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/bradfitz/gom
 
 import (
 	"context"
+	"math"
 
 	"github.com/bradfitz/gomemcache/memcache"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
@@ -56,10 +57,15 @@ func (c *Client) WithContext(ctx context.Context) *Client {
 
 // startSpan starts a span from the context set with WithContext.
 func (c *Client) startSpan(resourceName string) ddtrace.Span {
-	span, _ := tracer.StartSpanFromContext(c.context, operationName,
+	opts := []ddtrace.StartSpanOption{
 		tracer.SpanType(ext.SpanTypeMemcached),
 		tracer.ServiceName(c.cfg.serviceName),
-		tracer.ResourceName(resourceName))
+		tracer.ResourceName(resourceName),
+	}
+	if rate := c.cfg.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
+	span, _ := tracer.StartSpanFromContext(c.context, operationName, opts...)
 	return span
 }
 
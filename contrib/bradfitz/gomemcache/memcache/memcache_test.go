@@ -86,6 +86,27 @@ func testMemcache(t *testing.T, addr string) {
 	})
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	li := makeFakeServer(t)
+	defer li.Close()
+
+	assertRate := func(t *testing.T, rate interface{}, opts ...ClientOption) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		client := WrapClient(memcache.New(li.Addr().String()), opts...)
+		err := client.Add(&memcache.Item{Key: "key", Value: []byte("value")})
+		assert.Nil(t, err)
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) { assertRate(t, nil) })
+	t.Run("rate", func(t *testing.T) { assertRate(t, 0.23, WithAnalyticsRate(0.23)) })
+}
+
 func TestFakeServer(t *testing.T) {
 	li := makeFakeServer(t)
 	defer li.Close()
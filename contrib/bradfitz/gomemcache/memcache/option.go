@@ -1,11 +1,16 @@
 package memcache
 
+import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
 const (
 	serviceName   = "memcached"
 	operationName = "memcached.query"
 )
 
-type clientConfig struct{ serviceName string }
+type clientConfig struct {
+	serviceName string
+	analytics   analytics.Config
+}
 
 // ClientOption represents an option that can be passed to Dial.
 type ClientOption func(*clientConfig)
@@ -20,3 +25,18 @@ func WithServiceName(name string) ClientOption {
 		cfg.serviceName = name
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
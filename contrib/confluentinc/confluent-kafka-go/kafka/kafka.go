@@ -2,6 +2,8 @@
 package kafka // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/confluentinc/confluent-kafka-go/kafka"
 
 import (
+	"math"
+
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
@@ -86,6 +88,9 @@ func (c *Consumer) startSpan(msg *kafka.Message) ddtrace.Span {
 		tracer.Tag("partition", msg.TopicPartition.Partition),
 		tracer.Tag("offset", msg.TopicPartition.Offset),
 	}
+	if rate := c.cfg.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
 	// kafka supports headers, so try to extract a span context
 	carrier := NewMessageCarrier(msg)
 	if spanctx, err := tracer.Extract(carrier); err == nil {
@@ -172,6 +177,9 @@ func (p *Producer) startSpan(msg *kafka.Message) ddtrace.Span {
 		tracer.SpanType(ext.SpanTypeMessageProducer),
 		tracer.Tag("partition", msg.TopicPartition.Partition),
 	}
+	if rate := p.cfg.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
 	carrier := NewMessageCarrier(msg)
 	span, _ := tracer.StartSpanFromContext(p.cfg.ctx, "kafka.produce", opts...)
 	// inject the span context so consumers can pick it up
@@ -76,6 +76,39 @@ func TestConsumerChannel(t *testing.T) {
 	}
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, rate interface{}, opts ...Option) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		c, err := NewConsumer(&kafka.ConfigMap{
+			"go.events.channel.enable": true,
+			"group.id":                 testGroupID,
+			"socket.timeout.ms":        10,
+			"session.timeout.ms":       10,
+			"enable.auto.offset.store": false,
+		}, opts...)
+		assert.NoError(t, err)
+
+		go func() {
+			c.Consumer.Events() <- &kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &testTopic, Partition: 1, Offset: 1},
+			}
+		}()
+		<-c.Events()
+		c.Close()
+		// wait for the events channel to be closed
+		<-c.Events()
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) { assertRate(t, nil) })
+	t.Run("rate", func(t *testing.T) { assertRate(t, 0.23, WithAnalyticsRate(0.23)) })
+}
+
 /*
 to run the integration test locally:
 
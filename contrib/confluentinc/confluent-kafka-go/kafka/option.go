@@ -1,10 +1,15 @@
 package kafka
 
-import "context"
+import (
+	"context"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+)
 
 type config struct {
 	serviceName string
 	ctx         context.Context
+	analytics   analytics.Config
 }
 
 // An Option customizes the config.
@@ -34,3 +39,18 @@ func WithServiceName(serviceName string) Option {
 		cfg.serviceName = serviceName
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) Option {
+	return func(cfg *config) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) Option {
+	return func(cfg *config) {
+		cfg.analytics.SetRate(rate)
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"math"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/obfuscate"
 )
 
 var _ driver.Driver = (*tracedDriver)(nil)
@@ -39,6 +42,7 @@ func (d *tracedDriver) Open(dsn string) (c driver.Conn, err error) {
 	tp := &traceParams{
 		driverName: d.driverName,
 		config:     d.config,
+		dialect:    sqlDialect(d.driverName),
 		meta:       meta,
 	}
 	return &tracedConn{conn, tp}, err
@@ -49,9 +53,23 @@ type traceParams struct {
 	config     *registerConfig
 	driverName string
 	resource   string
+	dialect    obfuscate.Dialect
 	meta       map[string]string
 }
 
+// sqlDialect returns the SQL dialect used to normalize the queries sent using
+// the driver registered with the given name.
+func sqlDialect(driverName string) obfuscate.Dialect {
+	switch driverName {
+	case "mysql":
+		return obfuscate.DialectMySQL
+	case "postgres", "pgx":
+		return obfuscate.DialectPostgres
+	default:
+		return obfuscate.DialectDefault
+	}
+}
+
 // tryTrace will create a span using the given arguments, but will act as a no-op when err is driver.ErrSkip.
 func (tp *traceParams) tryTrace(ctx context.Context, resource string, query string, startTime time.Time, err error) {
 	if err == driver.ErrSkip {
@@ -62,13 +80,24 @@ func (tp *traceParams) tryTrace(ctx context.Context, resource string, query stri
 		return
 	}
 	name := fmt.Sprintf("%s.query", tp.driverName)
-	span, _ := tracer.StartSpanFromContext(ctx, name,
+	opts := []ddtrace.StartSpanOption{
 		tracer.SpanType(ext.SpanTypeSQL),
 		tracer.ServiceName(tp.config.serviceName),
 		tracer.StartTime(startTime),
-	)
+	}
+	if rate := tp.config.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
+	span, _ := tracer.StartSpanFromContext(ctx, name, opts...)
 	if query != "" {
-		resource = query
+		if q := obfuscate.NormalizeSQL(query, tp.dialect); q != "" {
+			// queries only made of comments are normalized to nothing, in
+			// which case the operation is kept as the resource
+			resource = q
+		}
+		if tp.config.rawQuery {
+			span.SetTag(ext.SQLQuery, query)
+		}
 	}
 	span.SetTag(ext.ResourceName, resource)
 	for k, v := range tp.meta {
@@ -1,6 +1,12 @@
 package sql
 
-type registerConfig struct{ serviceName string }
+import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
+type registerConfig struct {
+	serviceName string
+	rawQuery    bool
+	analytics   analytics.Config
+}
 
 // RegisterOption represents an option that can be passed to Register.
 type RegisterOption func(*registerConfig)
@@ -15,3 +21,28 @@ func WithServiceName(name string) RegisterOption {
 		cfg.serviceName = name
 	}
 }
+
+// WithRawQuery sets the raw query, including its literals, as the "sql.query"
+// tag of the spans of the registered driver. The resource of these spans is the
+// normalized query regardless, so this option should only be used when the
+// queries do not hold sensitive values.
+func WithRawQuery() RegisterOption {
+	return func(cfg *registerConfig) {
+		cfg.rawQuery = true
+	}
+}
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) RegisterOption {
+	return func(cfg *registerConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) RegisterOption {
+	return func(cfg *registerConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
@@ -9,6 +9,10 @@
 //
 // The rest of our application would continue as usual, but with tracing enabled.
 //
+// The resource of the spans is the normalized query: its literals are replaced by "?" and its
+// comments are removed, so that queries which only differ by their values are grouped together
+// and sensitive values are not sent. Use WithRawQuery to also record the raw query.
+//
 package sql
 
 import (
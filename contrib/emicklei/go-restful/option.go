@@ -0,0 +1,25 @@
+package restful
+
+import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
+type config struct {
+	analytics analytics.Config
+}
+
+// Option represents an option that can be passed to FilterFunc.
+type Option func(*config)
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) Option {
+	return func(cfg *config) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) Option {
+	return func(cfg *config) {
+		cfg.analytics.SetRate(rate)
+	}
+}
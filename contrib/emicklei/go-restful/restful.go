@@ -2,6 +2,7 @@
 package restful
 
 import (
+	"math"
 	"strconv"
 
 	"github.com/emicklei/go-restful"
@@ -10,14 +11,33 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
 
+// FilterFunc returns a restful.FilterFunction which will automatically trace incoming request.
+func FilterFunc(opts ...Option) restful.FilterFunction {
+	cfg := new(config)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		filter(cfg, req, resp, chain)
+	}
+}
+
 // Filter is a filter that will trace incoming request
 func Filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	cfg := new(config)
+	filter(cfg, req, resp, chain)
+}
+
+func filter(cfg *config, req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
 	opts := []ddtrace.StartSpanOption{
 		tracer.ResourceName(req.SelectedRoutePath()),
 		tracer.SpanType(ext.SpanTypeWeb),
 		tracer.Tag(ext.HTTPMethod, req.Request.Method),
 		tracer.Tag(ext.HTTPURL, req.Request.URL.Path),
 	}
+	if rate := cfg.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
 	if spanctx, err := tracer.Extract(tracer.HTTPHeadersCarrier(req.Request.Header)); err == nil {
 		opts = append(opts, tracer.ChildOf(spanctx))
 	}
@@ -103,3 +103,25 @@ func TestPropagation(t *testing.T) {
 
 	container.ServeHTTP(w, r)
 }
+
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, rate interface{}, opts ...Option) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		ws := new(restful.WebService)
+		ws.Filter(FilterFunc(opts...))
+		ws.Route(ws.GET("/user/{id}").To(func(*restful.Request, *restful.Response) {}))
+
+		container := restful.NewContainer()
+		container.Add(ws)
+		container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/user/123", nil))
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) { assertRate(t, nil) })
+	t.Run("rate", func(t *testing.T) { assertRate(t, 0.23, WithAnalyticsRate(0.23)) })
+}
@@ -1,6 +1,11 @@
 package redigo // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/garyburd/redigo"
 
-type dialConfig struct{ serviceName string }
+import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
+type dialConfig struct {
+	serviceName string
+	analytics   analytics.Config
+}
 
 // DialOption represents an option that can be passed to Dial.
 type DialOption func(*dialConfig)
@@ -15,3 +20,18 @@ func WithServiceName(name string) DialOption {
 		cfg.serviceName = name
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) DialOption {
+	return func(cfg *dialConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) DialOption {
+	return func(cfg *dialConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
@@ -46,6 +46,24 @@ func TestClient(t *testing.T) {
 	assert.Equal("2", span.Tag("redis.args_length"))
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, rate interface{}, opts ...interface{}) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		c, err := Dial("tcp", "127.0.0.1:6379", opts...)
+		assert.Nil(t, err)
+		c.Do("SET", 1, "truck")
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) { assertRate(t, nil) })
+	t.Run("rate", func(t *testing.T) { assertRate(t, 0.23, WithAnalyticsRate(0.23)) })
+}
+
 func TestCommandError(t *testing.T) {
 	assert := assert.New(t)
 	mt := mocktracer.Start()
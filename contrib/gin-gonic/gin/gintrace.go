@@ -3,6 +3,7 @@ package gin // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/gin-gonic/gin"
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
@@ -13,8 +14,11 @@ import (
 )
 
 // Middleware returns middleware that will trace incoming requests.
-// The last parameter is optional and can be used to pass a custom tracer.
-func Middleware(service string) gin.HandlerFunc {
+func Middleware(service string, opts ...Option) gin.HandlerFunc {
+	cfg := new(config)
+	for _, fn := range opts {
+		fn(cfg)
+	}
 	return func(c *gin.Context) {
 		resource := c.HandlerName()
 		opts := []ddtrace.StartSpanOption{
@@ -24,6 +28,9 @@ func Middleware(service string) gin.HandlerFunc {
 			tracer.Tag(ext.HTTPMethod, c.Request.Method),
 			tracer.Tag(ext.HTTPURL, c.Request.URL.Path),
 		}
+		if rate := cfg.analytics.Rate(); !math.IsNaN(rate) {
+			opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+		}
 		if spanctx, err := tracer.Extract(tracer.HTTPHeadersCarrier(c.Request.Header)); err == nil {
 			opts = append(opts, tracer.ChildOf(spanctx))
 		}
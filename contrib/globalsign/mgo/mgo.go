@@ -2,6 +2,7 @@
 package mgo // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/globalsign/mgo"
 
 import (
+	"math"
 	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
@@ -37,12 +38,15 @@ type Session struct {
 }
 
 func newChildSpanFromContext(config mongoConfig) ddtrace.Span {
-	span, _ := tracer.StartSpanFromContext(
-		config.ctx,
-		"mongodb.query",
+	opts := []ddtrace.StartSpanOption{
 		tracer.SpanType(ext.SpanTypeMongoDB),
 		tracer.ServiceName(config.serviceName),
-		tracer.ResourceName("mongodb.query"))
+		tracer.ResourceName("mongodb.query"),
+	}
+	if rate := config.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
+	span, _ := tracer.StartSpanFromContext(config.ctx, "mongodb.query", opts...)
 
 	for key, value := range config.tags {
 		span.SetTag(key, value)
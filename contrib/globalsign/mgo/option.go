@@ -1,11 +1,16 @@
 package mgo
 
-import "context"
+import (
+	"context"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+)
 
 type mongoConfig struct {
 	ctx         context.Context
 	serviceName string
 	tags        map[string]string
+	analytics   analytics.Config
 }
 
 func defaults(cfg *mongoConfig) {
@@ -30,3 +35,18 @@ func WithContext(ctx context.Context) DialOption {
 		cfg.ctx = ctx
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) DialOption {
+	return func(cfg *mongoConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) DialOption {
+	return func(cfg *mongoConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
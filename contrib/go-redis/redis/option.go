@@ -1,6 +1,11 @@
 package redis // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/go-redis/redis"
 
-type clientConfig struct{ serviceName string }
+import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
+type clientConfig struct {
+	serviceName string
+	analytics   analytics.Config
+}
 
 // ClientOption represents an option that can be used to create or wrap a client.
 type ClientOption func(*clientConfig)
@@ -15,3 +20,18 @@ func WithServiceName(name string) ClientOption {
 		cfg.serviceName = name
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
@@ -4,6 +4,7 @@ package redis
 import (
 	"bytes"
 	"context"
+	"math"
 	"net"
 	"strconv"
 	"strings"
@@ -87,14 +88,18 @@ func (c *Pipeliner) Exec() ([]redis.Cmder, error) {
 
 func (c *Pipeliner) execWithContext(ctx context.Context) ([]redis.Cmder, error) {
 	p := c.params
-	span, _ := tracer.StartSpanFromContext(ctx, "redis.command",
+	spanopts := []ddtrace.StartSpanOption{
 		tracer.SpanType(ext.SpanTypeRedis),
 		tracer.ServiceName(p.config.serviceName),
 		tracer.ResourceName("redis"),
 		tracer.Tag(ext.TargetHost, p.host),
 		tracer.Tag(ext.TargetPort, p.port),
 		tracer.Tag("out.db", p.db),
-	)
+	}
+	if rate := p.config.analytics.Rate(); !math.IsNaN(rate) {
+		spanopts = append(spanopts, tracer.Tag(ext.EventSampleRate, rate))
+	}
+	span, _ := tracer.StartSpanFromContext(ctx, "redis.command", spanopts...)
 	cmds, err := c.Pipeliner.Exec()
 	span.SetTag(ext.ResourceName, commandsToString(cmds))
 	span.SetTag("redis.pipeline_length", strconv.Itoa(len(cmds)))
@@ -134,7 +139,7 @@ func createWrapperFromClient(tc *Client) func(oldProcess func(cmd redis.Cmder) e
 			parts := strings.Split(raw, " ")
 			length := len(parts) - 1
 			p := tc.params
-			span, _ := tracer.StartSpanFromContext(ctx, "redis.command",
+			spanopts := []ddtrace.StartSpanOption{
 				tracer.SpanType(ext.SpanTypeRedis),
 				tracer.ServiceName(p.config.serviceName),
 				tracer.ResourceName(parts[0]),
@@ -143,7 +148,11 @@ func createWrapperFromClient(tc *Client) func(oldProcess func(cmd redis.Cmder) e
 				tracer.Tag("out.db", p.db),
 				tracer.Tag("redis.raw_command", raw),
 				tracer.Tag("redis.args_length", strconv.Itoa(length)),
-			)
+			}
+			if rate := p.config.analytics.Rate(); !math.IsNaN(rate) {
+				spanopts = append(spanopts, tracer.Tag(ext.EventSampleRate, rate))
+			}
+			span, _ := tracer.StartSpanFromContext(ctx, "redis.command", spanopts...)
 			err := oldProcess(cmd)
 			var opts []ddtrace.FinishOption
 			if err != redis.Nil {
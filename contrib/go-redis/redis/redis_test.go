@@ -194,3 +194,33 @@ func TestError(t *testing.T) {
 		assert.Equal("get non_existent_key: ", span.Tag("redis.raw_command"))
 	})
 }
+
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...ClientOption) {
+		client := NewClient(&redis.Options{Addr: "127.0.0.1:6379"}, opts...)
+		client.Set("test_key", "test_value", 0)
+		pipeline := client.Pipeline()
+		pipeline.Expire("pipeline_counter", time.Hour)
+		pipeline.Exec()
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 2)
+		for _, s := range spans {
+			assert.Equal(t, rate, s.Tag(ext.EventSampleRate))
+		}
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, WithAnalyticsRate(0.23))
+	})
+}
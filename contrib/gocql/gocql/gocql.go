@@ -4,12 +4,14 @@ package gocql // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/gocql/gocql"
 import (
 	"context"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/obfuscate"
 
 	"github.com/gocql/gocql"
 )
@@ -32,6 +34,7 @@ type params struct {
 	config    *queryConfig
 	keyspace  string
 	paginated bool
+	query     string // raw query, set when WithRawQuery is used
 }
 
 // WrapQuery wraps a gocql.Query into a traced Query under the given service name.
@@ -49,20 +52,30 @@ func WrapQuery(q *gocql.Query, opts ...WrapOption) *Query {
 	for _, fn := range opts {
 		fn(cfg)
 	}
-	if cfg.resourceName == "" {
-		q := `"` + strings.SplitN(q.String(), "\"", 3)[1] + `"`
-		q, err := strconv.Unquote(q)
-		if err != nil {
+	p := &params{config: cfg}
+	if cfg.resourceName == "" || cfg.rawQuery {
+		stmt, err := statement(q)
+		if cfg.resourceName == "" && err == nil {
+			cfg.resourceName = obfuscate.NormalizeSQL(stmt, obfuscate.DialectCQL)
+		}
+		if cfg.resourceName == "" {
 			// avoid having an empty resource as it will cause the trace
-			// to be dropped.
-			q = "_"
+			// to be dropped, e.g. when the query only holds comments.
+			cfg.resourceName = "_"
+		}
+		if cfg.rawQuery {
+			p.query = stmt
 		}
-		cfg.resourceName = q
 	}
-	tq := &Query{q, &params{config: cfg}, context.Background()}
+	tq := &Query{q, p, context.Background()}
 	return tq
 }
 
+// statement returns the statement of the given query.
+func statement(q *gocql.Query) (string, error) {
+	return strconv.Unquote(`"` + strings.SplitN(q.String(), "\"", 3)[1] + `"`)
+}
+
 // WithContext adds the specified context to the traced Query structure.
 func (tq *Query) WithContext(ctx context.Context) *Query {
 	tq.traceContext = ctx
@@ -80,13 +93,20 @@ func (tq *Query) PageState(state []byte) *Query {
 // NewChildSpan creates a new span from the params and the context.
 func (tq *Query) newChildSpan(ctx context.Context) ddtrace.Span {
 	p := tq.params
-	span, _ := tracer.StartSpanFromContext(ctx, ext.CassandraQuery,
+	opts := []ddtrace.StartSpanOption{
 		tracer.SpanType(ext.SpanTypeCassandra),
 		tracer.ServiceName(p.config.serviceName),
 		tracer.ResourceName(p.config.resourceName),
 		tracer.Tag(ext.CassandraPaginated, fmt.Sprintf("%t", p.paginated)),
 		tracer.Tag(ext.CassandraKeyspace, p.keyspace),
-	)
+	}
+	if rate := p.config.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
+	span, _ := tracer.StartSpanFromContext(ctx, ext.CassandraQuery, opts...)
+	if p.query != "" {
+		span.SetTag(ext.CassandraQuery, p.query)
+	}
 	return span
 }
 
@@ -119,3 +119,55 @@ func TestChildWrapperSpan(t *testing.T) {
 		assert.Equal(childSpan.Tag(ext.CassandraCluster), "datacenter1")
 	}
 }
+
+func TestQueryNormalization(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	cluster := newCassandraCluster()
+	session, err := cluster.CreateSession()
+	assert.Nil(err)
+	query := "SELECT * FROM trace.person WHERE name = 'Cassandra' /* by name */"
+	WrapQuery(session.Query(query)).Iter().Close()
+	WrapQuery(session.Query(query), WithRawQuery()).Iter().Close()
+
+	spans := mt.FinishedSpans()
+	assert.Len(spans, 2)
+	for _, span := range spans {
+		assert.Equal("SELECT * FROM trace.person WHERE name = ?", span.Tag(ext.ResourceName))
+	}
+	assert.Nil(spans[0].Tag(ext.CassandraQuery))
+	assert.Equal(query, spans[1].Tag(ext.CassandraQuery))
+
+	// queries only made of comments are normalized to nothing
+	q := WrapQuery(session.Query("// only a comment"))
+	assert.Equal("_", q.config.resourceName)
+}
+
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...WrapOption) {
+		session, err := newCassandraCluster().CreateSession()
+		assert.Nil(t, err)
+		q := session.Query("SELECT * FROM trace.person WHERE name = 'Cassandra'")
+		WrapQuery(q, opts...).Iter().Close()
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, WithAnalyticsRate(0.23))
+	})
+}
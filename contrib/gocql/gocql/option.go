@@ -1,6 +1,12 @@
 package gocql
 
-type queryConfig struct{ serviceName, resourceName string }
+import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
+type queryConfig struct {
+	serviceName, resourceName string
+	rawQuery                  bool
+	analytics                 analytics.Config
+}
 
 // WrapOption represents an option that can be passed to WrapQuery.
 type WrapOption func(*queryConfig)
@@ -17,7 +23,8 @@ func WithServiceName(name string) WrapOption {
 }
 
 // WithResourceName sets a custom resource name to be used with the traced query.
-// By default, the query statement is extracted automatically. This method should
+// By default, the query statement is extracted automatically and normalized, replacing
+// its literals by "?". This method should
 // be used when a different resource name is desired or in performance critical
 // environments. The gocql library returns the query statement using an fmt.Sprintf
 // call, which can be costly when called repeatedly. Using WithResourceName will
@@ -27,3 +34,26 @@ func WithResourceName(name string) WrapOption {
 		cfg.resourceName = name
 	}
 }
+
+// WithRawQuery sets the raw query statement, including its literals, as the
+// "cassandra.query" tag of the spans of the returned query.
+func WithRawQuery() WrapOption {
+	return func(cfg *queryConfig) {
+		cfg.rawQuery = true
+	}
+}
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) WrapOption {
+	return func(cfg *queryConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) WrapOption {
+	return func(cfg *queryConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
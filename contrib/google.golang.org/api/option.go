@@ -1,11 +1,17 @@
 package api
 
-import "context"
+import (
+	"context"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+)
 
 type config struct {
 	serviceName string
 	ctx         context.Context
 	scopes      []string
+
+	analytics analytics.Config
 }
 
 func newConfig(options ...Option) *config {
@@ -43,3 +49,18 @@ func WithServiceName(serviceName string) Option {
 		cfg.serviceName = serviceName
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) Option {
+	return func(cfg *config) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) Option {
+	return func(cfg *config) {
+		cfg.analytics.SetRate(rate)
+	}
+}
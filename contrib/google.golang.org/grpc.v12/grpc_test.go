@@ -135,6 +135,40 @@ func TestPass(t *testing.T) {
 	assert.True(s.FinishTime().Sub(s.StartTime()) > 0)
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...InterceptorOption) {
+		rig, err := newRig(true, opts...)
+		if err != nil {
+			t.Fatalf("error setting up rig: %s", err)
+		}
+		defer rig.Close()
+
+		resp, err := rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		assert.Nil(t, err)
+		assert.Equal(t, resp.Message, "passed")
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 2)
+		for _, s := range spans {
+			assert.Equal(t, rate, s.Tag(ext.EventSampleRate))
+		}
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, WithAnalyticsRate(0.23))
+	})
+}
+
 // fixtureServer a dummy implemenation of our grpc fixtureServer.
 type fixtureServer struct{}
 
@@ -172,8 +206,10 @@ func (r *rig) Close() {
 	r.listener.Close()
 }
 
-func newRig(traceClient bool) (*rig, error) {
-	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(WithServiceName("grpc"))))
+func newRig(traceClient bool, interceptorOpts ...InterceptorOption) (*rig, error) {
+	interceptorOpts = append([]InterceptorOption{WithServiceName("grpc")}, interceptorOpts...)
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(interceptorOpts...)))
 
 	RegisterFixtureServer(server, new(fixtureServer))
 
@@ -187,7 +223,7 @@ func newRig(traceClient bool) (*rig, error) {
 
 	opts := []grpc.DialOption{grpc.WithInsecure()}
 	if traceClient {
-		opts = append(opts, grpc.WithUnaryInterceptor(UnaryClientInterceptor(WithServiceName("grpc"))))
+		opts = append(opts, grpc.WithUnaryInterceptor(UnaryClientInterceptor(interceptorOpts...)))
 	}
 	conn, err := grpc.Dial(li.Addr().String(), opts...)
 	if err != nil {
@@ -1,6 +1,11 @@
 package grpc
 
-type interceptorConfig struct{ serviceName string }
+import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
+type interceptorConfig struct {
+	serviceName string
+	analytics   analytics.Config
+}
 
 // InterceptorOption represents an option that can be passed to the grpc unary
 // client and server interceptors.
@@ -16,3 +21,18 @@ func WithServiceName(name string) InterceptorOption {
 		cfg.serviceName = name
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
@@ -22,7 +22,7 @@ type clientStream struct {
 
 func (cs *clientStream) RecvMsg(m interface{}) (err error) {
 	if cs.cfg.traceStreamMessages {
-		span, _ := startSpanFromContext(cs.Context(), cs.method, "grpc.message", cs.cfg.clientServiceName())
+		span, _ := startSpanFromContext(cs.Context(), cs.method, "grpc.message", cs.cfg.clientServiceName(), cs.cfg.analytics.Rate())
 		if p, ok := peer.FromContext(cs.Context()); ok {
 			setSpanTargetFromPeer(span, *p)
 		}
@@ -34,7 +34,7 @@ func (cs *clientStream) RecvMsg(m interface{}) (err error) {
 
 func (cs *clientStream) SendMsg(m interface{}) (err error) {
 	if cs.cfg.traceStreamMessages {
-		span, _ := startSpanFromContext(cs.Context(), cs.method, "grpc.message", cs.cfg.clientServiceName())
+		span, _ := startSpanFromContext(cs.Context(), cs.method, "grpc.message", cs.cfg.clientServiceName(), cs.cfg.analytics.Rate())
 		if p, ok := peer.FromContext(cs.Context()); ok {
 			setSpanTargetFromPeer(span, *p)
 		}
@@ -123,7 +123,7 @@ func doClientRequest(
 	handler func(ctx context.Context, opts []grpc.CallOption) error,
 ) (ddtrace.Span, error) {
 	// inject the trace id into the metadata
-	span, ctx := startSpanFromContext(ctx, method, "grpc.client", cfg.clientServiceName())
+	span, ctx := startSpanFromContext(ctx, method, "grpc.client", cfg.clientServiceName(), cfg.analytics.Rate())
 	ctx = injectSpanIntoContext(ctx)
 
 	// fill in the peer so we can add it to the tags
@@ -1,9 +1,12 @@
 package grpc
 
+import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
 type interceptorConfig struct {
 	serviceName                           string
 	traceStreamCalls, traceStreamMessages bool
 	noDebugStack                          bool
+	analytics                             analytics.Config
 }
 
 func (cfg *interceptorConfig) serverServiceName() string {
@@ -58,3 +61,18 @@ func NoDebugStack() InterceptorOption {
 		cfg.noDebugStack = true
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
@@ -27,7 +27,7 @@ func (ss *serverStream) Context() context.Context {
 
 func (ss *serverStream) RecvMsg(m interface{}) (err error) {
 	if ss.cfg.traceStreamMessages {
-		span, _ := startSpanFromContext(ss.ctx, ss.method, "grpc.message", ss.cfg.serverServiceName())
+		span, _ := startSpanFromContext(ss.ctx, ss.method, "grpc.message", ss.cfg.serverServiceName(), ss.cfg.analytics.Rate())
 		defer finishWithError(span, err, ss.cfg.noDebugStack)
 	}
 	err = ss.ServerStream.RecvMsg(m)
@@ -36,7 +36,7 @@ func (ss *serverStream) RecvMsg(m interface{}) (err error) {
 
 func (ss *serverStream) SendMsg(m interface{}) (err error) {
 	if ss.cfg.traceStreamMessages {
-		span, _ := startSpanFromContext(ss.ctx, ss.method, "grpc.message", ss.cfg.serverServiceName())
+		span, _ := startSpanFromContext(ss.ctx, ss.method, "grpc.message", ss.cfg.serverServiceName(), ss.cfg.analytics.Rate())
 		defer finishWithError(span, err, ss.cfg.noDebugStack)
 	}
 	err = ss.ServerStream.SendMsg(m)
@@ -59,7 +59,7 @@ func StreamServerInterceptor(opts ...InterceptorOption) grpc.StreamServerInterce
 		// if we've enabled call tracing, create a span
 		if cfg.traceStreamCalls {
 			var span ddtrace.Span
-			span, ctx = startSpanFromContext(ctx, info.FullMethod, "grpc.server", cfg.serviceName)
+			span, ctx = startSpanFromContext(ctx, info.FullMethod, "grpc.server", cfg.serviceName, cfg.analytics.Rate())
 			defer finishWithError(span, err, cfg.noDebugStack)
 		}
 
@@ -84,7 +84,7 @@ func UnaryServerInterceptor(opts ...InterceptorOption) grpc.UnaryServerIntercept
 		fn(cfg)
 	}
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		span, ctx := startSpanFromContext(ctx, info.FullMethod, "grpc.server", cfg.serverServiceName())
+		span, ctx := startSpanFromContext(ctx, info.FullMethod, "grpc.server", cfg.serverServiceName(), cfg.analytics.Rate())
 		resp, err := handler(ctx, req)
 		finishWithError(span, err, cfg.noDebugStack)
 		return resp, err
@@ -2,10 +2,12 @@
 package mux // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/gorilla/mux"
 
 import (
+	"math"
 	"net/http"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httputil"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
 	"github.com/gorilla/mux"
@@ -97,6 +99,9 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			spanopts = append(spanopts, tracer.Tag("mux.host", h))
 		}
 	}
+	if rate := r.config.analytics.Rate(); !math.IsNaN(rate) {
+		spanopts = append(spanopts, tracer.Tag(ext.EventSampleRate, rate))
+	}
 	spanopts = append(spanopts, r.config.spanOpts...)
 	resource := req.Method + " " + route
 	httputil.TraceAndServe(r.Router, w, req, r.config.serviceName, resource, spanopts...)
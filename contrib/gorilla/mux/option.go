@@ -1,9 +1,13 @@
 package mux
 
-import "gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+import (
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+)
 
 type routerConfig struct {
 	serviceName string
+	analytics   analytics.Config
 	spanOpts    []ddtrace.StartSpanOption // additional span options to be applied
 }
 
@@ -28,3 +32,18 @@ func WithSpanOptions(opts ...ddtrace.StartSpanOption) RouterOption {
 		cfg.spanOpts = opts
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
@@ -61,3 +61,42 @@ func Test(t *testing.T) {
 		assert.Equal(t, "graphql.request", s.Tag(ext.ResourceName))
 	}
 }
+
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...Option) {
+		schema := graphql.MustParseSchema(`
+			schema {
+				query: Query
+			}
+			type Query {
+				hello: String!
+			}
+		`, new(testResolver), graphql.Tracer(NewTracer(opts...)))
+		srv := httptest.NewServer(&relay.Handler{Schema: schema})
+		defer srv.Close()
+
+		http.Post(srv.URL, "application/json", strings.NewReader(`{
+			"query": "{ hello }"
+		}`))
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 2)
+		s := spans[1]
+		assert.Equal(t, "graphql.request", s.OperationName())
+		assert.Equal(t, rate, s.Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, WithAnalyticsRate(0.23))
+	})
+}
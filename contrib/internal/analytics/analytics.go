@@ -0,0 +1,51 @@
+// Package analytics holds the trace analytics setting shared by the integrations.
+package analytics // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+
+import (
+	"math"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+)
+
+// Config holds the trace analytics setting of an integration. Its zero value
+// uses the global setting of the tracer.
+type Config struct {
+	rate float64
+	set  bool
+}
+
+// Enable enables or disables trace analytics for the integration, regardless of
+// the global setting. It implements the WithAnalytics option of the integrations.
+func (c *Config) Enable(on bool) {
+	if on {
+		c.SetRate(1.0)
+	} else {
+		c.SetRate(math.NaN())
+	}
+}
+
+// SetRate sets the rate at which the spans of the integration are marked as APM
+// events, regardless of the global setting. Rates outside of [0, 1] disable trace
+// analytics. It implements the WithAnalyticsRate option of the integrations.
+func (c *Config) SetRate(rate float64) {
+	c.set = true
+	if rate >= 0.0 && rate <= 1.0 {
+		c.rate = rate
+	} else {
+		c.rate = math.NaN()
+	}
+}
+
+// Rate returns the rate at which spans are marked as APM events, or NaN if they
+// are not. It is the rate set for the integration, if any, and the global rate
+// of the tracer otherwise, so it should be read when each span is started.
+func (c *Config) Rate() float64 {
+	if c.set {
+		return c.rate
+	}
+	return globalconfig.AnalyticsRate()
+}
+
+// IsSet reports whether trace analytics were explicitly configured for the
+// integration, using Enable or SetRate.
+func (c *Config) IsSet() bool { return c.set }
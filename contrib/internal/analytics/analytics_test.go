@@ -0,0 +1,45 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		global float64
+		set    func(*Config)
+		rate   float64 // NaN when disabled
+	}{
+		{name: "defaults", global: math.NaN(), rate: math.NaN()},
+		{name: "global", global: 0.4, rate: 0.4},
+		{name: "enabled", global: math.NaN(), set: func(c *Config) { c.Enable(true) }, rate: 1},
+		{name: "disabled", global: 0.4, set: func(c *Config) { c.Enable(false) }, rate: math.NaN()},
+		{name: "override", global: 0.4, set: func(c *Config) { c.SetRate(0.23) }, rate: 0.23},
+		{name: "zero", global: 0.4, set: func(c *Config) { c.SetRate(0) }, rate: 0},
+		{name: "invalid", global: 0.4, set: func(c *Config) { c.SetRate(1.5) }, rate: math.NaN()},
+		{name: "nan", global: 0.4, set: func(c *Config) { c.SetRate(math.NaN()) }, rate: math.NaN()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			defer globalconfig.SetAnalyticsRate(globalconfig.AnalyticsRate())
+			var c Config
+			if tt.set != nil {
+				tt.set(&c)
+			}
+			// the global rate is read when spans start, after configuration
+			globalconfig.SetAnalyticsRate(tt.global)
+			assert.Equal(tt.set != nil, c.IsSet())
+			if math.IsNaN(tt.rate) {
+				assert.True(math.IsNaN(c.Rate()), c.Rate())
+			} else {
+				assert.Equal(tt.rate, c.Rate())
+			}
+		})
+	}
+}
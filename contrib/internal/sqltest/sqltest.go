@@ -50,6 +50,7 @@ func RunAll(t *testing.T, cfg *Config) {
 		"Statement":     testStatement,
 		"BeginRollback": testBeginRollback,
 		"Exec":          testExec,
+		"Normalization": testNormalization,
 	} {
 		t.Run(name, test(cfg))
 	}
@@ -164,6 +165,7 @@ func testExec(cfg *Config) func(*testing.T) {
 	return func(t *testing.T) {
 		assert := assert.New(t)
 		query := fmt.Sprintf("INSERT INTO %s(name) VALUES('New York')", cfg.TableName)
+		resource := fmt.Sprintf("INSERT INTO %s(name) VALUES(?)", cfg.TableName)
 
 		parent, ctx := tracer.StartSpanFromContext(context.Background(), "test.parent",
 			tracer.ServiceName("test"),
@@ -185,7 +187,7 @@ func testExec(cfg *Config) func(*testing.T) {
 
 		var span mocktracer.Span
 		for _, s := range spans {
-			if s.OperationName() == cfg.ExpectName && s.Tag(ext.ResourceName) == query {
+			if s.OperationName() == cfg.ExpectName && s.Tag(ext.ResourceName) == resource {
 				span = s
 			}
 		}
@@ -205,6 +207,49 @@ func testExec(cfg *Config) func(*testing.T) {
 	}
 }
 
+func testNormalization(cfg *Config) func(*testing.T) {
+	// the literals are quoted in a way which is only understood using the
+	// dialect of the driver
+	var query string
+	switch cfg.DriverName {
+	case "postgres":
+		query = fmt.Sprintf("SELECT id, name FROM %s WHERE name = $$New York$$ /* by name */", cfg.TableName)
+	case "mysql":
+		query = fmt.Sprintf(`SELECT id, name FROM %s WHERE name = "New York" # by name`, cfg.TableName)
+	}
+	resource := fmt.Sprintf("SELECT id, name FROM %s WHERE name = ?", cfg.TableName)
+	return func(t *testing.T) {
+		cfg.mockTracer.Reset()
+		assert := assert.New(t)
+		rows, err := cfg.DB.Query(query)
+		if assert.Nil(err) {
+			rows.Close()
+		}
+
+		spans := cfg.mockTracer.FinishedSpans()
+		assert.Len(spans, 1)
+
+		span := spans[0]
+		assert.Equal(resource, span.Tag(ext.ResourceName))
+		if cfg.RawQuery {
+			assert.Equal(query, span.Tag(ext.SQLQuery))
+		} else {
+			assert.Nil(span.Tag(ext.SQLQuery))
+		}
+
+		// queries only made of comments are normalized to nothing, which must
+		// not result in spans without a resource
+		cfg.mockTracer.Reset()
+		cfg.DB.Exec("/* only a comment */")
+
+		spans = cfg.mockTracer.FinishedSpans()
+		assert.NotEmpty(spans)
+		for _, s := range spans {
+			assert.NotEmpty(s.Tag(ext.ResourceName))
+		}
+	}
+}
+
 // Config holds the test configuration.
 type Config struct {
 	*sql.DB
@@ -213,4 +258,8 @@ type Config struct {
 	TableName  string
 	ExpectName string
 	ExpectTags map[string]interface{}
+
+	// RawQuery is set when the driver was registered using WithRawQuery, in
+	// which case the raw queries are expected to be recorded.
+	RawQuery bool
 }
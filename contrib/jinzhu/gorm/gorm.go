@@ -1,4 +1,8 @@
 // Package gorm provides helper functions for tracing the jinzhu/gorm package (https://github.com/jinzhu/gorm).
+//
+// Queries are traced by the (gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql) package, so the
+// resource of the spans is the normalized query, and the raw query can be recorded by registering
+// the dialect using the WithRawQuery option.
 package gorm
 
 import (
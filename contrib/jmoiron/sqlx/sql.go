@@ -3,7 +3,9 @@
 // you will be using, then continue using the package as you normally would.
 //
 // For more information on registering and why this needs to happen, please check the
-// github.com/DataDog/dd-trace-go/contrib/database/sql package.
+// github.com/DataDog/dd-trace-go/contrib/database/sql package. As with that package, the
+// resource of the spans is the normalized query, and the raw query can be recorded by
+// registering the driver using the WithRawQuery option.
 //
 package sqlx // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/jmoiron/sqlx"
 
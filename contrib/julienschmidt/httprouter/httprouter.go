@@ -2,10 +2,14 @@
 package httprouter // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/julienschmidt/httprouter"
 
 import (
+	"math"
 	"net/http"
 	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httputil"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -35,5 +39,9 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		route = strings.Replace(route, param.Value, ":"+param.Key, 1)
 	}
 	resource := req.Method + " " + route
-	httputil.TraceAndServe(r.Router, w, req, r.config.serviceName, resource, r.config.spanOpts...)
+	spanopts := r.config.spanOpts
+	if rate := r.config.analytics.Rate(); !math.IsNaN(rate) {
+		spanopts = append([]ddtrace.StartSpanOption{tracer.Tag(ext.EventSampleRate, rate)}, spanopts...)
+	}
+	httputil.TraceAndServe(r.Router, w, req, r.config.serviceName, resource, spanopts...)
 }
@@ -1,9 +1,13 @@
 package httprouter
 
-import "gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+import (
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+)
 
 type routerConfig struct {
 	serviceName string
+	analytics   analytics.Config
 	spanOpts    []ddtrace.StartSpanOption
 }
 
@@ -27,3 +31,18 @@ func WithSpanOptions(opts ...ddtrace.StartSpanOption) RouterOption {
 		cfg.spanOpts = opts
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
@@ -17,9 +17,20 @@ const (
 )
 
 // WrapRoundTripper wraps a RoundTripper intended for interfacing with
-// Kubernetes and traces all requests.
+// Kubernetes and traces all requests. It can be assigned to the WrapTransport
+// field of a rest.Config.
 func WrapRoundTripper(rt http.RoundTripper) http.RoundTripper {
-	return httptrace.WrapRoundTripper(rt,
+	return WrapRoundTripperWithOptions(rt)
+}
+
+// WrapRoundTripperWithOptions is like WrapRoundTripper, but allows customizing
+// the traces using the given options.
+func WrapRoundTripperWithOptions(rt http.RoundTripper, opts ...Option) http.RoundTripper {
+	cfg := new(config)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	rtOpts := []httptrace.RoundTripperOption{
 		httptrace.WithBefore(func(req *http.Request, span ddtrace.Span) {
 			span.SetTag(ext.ServiceName, "kubernetes")
 			span.SetTag(ext.ResourceName, RequestToResource(req.Method, req.URL.Path))
@@ -31,7 +42,12 @@ func WrapRoundTripper(rt http.RoundTripper) http.RoundTripper {
 			kubeAuditID := strconv.FormatUint(traceID, 10)
 			req.Header.Set("Audit-Id", kubeAuditID)
 			span.SetTag("kubernetes.audit_id", kubeAuditID)
-		}))
+		}),
+	}
+	if cfg.analytics.IsSet() {
+		rtOpts = append(rtOpts, httptrace.RTWithAnalyticsRate(cfg.analytics.Rate()))
+	}
+	return httptrace.WrapRoundTripper(rt, rtOpts...)
 }
 
 // RequestToResource parses a Kubernetes request and extracts a resource name from it.
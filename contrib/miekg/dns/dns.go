@@ -3,6 +3,7 @@ package dns
 import (
 	"context"
 	"errors"
+	"math"
 	"net"
 	"time"
 
@@ -14,31 +15,33 @@ import (
 )
 
 // ListenAndServe calls dns.ListenAndServe with a wrapped Handler.
-func ListenAndServe(addr string, network string, handler dns.Handler) error {
-	return dns.ListenAndServe(addr, network, WrapHandler(handler))
+func ListenAndServe(addr string, network string, handler dns.Handler, opts ...Option) error {
+	return dns.ListenAndServe(addr, network, WrapHandler(handler, opts...))
 }
 
 // ListenAndServeTLS calls dns.ListenAndServeTLS with a wrapped Handler.
-func ListenAndServeTLS(addr, certFile, keyFile string, handler dns.Handler) error {
-	return dns.ListenAndServeTLS(addr, certFile, keyFile, WrapHandler(handler))
+func ListenAndServeTLS(addr, certFile, keyFile string, handler dns.Handler, opts ...Option) error {
+	return dns.ListenAndServeTLS(addr, certFile, keyFile, WrapHandler(handler, opts...))
 }
 
 // A Handler wraps a DNS Handler so that requests are traced.
 type Handler struct {
 	dns.Handler
+	cfg *config
 }
 
 // WrapHandler creates a new, wrapped DNS handler.
-func WrapHandler(handler dns.Handler) *Handler {
+func WrapHandler(handler dns.Handler, opts ...Option) *Handler {
 	return &Handler{
 		Handler: handler,
+		cfg:     newConfig(opts...),
 	}
 }
 
 // ServeDNS dispatches requests to the underlying Handler. All requests will be
 // traced.
 func (h *Handler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
-	span, _ := startSpan(context.Background(), r.Opcode)
+	span, _ := startSpan(context.Background(), r.Opcode, h.cfg)
 	rw := &responseWriter{ResponseWriter: w}
 	h.Handler.ServeDNS(rw, r)
 	span.Finish(tracer.WithError(rw.err))
@@ -59,24 +62,24 @@ func (rw *responseWriter) WriteMsg(msg *dns.Msg) error {
 }
 
 // Exchange calls dns.Exchange and traces the request.
-func Exchange(m *dns.Msg, addr string) (r *dns.Msg, err error) {
-	span, _ := startSpan(context.Background(), m.Opcode)
+func Exchange(m *dns.Msg, addr string, opts ...Option) (r *dns.Msg, err error) {
+	span, _ := startSpan(context.Background(), m.Opcode, newConfig(opts...))
 	r, err = dns.Exchange(m, addr)
 	span.Finish(tracer.WithError(err))
 	return r, err
 }
 
 // ExchangeConn calls dns.ExchangeConn and traces the request.
-func ExchangeConn(c net.Conn, m *dns.Msg) (r *dns.Msg, err error) {
-	span, _ := startSpan(context.Background(), m.Opcode)
+func ExchangeConn(c net.Conn, m *dns.Msg, opts ...Option) (r *dns.Msg, err error) {
+	span, _ := startSpan(context.Background(), m.Opcode, newConfig(opts...))
 	r, err = dns.ExchangeConn(c, m)
 	span.Finish(tracer.WithError(err))
 	return r, err
 }
 
 // ExchangeContext calls dns.ExchangeContext and traces the request.
-func ExchangeContext(ctx context.Context, m *dns.Msg, addr string) (r *dns.Msg, err error) {
-	span, ctx := startSpan(ctx, m.Opcode)
+func ExchangeContext(ctx context.Context, m *dns.Msg, addr string, opts ...Option) (r *dns.Msg, err error) {
+	span, ctx := startSpan(ctx, m.Opcode, newConfig(opts...))
 	r, err = dns.ExchangeContext(ctx, m, addr)
 	span.Finish(tracer.WithError(err))
 	return r, err
@@ -85,11 +88,20 @@ func ExchangeContext(ctx context.Context, m *dns.Msg, addr string) (r *dns.Msg,
 // A Client wraps a DNS Client so that requests are traced.
 type Client struct {
 	*dns.Client
+	cfg *config
+}
+
+// WrapClient returns a Client tracing the requests made by client.
+func WrapClient(client *dns.Client, opts ...Option) *Client {
+	return &Client{
+		Client: client,
+		cfg:    newConfig(opts...),
+	}
 }
 
 // Exchange calls the underlying Client.Exchange and traces the request.
 func (c *Client) Exchange(m *dns.Msg, addr string) (r *dns.Msg, rtt time.Duration, err error) {
-	span, _ := startSpan(context.Background(), m.Opcode)
+	span, _ := startSpan(context.Background(), m.Opcode, c.cfg)
 	r, rtt, err = c.Client.Exchange(m, addr)
 	span.Finish(tracer.WithError(err))
 	return r, rtt, err
@@ -97,15 +109,24 @@ func (c *Client) Exchange(m *dns.Msg, addr string) (r *dns.Msg, rtt time.Duratio
 
 // ExchangeContext calls the underlying Client.ExchangeContext and traces the request.
 func (c *Client) ExchangeContext(ctx context.Context, m *dns.Msg, addr string) (r *dns.Msg, rtt time.Duration, err error) {
-	span, ctx := startSpan(ctx, m.Opcode)
+	span, ctx := startSpan(ctx, m.Opcode, c.cfg)
 	r, rtt, err = c.Client.ExchangeContext(ctx, m, addr)
 	span.Finish(tracer.WithError(err))
 	return r, rtt, err
 }
 
-func startSpan(ctx context.Context, opcode int) (ddtrace.Span, context.Context) {
-	return tracer.StartSpanFromContext(ctx, "dns.request",
+func startSpan(ctx context.Context, opcode int, cfg *config) (ddtrace.Span, context.Context) {
+	if cfg == nil {
+		// the Handler or Client was not created using WrapHandler or WrapClient
+		cfg = new(config)
+	}
+	opts := []ddtrace.StartSpanOption{
 		tracer.ServiceName("dns"),
 		tracer.ResourceName(dns.OpcodeToString[opcode]),
-		tracer.SpanType(ext.SpanTypeDNS))
+		tracer.SpanType(ext.SpanTypeDNS),
+	}
+	if rate := cfg.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
+	return tracer.StartSpanFromContext(ctx, "dns.request", opts...)
 }
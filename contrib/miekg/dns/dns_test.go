@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
@@ -48,6 +49,99 @@ func TestDNS(t *testing.T) {
 	}
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...Option) {
+		h := WrapHandler(dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			w.WriteMsg(m)
+		}), opts...)
+		m := new(dns.Msg)
+		m.SetQuestion("miek.nl.", dns.TypeMX)
+		h.ServeDNS(new(testResponseWriter), m)
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, WithAnalyticsRate(0.23))
+	})
+}
+
+func TestClientAnalyticsSettings(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})
+	addr := getFreeAddr(t).String()
+
+	// start an untraced server, so that only client spans are recorded
+	go func() {
+		err := dns.ListenAndServe(addr, "udp", mux)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	waitTillUDPReady(t, addr)
+
+	assertRate := func(t *testing.T, rate interface{}, exchange func(*dns.Msg) error) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		m := new(dns.Msg)
+		m.SetQuestion("miek.nl.", dns.TypeMX)
+		assert.NoError(t, exchange(m))
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		assertRate(t, nil, func(m *dns.Msg) error {
+			_, err := Exchange(m, addr)
+			return err
+		})
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		assertRate(t, 0.23, func(m *dns.Msg) error {
+			_, err := ExchangeContext(context.Background(), m, addr, WithAnalyticsRate(0.23))
+			return err
+		})
+	})
+
+	t.Run("client", func(t *testing.T) {
+		client := WrapClient(new(dns.Client), WithAnalyticsRate(0.23))
+		assertRate(t, 0.23, func(m *dns.Msg) error {
+			_, _, err := client.Exchange(m, addr)
+			return err
+		})
+	})
+
+	t.Run("client-literal", func(t *testing.T) {
+		client := &Client{Client: new(dns.Client)}
+		assertRate(t, nil, func(m *dns.Msg) error {
+			_, _, err := client.Exchange(m, addr)
+			return err
+		})
+	})
+}
+
 func getFreeAddr(t *testing.T) net.Addr {
 	li, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -70,3 +164,10 @@ func waitTillUDPReady(t *testing.T, addr string) {
 		time.Sleep(time.Millisecond * 100)
 	}
 }
+
+// testResponseWriter is a dns.ResponseWriter discarding the messages written.
+type testResponseWriter struct {
+	dns.ResponseWriter
+}
+
+func (*testResponseWriter) WriteMsg(*dns.Msg) error { return nil }
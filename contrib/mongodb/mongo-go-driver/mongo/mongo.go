@@ -8,6 +8,7 @@ package mongo
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"sync"
 
@@ -25,21 +26,26 @@ type spanKey struct {
 type monitor struct {
 	sync.Mutex
 	spans map[spanKey]ddtrace.Span
+	cfg   *config
 }
 
 func (m *monitor) Started(ctx context.Context, evt *event.CommandStartedEvent) {
 	hostname, port := peerInfo(evt)
 	statement := evt.Command.ToExtJSON(false)
 
-	span, _ := tracer.StartSpanFromContext(ctx, "mongodb.query",
+	opts := []ddtrace.StartSpanOption{
 		tracer.ServiceName("mongo"),
-		tracer.ResourceName("mongo."+evt.CommandName),
+		tracer.ResourceName("mongo." + evt.CommandName),
 		tracer.Tag(ext.DBInstance, evt.DatabaseName),
 		tracer.Tag(ext.DBStatement, statement),
 		tracer.Tag(ext.DBType, "mongo"),
 		tracer.Tag(ext.PeerHostname, hostname),
 		tracer.Tag(ext.PeerPort, port),
-	)
+	}
+	if rate := m.cfg.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
+	span, _ := tracer.StartSpanFromContext(ctx, "mongodb.query", opts...)
 	key := spanKey{
 		ConnectionID: evt.ConnectionID,
 		RequestID:    evt.RequestID,
@@ -75,9 +81,14 @@ func (m *monitor) Finished(evt *event.CommandFinishedEvent, err error) {
 }
 
 // NewMonitor creates a new mongodb event CommandMonitor.
-func NewMonitor() *event.CommandMonitor {
+func NewMonitor(opts ...Option) *event.CommandMonitor {
+	cfg := new(config)
+	for _, fn := range opts {
+		fn(cfg)
+	}
 	m := &monitor{
 		spans: make(map[spanKey]ddtrace.Span),
+		cfg:   cfg,
 	}
 	return &event.CommandMonitor{
 		Started:   m.Started,
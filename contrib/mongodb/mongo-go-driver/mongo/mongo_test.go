@@ -65,6 +65,49 @@ func Test(t *testing.T) {
 	assert.Equal(t, "mongo", s.Tag(ext.DBType))
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...Option) {
+		li, err := mockMongo()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+
+		addr := fmt.Sprintf("mongodb://%s", li.Addr().String())
+		client, err := mongo.Connect(ctx, addr, clientopt.Single(true), clientopt.Monitor(NewMonitor(opts...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client.
+			Database("test-database").
+			Collection("test-collection").
+			InsertOne(ctx, bson.NewDocument(
+				bson.EC.String("test-item", "test-value"),
+			))
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, WithAnalyticsRate(0.23))
+	})
+}
+
 // mockMongo implements a crude mongodb server that responds with
 // expected replies so that we can confirm tracing works properly
 func mockMongo() (net.Listener, error) {
@@ -2,9 +2,14 @@
 package http // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
 
 import (
+	"math"
 	"net/http"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httputil"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
 
 // ServeMux is an HTTP request multiplexer that traces all the incoming requests.
@@ -35,12 +40,26 @@ func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// get the resource associated to this request
 	_, route := mux.Handler(r)
 	resource := r.Method + " " + route
-	httputil.TraceAndServe(mux.ServeMux, w, r, mux.config.serviceName, resource)
+	httputil.TraceAndServe(mux.ServeMux, w, r, mux.config.serviceName, resource, spanOptions(&mux.config.analytics)...)
 }
 
 // WrapHandler wraps an http.Handler with tracing using the given service and resource.
-func WrapHandler(h http.Handler, service, resource string) http.Handler {
+func WrapHandler(h http.Handler, service, resource string, opts ...WrapHandlerOption) http.Handler {
+	cfg := new(wrapHandlerConfig)
+	for _, fn := range opts {
+		fn(cfg)
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		httputil.TraceAndServe(h, w, req, service, resource)
+		httputil.TraceAndServe(h, w, req, service, resource, spanOptions(&cfg.analytics)...)
 	})
 }
+
+// spanOptions returns the options to start the spans of requests with, given
+// the analytics setting of the handler.
+func spanOptions(a *analytics.Config) []ddtrace.StartSpanOption {
+	rate := a.Rate()
+	if math.IsNaN(rate) {
+		return nil
+	}
+	return []ddtrace.StartSpanOption{tracer.Tag(ext.EventSampleRate, rate)}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 )
 
 func TestHttpTracer200(t *testing.T) {
@@ -90,6 +91,58 @@ func TestWrapHandler200(t *testing.T) {
 	assert.Equal(nil, s.Tag(ext.Error))
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, muxOpts []MuxOption, handlerOpts ...WrapHandlerOption) {
+		mux := NewServeMux(muxOpts...)
+		mux.HandleFunc("/200", handler200)
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/200", nil))
+		WrapHandler(http.HandlerFunc(handler200), "my-service", "my-resource", handlerOpts...).
+			ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 2)
+		for _, s := range spans {
+			assert.Equal(t, rate, s.Tag(ext.EventSampleRate))
+		}
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil, nil)
+	})
+
+	t.Run("global-after-configuration", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		mux := NewServeMux()
+		mux.HandleFunc("/200", handler200)
+		h := WrapHandler(http.HandlerFunc(handler200), "my-service", "my-resource")
+
+		rate := globalconfig.AnalyticsRate()
+		defer globalconfig.SetAnalyticsRate(rate)
+		globalconfig.SetAnalyticsRate(0.4)
+
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/200", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 2)
+		for _, s := range spans {
+			assert.Equal(t, 0.4, s.Tag(ext.EventSampleRate))
+		}
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, []MuxOption{WithAnalyticsRate(0.23)}, HandlerWithAnalyticsRate(0.23))
+	})
+}
+
 func router() http.Handler {
 	mux := NewServeMux(WithServiceName("my-service"))
 	mux.HandleFunc("/200", handler200)
@@ -3,10 +3,14 @@ package http
 import (
 	"net/http"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 )
 
-type muxConfig struct{ serviceName string }
+type muxConfig struct {
+	serviceName string
+	analytics   analytics.Config
+}
 
 // MuxOption represents an option that can be passed to NewServeMux.
 type MuxOption func(*muxConfig)
@@ -22,6 +26,43 @@ func WithServiceName(name string) MuxOption {
 	}
 }
 
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) MuxOption {
+	return func(cfg *muxConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) MuxOption {
+	return func(cfg *muxConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
+
+type wrapHandlerConfig struct {
+	analytics analytics.Config
+}
+
+// A WrapHandlerOption represents an option that can be passed to WrapHandler.
+type WrapHandlerOption func(*wrapHandlerConfig)
+
+// HandlerWithAnalytics enables Trace Analytics for all started spans.
+func HandlerWithAnalytics(on bool) WrapHandlerOption {
+	return func(cfg *wrapHandlerConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// HandlerWithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func HandlerWithAnalyticsRate(rate float64) WrapHandlerOption {
+	return func(cfg *wrapHandlerConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
+
 // A RoundTripperBeforeFunc can be used to modify a span before an http
 // RoundTrip is made.
 type RoundTripperBeforeFunc func(*http.Request, ddtrace.Span)
@@ -31,8 +72,13 @@ type RoundTripperBeforeFunc func(*http.Request, ddtrace.Span)
 type RoundTripperAfterFunc func(*http.Response, ddtrace.Span)
 
 type roundTripperConfig struct {
-	before RoundTripperBeforeFunc
-	after  RoundTripperAfterFunc
+	before    RoundTripperBeforeFunc
+	after     RoundTripperAfterFunc
+	analytics analytics.Config
+}
+
+func newRoundTripperConfig() *roundTripperConfig {
+	return &roundTripperConfig{}
 }
 
 // A RoundTripperOption represents an option that can be passed to
@@ -54,3 +100,18 @@ func WithAfter(f RoundTripperAfterFunc) RoundTripperOption {
 		cfg.after = f
 	}
 }
+
+// RTWithAnalytics enables Trace Analytics for all started spans.
+func RTWithAnalytics(on bool) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// RTWithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func RTWithAnalyticsRate(rate float64) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
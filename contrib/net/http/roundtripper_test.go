@@ -10,6 +10,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 )
 
 func TestRoundTripper(t *testing.T) {
@@ -66,3 +67,33 @@ func TestWrapClient(t *testing.T) {
 	_, ok := c.Transport.(*roundTripper)
 	assert.True(t, ok)
 }
+
+func TestRoundTripperAnalyticsSettings(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	assertRate := func(t *testing.T, rate interface{}, opts ...RoundTripperOption) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		client := &http.Client{Transport: WrapRoundTripper(http.DefaultTransport, opts...)}
+		_, err := client.Get(s.URL)
+		assert.NoError(t, err)
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) { assertRate(t, nil) })
+	t.Run("rate", func(t *testing.T) { assertRate(t, 0.23, RTWithAnalyticsRate(0.23)) })
+
+	t.Run("global", func(t *testing.T) {
+		rate := globalconfig.AnalyticsRate()
+		defer globalconfig.SetAnalyticsRate(rate)
+		globalconfig.SetAnalyticsRate(0.4)
+
+		assertRate(t, 0.4)
+	})
+
+}
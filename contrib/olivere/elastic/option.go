@@ -1,10 +1,15 @@
 package elastic
 
-import "net/http"
+import (
+	"net/http"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/analytics"
+)
 
 type clientConfig struct {
 	serviceName string
 	transport   *http.Transport
+	analytics   analytics.Config
 }
 
 // ClientOption represents an option that can be used when creating a client.
@@ -28,3 +33,18 @@ func WithTransport(t *http.Transport) ClientOption {
 		cfg.transport = t
 	}
 }
+
+// WithAnalytics enables Trace Analytics for all started spans.
+func WithAnalytics(on bool) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.analytics.Enable(on)
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.analytics.SetRate(rate)
+	}
+}
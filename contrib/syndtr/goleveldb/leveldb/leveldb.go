@@ -3,6 +3,7 @@ package leveldb // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/syndtr/goleve
 
 import (
 	"context"
+	"math"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
@@ -258,10 +259,14 @@ func (it *Iterator) Release() {
 }
 
 func startSpan(cfg *config, name string) ddtrace.Span {
-	span, _ := tracer.StartSpanFromContext(cfg.ctx, "leveldb.query",
+	opts := []ddtrace.StartSpanOption{
 		tracer.SpanType(ext.SpanTypeLevelDB),
 		tracer.ServiceName(cfg.serviceName),
 		tracer.ResourceName(name),
-	)
+	}
+	if rate := cfg.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
+	span, _ := tracer.StartSpanFromContext(cfg.ctx, "leveldb.query", opts...)
 	return span
 }
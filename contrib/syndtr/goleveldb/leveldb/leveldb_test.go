@@ -124,6 +124,34 @@ func TestIterator(t *testing.T) {
 	})
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...Option) {
+		db, err := Open(storage.NewMemStorage(), &opt.Options{}, opts...)
+		assert.NoError(t, err)
+		defer db.Close()
+
+		db.Get([]byte("hello"), nil)
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, WithAnalyticsRate(0.23))
+	})
+}
+
 func testAction(t *testing.T, name string, f func(mt mocktracer.Tracer, db *DB)) {
 	t.Run(name, func(t *testing.T) {
 		mt := mocktracer.Start()
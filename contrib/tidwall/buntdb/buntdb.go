@@ -2,6 +2,7 @@ package buntdb
 
 import (
 	"context"
+	"math"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
@@ -86,11 +87,15 @@ func WrapTx(tx *buntdb.Tx, opts ...Option) *Tx {
 }
 
 func (tx *Tx) startSpan(name string) ddtrace.Span {
-	span, _ := tracer.StartSpanFromContext(tx.cfg.ctx, "buntdb.query",
+	opts := []ddtrace.StartSpanOption{
 		tracer.SpanType(ext.AppTypeDB),
 		tracer.ServiceName(tx.cfg.serviceName),
 		tracer.ResourceName(name),
-	)
+	}
+	if rate := tx.cfg.analytics.Rate(); !math.IsNaN(rate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, rate))
+	}
+	span, _ := tracer.StartSpanFromContext(tx.cfg.ctx, "buntdb.query", opts...)
 	return span
 }
 
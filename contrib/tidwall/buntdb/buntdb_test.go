@@ -386,6 +386,38 @@ func testView(t *testing.T, name string, f func(tx *Tx) error) {
 	assert.Equal(t, "buntdb.query", spans[0].OperationName())
 }
 
+func TestAnalyticsSettings(t *testing.T) {
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...Option) {
+		db, err := Open(":memory:", opts...)
+		assert.NoError(t, err)
+		defer db.Close()
+
+		err = db.View(func(tx *Tx) error {
+			_, err := tx.Get("regular:a")
+			return err
+		})
+		assert.Equal(t, buntdb.ErrNotFound, err)
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, rate, spans[0].Tag(ext.EventSampleRate))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, nil)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRate(t, mt, 0.23, WithAnalyticsRate(0.23))
+	})
+}
+
 func getDatabase(t *testing.T) *DB {
 	bdb, err := buntdb.Open(":memory:")
 	if err != nil {
@@ -72,6 +72,35 @@ type SpanContext interface {
 	ForeachBaggageItem(handler func(k, v string) bool)
 }
 
+// SpanContextW3C represents a SpanContext with a 128-bit trace ID, as used by
+// the W3C Trace Context format. The lower 64 bits of the trace ID are returned
+// by TraceID.
+type SpanContextW3C interface {
+	SpanContext
+
+	// TraceID128 returns the full 128-bit trace ID as 32 lowercase hex
+	// characters. The upper 64 bits are zero for 64-bit trace IDs.
+	TraceID128() string
+}
+
+// Logger implementations are able to log the messages that a tracer might output.
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	// Debug logs a message which is only useful when debugging the tracer.
+	Debug(msg string)
+
+	// Info logs an informational message about the state of the tracer.
+	Info(msg string)
+
+	// Warn logs a message about a problem which does not prevent the tracer
+	// from working, such as an invalid configuration value.
+	Warn(msg string)
+
+	// Error logs a message about a problem which results in lost data, such
+	// as traces that could not be sent to the agent.
+	Error(msg string)
+}
+
 // StartSpanOption is a configuration option that can be used with a Tracer's StartSpan method.
 type StartSpanOption func(cfg *StartSpanConfig)
 
@@ -24,6 +24,9 @@ func TestSpec(t *testing.T) {
 		SQLQuery, "sql.query",
 		HTTPURL, "http.url",
 		Environment, "env",
+		EventSampleRate, "_dd1.sr.eausr",
+		ManualKeep, "manual.keep",
+		ManualDrop, "manual.drop",
 	}
 	if len(tests)%2 != 0 {
 		t.Fatal("uneven test count")
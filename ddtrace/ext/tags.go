@@ -12,6 +12,14 @@ const (
 	// SamplingPriority is the tag that marks the sampling priority of a span.
 	SamplingPriority = "sampling.priority"
 
+	// ManualKeep is a tag which specifies that the trace to which this span
+	// belongs to should be kept when set to true.
+	ManualKeep = "manual.keep"
+
+	// ManualDrop is a tag which specifies that the trace to which this span
+	// belongs to should be dropped when set to true.
+	ManualDrop = "manual.drop"
+
 	// SQLType sets the sql type tag.
 	SQLType = "sql"
 
@@ -54,4 +62,12 @@ const (
 
 	// Environment specifies the environment to use with a trace.
 	Environment = "env"
+
+	// Version specifies the version of the application being traced.
+	Version = "version"
+
+	// EventSampleRate specifies the rate at which the span is sampled as an
+	// APM event, i.e. indexed by trace analytics, between 0 and 1. It is a
+	// reserved metric which is set by integrations when analytics are enabled.
+	EventSampleRate = "_dd1.sr.eausr"
 )
@@ -0,0 +1,43 @@
+package internal
+
+import "strconv"
+
+// IsManualPriority reports whether setting the ext.ManualKeep or ext.ManualDrop
+// tag to the given value applies it. Any value does, except nil, false, the zero
+// numbers, and the strings which are empty or parsed as false, such as "false".
+func IsManualPriority(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		b, err := strconv.ParseBool(v)
+		return v != "" && (err != nil || b)
+	case int:
+		return v != 0
+	case int8:
+		return v != 0
+	case int16:
+		return v != 0
+	case int32:
+		return v != 0
+	case int64:
+		return v != 0
+	case uint:
+		return v != 0
+	case uint8:
+		return v != 0
+	case uint16:
+		return v != 0
+	case uint32:
+		return v != 0
+	case uint64:
+		return v != 0
+	case float32:
+		return v != 0
+	case float64:
+		return v != 0
+	}
+	return true
+}
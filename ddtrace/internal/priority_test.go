@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsManualPriority(t *testing.T) {
+	for _, tt := range []struct {
+		value interface{}
+		want  bool
+	}{
+		{true, true},
+		{"true", true},
+		{"yes", true},
+		{1, true},
+		{uint8(1), true},
+		{struct{}{}, true},
+		{false, false},
+		{"false", false},
+		{"0", false},
+		{"", false},
+		{0, false},
+		{int16(0), false},
+		{0.0, false},
+		{nil, false},
+	} {
+		assert.Equal(t, tt.want, IsManualPriority(tt.value), "%#v", tt.value)
+	}
+}
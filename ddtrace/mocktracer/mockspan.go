@@ -7,6 +7,7 @@ import (
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 )
 
 var _ ddtrace.Span = (*mockspan)(nil)
@@ -114,6 +115,14 @@ func (s *mockspan) SetTag(key string, value interface{}) {
 			s.context.setSamplingPriority(int(p))
 		}
 	}
+	if internal.IsManualPriority(value) {
+		switch key {
+		case ext.ManualKeep:
+			s.context.setSamplingPriority(ext.PriorityUserKeep)
+		case ext.ManualDrop:
+			s.context.setSamplingPriority(ext.PriorityUserReject)
+		}
+	}
 	s.tags[key] = value
 }
 
@@ -80,6 +80,15 @@ func TestSpanSetTagPriority(t *testing.T) {
 	s.SetTag(ext.SamplingPriority, -1)
 	assert.True(s.context.hasSamplingPriority())
 	assert.Equal(-1, s.context.samplingPriority())
+
+	s.SetTag(ext.ManualKeep, true)
+	assert.Equal(ext.PriorityUserKeep, s.context.samplingPriority())
+	for _, v := range []interface{}{false, "false", 0, nil} {
+		s.SetTag(ext.ManualDrop, v)
+		assert.Equal(ext.PriorityUserKeep, s.context.samplingPriority())
+	}
+	s.SetTag(ext.ManualDrop, true)
+	assert.Equal(ext.PriorityUserReject, s.context.samplingPriority())
 }
 
 func TestSpanTagImmutability(t *testing.T) {
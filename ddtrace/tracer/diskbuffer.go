@@ -0,0 +1,314 @@
+package tracer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// segmentExt and segmentTmpExt are the extensions of the complete segment
+	// files and of the ones being written.
+	segmentExt    = ".seg"
+	segmentTmpExt = ".tmp"
+
+	// segmentHeaderSize is the size of the header of segment files, which is
+	// made of a magic number, followed by the number of traces, the size and
+	// the CRC-32 checksum of the msgpack-encoded traces which follow.
+	segmentHeaderSize = 16
+
+	// segmentMagic identifies segment files.
+	segmentMagic = "DDT1"
+)
+
+var (
+	// errCorruptSegment is returned when reading a segment file which is
+	// invalid, for example because it was truncated.
+	errCorruptSegment = errors.New("corrupt segment file")
+
+	// errSegmentTooLarge is returned when writing a payload which is larger
+	// than the disk buffer.
+	errSegmentTooLarge = errors.New("payload larger than the disk buffer")
+)
+
+// diskBuffer spools the payloads which could not be sent to the agent into
+// segment files, one per payload, so that they can be sent once the agent is
+// available again, even after the process restarts. It is safe for concurrent
+// use.
+//
+// Segments are numbered sequentially and are replayed in order. They are first
+// written to temporary files, which are renamed once complete, so that files
+// which were partially written when the process crashed are ignored.
+type diskBuffer struct {
+	dir     string
+	maxSize int // maximum total size of the segment files
+
+	mu       sync.Mutex   // guards below fields
+	segments []segmentRef // oldest first
+	size     int          // total size of the segments
+	seq      uint64       // sequence number of the next segment
+
+	// reading is the sequence number of the segment being sent, which is not
+	// evicted until it is removed or released, if isReading is set.
+	reading   uint64
+	isReading bool
+
+	// wake receives a value when segments should be sent right away, for
+	// example because the agent responded again.
+	wake chan struct{}
+}
+
+// segmentRef references a segment file of the disk buffer.
+type segmentRef struct {
+	seq   uint64
+	size  int
+	count int // number of traces
+}
+
+// openDiskBuffer opens the disk buffer stored in dir, creating the directory if
+// needed. Segments left over by a previous process are kept to be replayed, and
+// temporary files, which were partially written, are removed.
+func openDiskBuffer(dir string, maxSize int) (*diskBuffer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	b := &diskBuffer{dir: dir, maxSize: maxSize, wake: make(chan struct{}, 1)}
+	for _, f := range files {
+		name := f.Name()
+		switch filepath.Ext(name) {
+		case segmentTmpExt:
+			os.Remove(filepath.Join(dir, name))
+		case segmentExt:
+			seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+			if err != nil {
+				continue
+			}
+			// a corrupt header is reported when the segment is read
+			count, _ := readSegmentCount(filepath.Join(dir, name))
+			b.segments = append(b.segments, segmentRef{seq: seq, size: int(f.Size()), count: count})
+			b.size += int(f.Size())
+			if seq >= b.seq {
+				b.seq = seq + 1
+			}
+		}
+	}
+	sort.Slice(b.segments, func(i, j int) bool { return b.segments[i].seq < b.segments[j].seq })
+	return b, nil
+}
+
+// len returns the number of segments in the buffer.
+func (b *diskBuffer) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.segments)
+}
+
+// write writes p to a new segment, evicting the oldest segments if needed to
+// keep the buffer within its maximum size. It returns the number of traces
+// evicted. The segment being sent is never evicted, so the buffer may exceed
+// its maximum size by that segment until it is removed.
+func (b *diskBuffer) write(p *payload) (evicted int, err error) {
+	count := p.itemCount()
+	size := segmentHeaderSize + p.buf.Len()
+	if size > b.maxSize {
+		return 0, errSegmentTooLarge
+	}
+	b.mu.Lock()
+	seq := b.seq
+	b.seq++
+	b.mu.Unlock()
+
+	tmp := b.path(seq, segmentTmpExt)
+	if err := writeSegment(tmp, p); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := 0; i < len(b.segments) && b.size+size > b.maxSize; {
+		s := b.segments[i]
+		if b.isReading && s.seq == b.reading {
+			i++
+			continue
+		}
+		b.segments = append(b.segments[:i], b.segments[i+1:]...)
+		b.size -= s.size
+		evicted += s.count
+		os.Remove(b.path(s.seq, segmentExt))
+	}
+	path := b.path(seq, segmentExt)
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return evicted, err
+	}
+	// the rename is only durable once the directory is synced
+	if err := syncDir(b.dir); err != nil {
+		os.Remove(path)
+		return evicted, err
+	}
+	b.segments = append(b.segments, segmentRef{seq: seq, size: size, count: count})
+	b.size += size
+	return evicted, nil
+}
+
+// oldest returns the oldest segment of the buffer, or false if it is empty.
+func (b *diskBuffer) oldest() (segmentRef, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.segments) == 0 {
+		return segmentRef{}, false
+	}
+	return b.segments[0], true
+}
+
+// read returns the payload of the segment s, which is then not evicted until it
+// is removed or released. Segments which can not be read are removed from the
+// buffer.
+func (b *diskBuffer) read(s segmentRef) (*payload, error) {
+	b.mu.Lock()
+	b.reading, b.isReading = s.seq, true
+	b.mu.Unlock()
+	p, err := readSegment(b.path(s.seq, segmentExt))
+	if err != nil {
+		b.remove(s.seq)
+		return nil, err
+	}
+	return p, nil
+}
+
+// release allows the segment with the given sequence number, which was read
+// but not removed, to be evicted again.
+func (b *diskBuffer) release(seq uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.isReading && b.reading == seq {
+		b.isReading = false
+	}
+}
+
+// remove removes the segment with the given sequence number, if it is still
+// in the buffer.
+func (b *diskBuffer) remove(seq uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.isReading && b.reading == seq {
+		b.isReading = false
+	}
+	for i, s := range b.segments {
+		if s.seq == seq {
+			b.segments = append(b.segments[:i], b.segments[i+1:]...)
+			b.size -= s.size
+			os.Remove(b.path(seq, segmentExt))
+			return
+		}
+	}
+}
+
+// notify wakes up the goroutine replaying the segments, if any.
+func (b *diskBuffer) notify() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// path returns the path of the file of the segment with the given sequence
+// number and extension.
+func (b *diskBuffer) path(seq uint64, ext string) string {
+	return filepath.Join(b.dir, fmt.Sprintf("%020d%s", seq, ext))
+}
+
+// writeSegment writes the traces of p to a segment file at path, and syncs it
+// to disk.
+func writeSegment(path string, p *payload) error {
+	data := p.buf.Bytes()
+	var header [segmentHeaderSize]byte
+	copy(header[:], segmentMagic)
+	binary.BigEndian.PutUint32(header[4:], uint32(p.itemCount()))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(data)))
+	binary.BigEndian.PutUint32(header[12:], crc32.ChecksumIEEE(data))
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(header[:]); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir syncs the directory dir to disk, so that the files which were created,
+// renamed or removed in it persist after a crash.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readSegment reads the segment file at path and returns its payload.
+func readSegment(path string) (*payload, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < segmentHeaderSize || string(data[:4]) != segmentMagic {
+		return nil, errCorruptSegment
+	}
+	count := binary.BigEndian.Uint32(data[4:])
+	size := binary.BigEndian.Uint32(data[8:])
+	sum := binary.BigEndian.Uint32(data[12:])
+	data = data[segmentHeaderSize:]
+	if int(size) != len(data) || crc32.ChecksumIEEE(data) != sum {
+		return nil, errCorruptSegment
+	}
+	p := newPayload()
+	p.buf.Write(data)
+	p.count = uint64(count)
+	p.rewind()
+	return p, nil
+}
+
+// readSegmentCount returns the number of traces of the segment file at path,
+// as found in its header.
+func readSegmentCount(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var header [segmentHeaderSize]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil || string(header[:4]) != segmentMagic {
+		return 0, errCorruptSegment
+	}
+	return int(binary.BigEndian.Uint32(header[4:])), nil
+}
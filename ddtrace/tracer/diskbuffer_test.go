@@ -0,0 +1,302 @@
+package tracer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinylib/msgp/msgp"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+)
+
+// newDiskTestPayload returns a payload holding a trace for each of the given
+// operation names.
+func newDiskTestPayload(names ...string) *payload {
+	p := newPayload()
+	for _, name := range names {
+		p.push(spanList{newBasicSpan(name)})
+	}
+	return p
+}
+
+// readNames returns the names of the root spans of the traces of p.
+func readNames(t *testing.T, p *payload) []string {
+	var traces spanLists
+	if err := msgp.Decode(p, &traces); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, t := range traces {
+		names = append(names, t[0].Name)
+	}
+	return names
+}
+
+func TestDiskBuffer(t *testing.T) {
+	t.Run("order", func(t *testing.T) {
+		assert := assert.New(t)
+		dir, err := ioutil.TempDir("", "diskbuffer")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+
+		b, err := openDiskBuffer(dir, 1<<20)
+		assert.NoError(err)
+		for _, name := range []string{"a", "b"} {
+			evicted, err := b.write(newDiskTestPayload(name, name))
+			assert.NoError(err)
+			assert.Zero(evicted)
+		}
+
+		// segments survive a restart
+		b, err = openDiskBuffer(dir, 1<<20)
+		assert.NoError(err)
+		assert.Equal(2, b.len())
+		_, err = b.write(newDiskTestPayload("c"))
+		assert.NoError(err)
+		for _, want := range [][]string{{"a", "a"}, {"b", "b"}, {"c"}} {
+			s, ok := b.oldest()
+			assert.True(ok)
+			assert.Equal(len(want), s.count)
+			p, err := b.read(s)
+			assert.NoError(err)
+			assert.Equal(want, readNames(t, p))
+			b.remove(s.seq)
+		}
+		_, ok := b.oldest()
+		assert.False(ok)
+		files, err := ioutil.ReadDir(dir)
+		assert.NoError(err)
+		assert.Len(files, 0)
+	})
+
+	t.Run("full", func(t *testing.T) {
+		assert := assert.New(t)
+		dir, err := ioutil.TempDir("", "diskbuffer")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+
+		size := segmentHeaderSize + newDiskTestPayload("a", "a").buf.Len()
+		b, err := openDiskBuffer(dir, 2*size)
+		assert.NoError(err)
+		for _, name := range []string{"a", "b", "c"} {
+			evicted, err := b.write(newDiskTestPayload(name, name))
+			assert.NoError(err)
+			if name == "c" {
+				assert.Equal(2, evicted)
+			}
+		}
+		assert.Equal(2, b.len())
+		assert.Equal(2*size, b.size)
+		s, _ := b.oldest()
+		p, err := b.read(s)
+		assert.NoError(err)
+		assert.Equal([]string{"b", "b"}, readNames(t, p))
+
+		_, err = b.write(newDiskTestPayload("a", "b", "c", "d", "e"))
+		assert.Equal(errSegmentTooLarge, err)
+		assert.Equal(2, b.len())
+	})
+
+	t.Run("reading", func(t *testing.T) {
+		assert := assert.New(t)
+		dir, err := ioutil.TempDir("", "diskbuffer")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+
+		size := segmentHeaderSize + newDiskTestPayload("a", "a").buf.Len()
+		b, err := openDiskBuffer(dir, 2*size)
+		assert.NoError(err)
+		for _, name := range []string{"a", "b"} {
+			_, err := b.write(newDiskTestPayload(name, name))
+			assert.NoError(err)
+		}
+
+		// the segment being sent is skipped
+		a, _ := b.oldest()
+		_, err = b.read(a)
+		assert.NoError(err)
+		evicted, err := b.write(newDiskTestPayload("c", "c"))
+		assert.NoError(err)
+		assert.Equal(2, evicted)
+		s, _ := b.oldest()
+		assert.Equal(a.seq, s.seq)
+		assert.Equal(2, b.len())
+
+		// until it is released
+		b.release(a.seq)
+		evicted, err = b.write(newDiskTestPayload("d", "d"))
+		assert.NoError(err)
+		assert.Equal(2, evicted)
+		s, _ = b.oldest()
+		p, err := b.read(s)
+		assert.NoError(err)
+		assert.Equal([]string{"c", "c"}, readNames(t, p))
+	})
+
+	t.Run("crash", func(t *testing.T) {
+		assert := assert.New(t)
+		dir, err := ioutil.TempDir("", "diskbuffer")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+
+		b, err := openDiskBuffer(dir, 1<<20)
+		assert.NoError(err)
+		_, err = b.write(newDiskTestPayload("a"))
+		assert.NoError(err)
+		_, err = b.write(newDiskTestPayload("b"))
+		assert.NoError(err)
+
+		// a segment which was being written, and a truncated one
+		tmp := b.path(2, segmentTmpExt)
+		assert.NoError(ioutil.WriteFile(tmp, []byte("DDT1"), 0644))
+		first := b.path(0, segmentExt)
+		data, err := ioutil.ReadFile(first)
+		assert.NoError(err)
+		assert.NoError(ioutil.WriteFile(first, data[:len(data)-1], 0644))
+
+		b, err = openDiskBuffer(dir, 1<<20)
+		assert.NoError(err)
+		_, err = os.Stat(tmp)
+		assert.True(os.IsNotExist(err))
+		assert.Equal(2, b.len())
+
+		s, _ := b.oldest()
+		_, err = b.read(s)
+		assert.Equal(errCorruptSegment, err)
+		assert.Equal(1, b.len())
+		s, _ = b.oldest()
+		p, err := b.read(s)
+		assert.NoError(err)
+		assert.Equal([]string{"b"}, readNames(t, p))
+
+		// sequence numbers are not reused
+		_, err = b.write(newDiskTestPayload("c"))
+		assert.NoError(err)
+		_, err = os.Stat(b.path(2, segmentExt))
+		assert.NoError(err)
+	})
+}
+
+func TestTracerDiskBuffer(t *testing.T) {
+	t.Run("spill", func(t *testing.T) {
+		assert := assert.New(t)
+		dir, err := ioutil.TempDir("", "diskbuffer")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+		agent := newFlakyAgent(flakyResponse{status: 503}, flakyResponse{status: 503})
+		defer agent.Close()
+		tracer := newRetryTestTracer(agent)
+		tracer.retries = newRetryBuffer(0, defaultRetryMaxAge)
+		tracer.disk, err = openDiskBuffer(dir, 1<<20)
+		assert.NoError(err)
+
+		tracer.pushPayload([]*span{newBasicSpan("a")})
+		assert.Error(tracer.flushTraces(context.Background()))
+		assert.Equal(1, tracer.disk.len())
+		assert.Equal(1, tracer.spilledTraces)
+		assert.IsType(&sendRetryError{}, <-tracer.errorBuffer)
+
+		assert.Error(tracer.sendSpooled(context.Background()))
+		assert.Equal(1, tracer.disk.len())
+
+		// the agent is back
+		tracer.pushPayload([]*span{newBasicSpan("b")})
+		assert.NoError(tracer.flushTraces(context.Background()))
+		select {
+		case <-tracer.disk.wake:
+		default:
+			assert.Fail("replay was not notified")
+		}
+		assert.NoError(tracer.sendSpooled(context.Background()))
+		received, _ := agent.Received()
+		assert.Equal([]string{"b", "a"}, received)
+		assert.Equal(0, tracer.disk.len())
+	})
+
+	t.Run("evicted", func(t *testing.T) {
+		assert := assert.New(t)
+		dir, err := ioutil.TempDir("", "diskbuffer")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+		agent := newFlakyAgent(flakyResponse{status: 503})
+		defer agent.Close()
+		tracer := newRetryTestTracer(agent)
+		tracer.retries = newRetryBuffer(defaultRetryBufferSize, time.Minute)
+		tracer.disk, err = openDiskBuffer(dir, 1<<20)
+		assert.NoError(err)
+
+		tracer.pushPayload([]*span{newBasicSpan("a")})
+		assert.Error(tracer.flushTraces(context.Background()))
+		assert.Equal(1, tracer.retries.len())
+
+		// expired payloads are spooled rather than dropped
+		tracer.retries.entries[0].created = time.Now().Add(-2 * time.Minute)
+		tracer.retries.next = time.Time{}
+		tracer.flushTraces(context.Background())
+		assert.Equal(0, tracer.retries.len())
+		assert.Equal(1, tracer.disk.len())
+		assert.NoError(tracer.sendSpooled(context.Background()))
+		received, _ := agent.Received()
+		assert.Equal([]string{"a"}, received)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		assert := assert.New(t)
+		dir, err := ioutil.TempDir("", "diskbuffer")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+		agent := newFlakyAgent(flakyResponse{status: 400})
+		defer agent.Close()
+		tracer := newRetryTestTracer(agent)
+		tracer.disk, err = openDiskBuffer(dir, 1<<20)
+		assert.NoError(err)
+
+		tracer.pushPayload([]*span{newBasicSpan("a")})
+		assert.Error(tracer.flushTraces(context.Background()))
+		assert.Equal(0, tracer.disk.len())
+		assert.IsType(&dataLossError{}, <-tracer.errorBuffer)
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		assert := assert.New(t)
+		dir, err := ioutil.TempDir("", "diskbuffer")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+
+		down := newFlakyAgent(flakyResponse{status: 503}, flakyResponse{status: 503})
+		defer down.Close()
+		tracer := newTracer(
+			WithAgentAddr(strings.TrimPrefix(down.URL, "http://")),
+			WithDiskBuffer(dir, 1<<20),
+			WithLogger(new(recordLogger)),
+		)
+		internal.SetGlobalTracer(tracer)
+		tracer.StartSpan("a").Finish()
+		assert.Zero(StopWithTimeout(time.Second))
+		files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+		assert.NoError(err)
+		assert.Len(files, 1)
+
+		up := newFlakyAgent()
+		defer up.Close()
+		tracer = newTracer(
+			WithAgentAddr(strings.TrimPrefix(up.URL, "http://")),
+			WithDiskBuffer(dir, 1<<20),
+			WithFlushInterval(50*time.Millisecond),
+		)
+		defer tracer.Stop()
+		select {
+		case <-up.succeeded:
+		case <-time.After(5 * time.Second):
+			assert.Fail("spooled trace was not sent")
+		}
+		received, _ := up.Received()
+		assert.Equal([]string{"a"}, received)
+	})
+}
@@ -7,6 +7,11 @@
 // 	tracer.Start(tracer.WithAgentAddr("127.0.0.1:1234"))
 // 	defer tracer.Stop()
 //
+// Traces are sent to the agent in the background every few seconds, so short-lived
+// processes should call Flush or StopWithTimeout before exiting. Most options can
+// also be set using the environment variables documented with each of them, which
+// are overridden by the options passed to Start.
+//
 // The tracing client can perform trace sampling. While the trace agent
 // already samples traces to reduce bandwidth usage, client sampling reduces
 // performance overhead. To make use of it, the package comes with a ready-to-use
@@ -15,6 +20,11 @@
 //   s := tracer.NewRateSampler(0.3)
 //   tracer.Start(tracer.WithSampler(s))
 //
+// Finer grained control is possible using NewRulesSampler, and the decision of
+// the sampler can be overridden for a whole trace from any of its spans by calling
+// KeepTrace or DropTrace. Finished traces can also be modified or dropped before
+// being sent using span processors, see WithSpanProcessor.
+//
 // All spans created by the tracer contain a context hereby referred to as the span
 // context. Note that this is different from Go's context. The span context is used
 // to package essential information from a span, which is needed when creating child
@@ -40,6 +50,9 @@
 //  sctx, err := tracer.Extract(tracer.HTTPHeadersCarrier(req.Header))
 //  // ...
 //  span := tracer.StartSpan("child.span", tracer.ChildOf(sctx))
+// By default, the "x-datadog-*" headers are used. The B3 and W3C Trace Context
+// formats are available using NewB3Propagator and NewW3CPropagator, along with the
+// WithPropagator option.
 // In the same manner, any means can be used as a carrier to inject a context into a transport. Go's
 // context can also be used as a means to transport spans within the same process. The methods
 // StartSpanFromContext, ContextWithSpan and SpanFromContext exist for this reason.
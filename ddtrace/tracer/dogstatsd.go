@@ -0,0 +1,116 @@
+package tracer
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDogstatsdAddr specifies the default address of DogStatsD.
+const defaultDogstatsdAddr = "localhost:8125"
+
+// statsdClient represents a client capable of sending metrics to DogStatsD.
+// Implementations must be safe for concurrent use.
+type statsdClient interface {
+	// Count tracks how many times something happened.
+	Count(name string, value int64, tags []string, rate float64) error
+
+	// Gauge measures the value of a metric at a particular time.
+	Gauge(name string, value float64, tags []string, rate float64) error
+
+	// Timing sends timing information.
+	Timing(name string, value time.Duration, tags []string, rate float64) error
+
+	// Close closes the client and its underlying connection.
+	Close() error
+}
+
+var _ statsdClient = (*dogStatsd)(nil)
+
+// dogStatsd is a minimal DogStatsD client sending each metric as a datagram over
+// UDP or a Unix Domain Socket. It does not buffer, nor does it retry: metrics which
+// can not be written are dropped, so that the instrumented program is never blocked.
+type dogStatsd struct {
+	conn    net.Conn
+	tags    []string      // tags added to all metrics
+	timeout time.Duration // write timeout, only used with sockets which can block
+}
+
+// newDogStatsd returns a new client sending metrics to the DogStatsD server found
+// at addr, which is either a host:port pair or a Unix Domain Socket path prefixed
+// with "unix://". The given tags are added to all the metrics.
+func newDogStatsd(addr string, tags []string) (*dogStatsd, error) {
+	network, timeout := "udp", time.Duration(0)
+	if strings.HasPrefix(addr, "unix://") {
+		// writes to a unix datagram socket block when its buffer is full
+		network, timeout = "unixgram", 100*time.Millisecond
+		addr = strings.TrimPrefix(addr, "unix://")
+	}
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &dogStatsd{conn: conn, tags: tags, timeout: timeout}, nil
+}
+
+// Count implements statsdClient.
+func (d *dogStatsd) Count(name string, value int64, tags []string, rate float64) error {
+	return d.send(name, strconv.FormatInt(value, 10), "c", tags, rate)
+}
+
+// Gauge implements statsdClient.
+func (d *dogStatsd) Gauge(name string, value float64, tags []string, rate float64) error {
+	return d.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags, rate)
+}
+
+// Timing implements statsdClient. The duration is sent in milliseconds.
+func (d *dogStatsd) Timing(name string, value time.Duration, tags []string, rate float64) error {
+	ms := float64(value) / float64(time.Millisecond)
+	return d.send(name, strconv.FormatFloat(ms, 'f', 6, 64), "ms", tags, rate)
+}
+
+// Close implements statsdClient.
+func (d *dogStatsd) Close() error {
+	return d.conn.Close()
+}
+
+// send writes a single metric to the connection using the DogStatsD datagram
+// format: <name>:<value>|<type>|@<rate>|#<tag1>,<tag2>
+func (d *dogStatsd) send(name, value, typ string, tags []string, rate float64) error {
+	if rate < 1 && !sampledByRate(random.Uint64(), rate) {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(typ)
+	if rate < 1 {
+		b.WriteString("|@")
+		b.WriteString(strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+	if len(d.tags)+len(tags) > 0 {
+		b.WriteString("|#")
+		writeTags(&b, d.tags, false)
+		writeTags(&b, tags, len(d.tags) > 0)
+	}
+	if d.timeout > 0 {
+		d.conn.SetWriteDeadline(time.Now().Add(d.timeout))
+	}
+	_, err := d.conn.Write([]byte(b.String()))
+	return err
+}
+
+// writeTags writes the given tags to b separated by commas, starting with one
+// when sep is true.
+func writeTags(b *strings.Builder, tags []string, sep bool) {
+	for _, t := range tags {
+		if sep {
+			b.WriteByte(',')
+		}
+		b.WriteString(t)
+		sep = true
+	}
+}
@@ -0,0 +1,109 @@
+package tracer
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStatsdServer is a DogStatsD server listening on a local UDP socket, which
+// records all received datagrams.
+type testStatsdServer struct {
+	conn    net.PacketConn
+	packets chan string
+}
+
+func newTestStatsdServer(t *testing.T) *testStatsdServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &testStatsdServer{conn: conn, packets: make(chan string, 1000)}
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				close(srv.packets)
+				return
+			}
+			srv.packets <- string(buf[:n])
+		}
+	}()
+	return srv
+}
+
+// addr returns the address of the server.
+func (s *testStatsdServer) addr() string { return s.conn.LocalAddr().String() }
+
+// close stops the server.
+func (s *testStatsdServer) close() { s.conn.Close() }
+
+// wait waits for n datagrams to be received, or for the timeout to pass, and
+// returns all the received datagrams.
+func (s *testStatsdServer) wait(n int, timeout time.Duration) []string {
+	var got []string
+	deadline := time.After(timeout)
+	for len(got) < n {
+		select {
+		case p, ok := <-s.packets:
+			if !ok {
+				return got
+			}
+			got = append(got, p)
+		case <-deadline:
+			return got
+		}
+	}
+	return got
+}
+
+// find returns the datagrams having the given metric name.
+func find(packets []string, name string) []string {
+	var found []string
+	for _, p := range packets {
+		if strings.HasPrefix(p, name+":") {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+func TestDogStatsd(t *testing.T) {
+	assert := assert.New(t)
+	srv := newTestStatsdServer(t)
+	defer srv.close()
+
+	client, err := newDogStatsd(srv.addr(), []string{"service:svc", "lang:go"})
+	assert.NoError(err)
+	defer client.Close()
+
+	assert.NoError(client.Count("a.count", 42, nil, 1))
+	assert.NoError(client.Count("a.count", 1, []string{"reason:x"}, 1))
+	assert.NoError(client.Gauge("a.gauge", 0.5, nil, 1))
+	assert.NoError(client.Timing("a.timing", 1500*time.Microsecond, []string{"k:v", "k2:v2"}, 1))
+	assert.NoError(client.Count("sampled.out", 1, nil, 0))
+
+	assert.Equal([]string{
+		"a.count:42|c|#service:svc,lang:go",
+		"a.count:1|c|#service:svc,lang:go,reason:x",
+		"a.gauge:0.5|g|#service:svc,lang:go",
+		"a.timing:1.500000|ms|#service:svc,lang:go,k:v,k2:v2",
+	}, srv.wait(5, 100*time.Millisecond))
+}
+
+func TestDogStatsdNoTags(t *testing.T) {
+	srv := newTestStatsdServer(t)
+	defer srv.close()
+
+	client, err := newDogStatsd(srv.addr(), nil)
+	assert.NoError(t, err)
+	defer client.Close()
+
+	client.Count("a", 1, nil, 1)
+	client.Count("b", 1, []string{"k:v"}, 1)
+	assert.Equal(t, []string{"a:1|c", "b:1|c|#k:v"}, srv.wait(2, time.Second))
+}
@@ -2,8 +2,9 @@ package tracer
 
 import (
 	"fmt"
-	"log"
 	"strconv"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 )
 
 var errorPrefix = fmt.Sprintf("Datadog Tracer Error (%s): ", tracerVersion)
@@ -29,6 +30,23 @@ func (e *dataLossError) Error() string {
 	return fmt.Sprintf("lost traces (count: %d), error: %v", e.count, e.context)
 }
 
+type sendRetryError struct {
+	count   int   // number of items kept for a later retry
+	context error // the error which occurred while sending them
+}
+
+func (e *sendRetryError) Error() string {
+	return fmt.Sprintf("unable to send traces (count: %d), will retry, error: %v", e.count, e.context)
+}
+
+type statsError struct {
+	context error // the error which occurred while sending the stats
+}
+
+func (e *statsError) Error() string {
+	return fmt.Sprintf("unable to send stats, error: %v", e.context)
+}
+
 type errorSummary struct {
 	Count   int
 	Example string
@@ -36,6 +54,12 @@ type errorSummary struct {
 
 func aggregateErrors(errChan <-chan error) map[string]errorSummary {
 	errs := make(map[string]errorSummary, len(errChan))
+	aggregateErrorsInto(errs, errChan)
+	return errs
+}
+
+// aggregateErrorsInto drains errChan, adding a summary of its errors into errs.
+func aggregateErrorsInto(errs map[string]errorSummary, errChan <-chan error) {
 	for {
 		select {
 		case err := <-errChan:
@@ -48,22 +72,20 @@ func aggregateErrors(errChan <-chan error) map[string]errorSummary {
 			summary.Example = err.Error()
 			errs[key] = summary
 		default: // stop when there's no more data
-			return errs
+			return
 		}
 	}
 }
 
-// logErrors logs the errors, preventing log file flooding, when there
-// are many messages, it caps them and shows a quick summary.
-// As of today it only logs using standard golang log package, but
-// later we could send those stats to agent // TODO(ufoot).
-func logErrors(errChan <-chan error) {
-	errs := aggregateErrors(errChan)
+// logErrors logs the given error summaries using l, preventing log file
+// flooding: when there are many messages, it caps them and shows a quick
+// summary of each type of error.
+func logErrors(l ddtrace.Logger, errs map[string]errorSummary) {
 	for _, v := range errs {
 		var repeat string
 		if v.Count > 1 {
 			repeat = " (repeated " + strconv.Itoa(v.Count) + " times)"
 		}
-		log.Println(errorPrefix + v.Example + repeat)
+		l.Error(v.Example + repeat)
 	}
 }
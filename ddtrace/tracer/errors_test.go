@@ -2,6 +2,10 @@ package tracer
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -36,3 +40,71 @@ func TestAggregateErrors(t *testing.T) {
 		},
 	}, errs)
 }
+
+// recordLogger is a ddtrace.Logger which records all messages.
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) Debug(msg string) { l.log("DEBUG: " + msg) }
+func (l *recordLogger) Info(msg string)  { l.log("INFO: " + msg) }
+func (l *recordLogger) Warn(msg string)  { l.log("WARN: " + msg) }
+func (l *recordLogger) Error(msg string) { l.log("ERROR: " + msg) }
+
+func (l *recordLogger) log(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, msg)
+}
+
+func (l *recordLogger) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func TestLogErrorsRateLimited(t *testing.T) {
+	assert := assert.New(t)
+	l := new(recordLogger)
+	tracer := newTracerChannels()
+	tracer.config = &config{logger: l}
+
+	for i := 0; i < defaultErrorBufferSize; i++ {
+		tracer.pushError(&dataLossError{count: i})
+	}
+	// a flush was requested as the buffer filled up, it only aggregates
+	<-tracer.flushErrorsReq
+	tracer.aggregateErrors()
+	for i := 0; i < 10; i++ {
+		tracer.pushError(&traceEncodingError{context: errors.New("bad")})
+	}
+	assert.Len(l.Lines(), 0)
+
+	tracer.flushErrors()
+	lines := l.Lines()
+	sort.Strings(lines)
+	assert.Equal([]string{
+		"ERROR: error encoding trace: bad (repeated 10 times)",
+		fmt.Sprintf("ERROR: lost traces (count: %d), error: <nil> (repeated %d times)", defaultErrorBufferSize-1, defaultErrorBufferSize),
+	}, lines)
+
+	// nothing left to log
+	tracer.flushErrors()
+	assert.Len(l.Lines(), 2)
+}
+
+func TestTracerLogger(t *testing.T) {
+	assert := assert.New(t)
+	l := new(recordLogger)
+	tracer, _, stop := startTestTracer(WithLogger(l), WithDebugMode(true))
+	defer stop()
+
+	tracer.StartSpan("op").Finish()
+	tracer.forceFlush()
+	lines := l.Lines()
+	if assert.Len(lines, 1) {
+		assert.True(strings.HasPrefix(lines[0], "DEBUG: Sending payload: size: "))
+		assert.True(strings.HasSuffix(lines[0], " traces: 1 partial: 0"))
+	}
+}
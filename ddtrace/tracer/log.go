@@ -0,0 +1,40 @@
+package tracer
+
+import (
+	"fmt"
+	"log"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+)
+
+var _ ddtrace.Logger = defaultLogger{}
+
+// defaultLogger is the logger used by the tracer when none was provided using
+// WithLogger. It writes to the standard library's logger, prefixing warnings and
+// errors so that they can be identified as coming from the tracer.
+type defaultLogger struct{}
+
+// Debug implements ddtrace.Logger.
+func (defaultLogger) Debug(msg string) { log.Print(msg) }
+
+// Info implements ddtrace.Logger.
+func (defaultLogger) Info(msg string) { log.Print(msg) }
+
+// Warn implements ddtrace.Logger.
+func (defaultLogger) Warn(msg string) { log.Print(errorPrefix + msg) }
+
+// Error implements ddtrace.Logger.
+func (defaultLogger) Error(msg string) { log.Print(errorPrefix + msg) }
+
+// debugf logs a debug message formatted using fmt.Sprintf, only when the tracer
+// is in debug mode.
+func (t *tracer) debugf(format string, a ...interface{}) {
+	if t.config.debug {
+		t.config.logger.Debug(fmt.Sprintf(format, a...))
+	}
+}
+
+// warnf logs a warning formatted using fmt.Sprintf.
+func (c *config) warnf(format string, a ...interface{}) {
+	c.logger.Warn(fmt.Sprintf(format, a...))
+}
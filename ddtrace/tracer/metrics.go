@@ -0,0 +1,212 @@
+package tracer
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// healthMetricsInterval specifies the interval at which the tracer's health
+// metrics are reported.
+var healthMetricsInterval = 10 * time.Second
+
+// Names of the metrics reported about the tracer's health.
+const (
+	metricSpansStarted    = "datadog.tracer.spans.started"
+	metricSpansFinished   = "datadog.tracer.spans.finished"
+	metricSpansDropped    = "datadog.tracer.spans.dropped"
+	metricSpansOpen       = "datadog.tracer.spans.open"
+	metricTracesEnqueued  = "datadog.tracer.traces.enqueued"
+	metricTracesDropped   = "datadog.tracer.traces.dropped"
+	metricPartialFlushes  = "datadog.tracer.traces.partial_flushes"
+	metricTracesAbandoned = "datadog.tracer.traces.abandoned"
+	metricQueueDepth      = "datadog.tracer.queue.depth"
+	metricFlushTraces     = "datadog.tracer.flush.traces"
+	metricFlushBytes      = "datadog.tracer.flush.bytes"
+	metricFlushDuration   = "datadog.tracer.flush.duration"
+	metricFlushErrors     = "datadog.tracer.flush.errors"
+)
+
+// Tags describing the reason for which traces were dropped, reported along
+// with metricTracesDropped.
+const (
+	dropReasonQueueFull     = "reason:queue_full"
+	dropReasonTraceTooLarge = "reason:trace_too_large"
+	dropReasonSendFailed    = "reason:send_failed"
+	dropReasonEncoding      = "reason:encoding_error"
+	dropReasonProcessor     = "reason:span_processor"
+	dropReasonRetryFull     = "reason:retry_buffer_full"
+	dropReasonRetryExpired  = "reason:retry_expired"
+	dropReasonDiskFull      = "reason:disk_buffer_full"
+	dropReasonDiskError     = "reason:disk_buffer_error"
+)
+
+// healthStats holds counters tracking the health of the tracer, which are
+// periodically reported and reset. All fields are accessed atomically.
+type healthStats struct {
+	spansStarted           uint64
+	spansFinished          uint64
+	tracesEnqueued         uint64
+	tracesDroppedQueueFull uint64
+	tracesDroppedTooLarge  uint64
+	tracesDroppedProcessor uint64
+	spansDroppedProcessor  uint64
+}
+
+// statsTags returns the tags which are added to all metrics reported by a
+// tracer with the given configuration.
+func statsTags(c *config) []string {
+	tags := []string{
+		"service:" + c.serviceName,
+		"lang:go",
+		"lang_version:" + strings.TrimPrefix(runtime.Version(), "go"),
+		"tracer_version:" + tracerVersion,
+	}
+	global := make([]string, 0, len(c.globalTags))
+	for k, v := range c.globalTags {
+		global = append(global, k+":"+fmt.Sprint(v))
+	}
+	sort.Strings(global)
+	return append(tags, global...)
+}
+
+// reportHealthMetrics reports the health metrics of the tracer at the given
+// interval, until the tracer is stopped.
+func (t *tracer) reportHealthMetrics(interval time.Duration) {
+	defer t.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.reportHealthStats()
+		case <-t.stopped:
+			t.reportHealthStats()
+			return
+		}
+	}
+}
+
+// reportHealthStats sends and resets the counters accumulated since the last
+// report, along with gauges describing the current state of the tracer.
+func (t *tracer) reportHealthStats() {
+	s, stats := &t.health, t.config.statsd
+	stats.Count(metricSpansStarted, int64(atomic.SwapUint64(&s.spansStarted, 0)), nil, 1)
+	stats.Count(metricSpansFinished, int64(atomic.SwapUint64(&s.spansFinished, 0)), nil, 1)
+	stats.Count(metricTracesEnqueued, int64(atomic.SwapUint64(&s.tracesEnqueued, 0)), nil, 1)
+	if n := atomic.SwapUint64(&s.tracesDroppedQueueFull, 0); n > 0 {
+		stats.Count(metricTracesDropped, int64(n), []string{dropReasonQueueFull}, 1)
+	}
+	if n := atomic.SwapUint64(&s.tracesDroppedTooLarge, 0); n > 0 {
+		stats.Count(metricTracesDropped, int64(n), []string{dropReasonTraceTooLarge}, 1)
+	}
+	if n := atomic.SwapUint64(&s.tracesDroppedProcessor, 0); n > 0 {
+		stats.Count(metricTracesDropped, int64(n), []string{dropReasonProcessor}, 1)
+	}
+	if n := atomic.SwapUint64(&s.spansDroppedProcessor, 0); n > 0 {
+		stats.Count(metricSpansDropped, int64(n), []string{dropReasonProcessor}, 1)
+	}
+	stats.Gauge(metricQueueDepth, float64(len(t.payloadQueue)), nil, 1)
+	if t.openSpans != nil {
+		spans, abandoned := t.openSpans.counts(time.Now(), t.config.spanTimeout)
+		stats.Gauge(metricSpansOpen, float64(spans), nil, 1)
+		stats.Gauge(metricTracesAbandoned, float64(abandoned), nil, 1)
+	}
+}
+
+// runtimeMetricsInterval specifies the interval at which runtime metrics are
+// collected and reported.
+var runtimeMetricsInterval = 10 * time.Second
+
+// reportRuntimeMetrics collects and reports metrics about the Go runtime at the
+// given interval, until the tracer is stopped.
+func (t *tracer) reportRuntimeMetrics(interval time.Duration) {
+	defer t.wg.Done()
+	var ms runtime.MemStats
+	gc := debug.GCStats{
+		// When len(stats.PauseQuantiles) is 5, it will be filled with the
+		// minimum, 25%, 50%, 75%, and maximum pause times. See the documentation
+		// for (runtime/debug).ReadGCStats.
+		PauseQuantiles: make([]time.Duration, 5),
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			runtime.ReadMemStats(&ms)
+			debug.ReadGCStats(&gc)
+			t.sendRuntimeMetrics(&ms, &gc)
+		case <-t.stopped:
+			return
+		}
+	}
+}
+
+// sendRuntimeMetrics sends the given runtime statistics to DogStatsD.
+func (t *tracer) sendRuntimeMetrics(ms *runtime.MemStats, gc *debug.GCStats) {
+	stats := t.config.statsd
+
+	// CPU and goroutines
+	stats.Gauge("runtime.go.num_cpu", float64(runtime.NumCPU()), nil, 1)
+	stats.Gauge("runtime.go.num_goroutine", float64(runtime.NumGoroutine()), nil, 1)
+	stats.Gauge("runtime.go.num_cgo_call", float64(runtime.NumCgoCall()), nil, 1)
+
+	// general memory statistics
+	stats.Gauge("runtime.go.mem_stats.alloc", float64(ms.Alloc), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.total_alloc", float64(ms.TotalAlloc), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.sys", float64(ms.Sys), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.lookups", float64(ms.Lookups), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.mallocs", float64(ms.Mallocs), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.frees", float64(ms.Frees), nil, 1)
+
+	// heap memory statistics
+	stats.Gauge("runtime.go.mem_stats.heap_alloc", float64(ms.HeapAlloc), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.heap_sys", float64(ms.HeapSys), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.heap_idle", float64(ms.HeapIdle), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.heap_inuse", float64(ms.HeapInuse), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.heap_released", float64(ms.HeapReleased), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.heap_objects", float64(ms.HeapObjects), nil, 1)
+
+	// stack memory statistics
+	stats.Gauge("runtime.go.mem_stats.stack_inuse", float64(ms.StackInuse), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.stack_sys", float64(ms.StackSys), nil, 1)
+
+	// off-heap memory statistics
+	stats.Gauge("runtime.go.mem_stats.m_span_inuse", float64(ms.MSpanInuse), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.m_span_sys", float64(ms.MSpanSys), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.m_cache_inuse", float64(ms.MCacheInuse), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.m_cache_sys", float64(ms.MCacheSys), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.buck_hash_sys", float64(ms.BuckHashSys), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.gc_sys", float64(ms.GCSys), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.other_sys", float64(ms.OtherSys), nil, 1)
+
+	// garbage collector statistics
+	stats.Gauge("runtime.go.mem_stats.next_gc", float64(ms.NextGC), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.last_gc", float64(ms.LastGC), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.pause_total_ns", float64(ms.PauseTotalNs), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.num_gc", float64(ms.NumGC), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.num_forced_gc", float64(ms.NumForcedGC), nil, 1)
+	stats.Gauge("runtime.go.mem_stats.gc_cpu_fraction", ms.GCCPUFraction, nil, 1)
+	for i, p := range []string{"min", "25p", "50p", "75p", "max"} {
+		stats.Gauge("runtime.go.gc_stats.pause_quantiles."+p, float64(gc.PauseQuantiles[i]), nil, 1)
+	}
+
+	// scheduler statistics, when available
+	for p, v := range schedLatencies() {
+		stats.Gauge("runtime.go.sched.latency."+p, float64(v), nil, 1)
+	}
+}
+
+// noopStatsd is a statsdClient which discards all metrics. It is used when
+// health metrics are disabled.
+type noopStatsd struct{}
+
+func (noopStatsd) Count(_ string, _ int64, _ []string, _ float64) error          { return nil }
+func (noopStatsd) Gauge(_ string, _ float64, _ []string, _ float64) error        { return nil }
+func (noopStatsd) Timing(_ string, _ time.Duration, _ []string, _ float64) error { return nil }
+func (noopStatsd) Close() error                                                  { return nil }
@@ -0,0 +1,58 @@
+// +build go1.17
+
+package tracer
+
+import (
+	"math"
+	"runtime/metrics"
+	"time"
+)
+
+// schedLatencyMetric is the name of the runtime metric holding the distribution
+// of the time goroutines have spent in the scheduler in a runnable state before
+// actually running.
+const schedLatencyMetric = "/sched/latencies:seconds"
+
+// schedLatencies returns the 50th, 95th and 99th percentiles, as well as the
+// maximum, of the scheduling latency of goroutines since the program started.
+func schedLatencies() map[string]time.Duration {
+	sample := []metrics.Sample{{Name: schedLatencyMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+		// not supported by this version of Go
+		return nil
+	}
+	h := sample[0].Value.Float64Histogram()
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total == 0 {
+		return nil
+	}
+	lat := make(map[string]time.Duration, 4)
+	for p, q := range map[string]float64{"50p": 0.5, "95p": 0.95, "99p": 0.99, "max": 1} {
+		lat[p] = histogramQuantile(h, total, q)
+	}
+	return lat
+}
+
+// histogramQuantile returns an estimation of the q-quantile of the given histogram
+// having total counts, as the upper boundary of the bucket which holds it.
+func histogramQuantile(h *metrics.Float64Histogram, total uint64, q float64) time.Duration {
+	rank := uint64(math.Ceil(q * float64(total)))
+	var n uint64
+	for i, c := range h.Counts {
+		n += c
+		if n < rank || c == 0 {
+			continue
+		}
+		// bucket i spans [h.Buckets[i], h.Buckets[i+1])
+		upper := h.Buckets[i+1]
+		if math.IsInf(upper, 1) {
+			upper = h.Buckets[i]
+		}
+		return time.Duration(upper * float64(time.Second))
+	}
+	return 0
+}
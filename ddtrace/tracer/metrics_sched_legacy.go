@@ -0,0 +1,9 @@
+// +build !go1.17
+
+package tracer
+
+import "time"
+
+// schedLatencies returns nil, as scheduling latencies are not available with
+// versions of Go prior to 1.17.
+func schedLatencies() map[string]time.Duration { return nil }
@@ -0,0 +1,30 @@
+// +build go1.17
+
+package tracer
+
+import (
+	"math"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	assert := assert.New(t)
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{0, 50, 45, 4, 1},
+		Buckets: []float64{0, 0.001, 0.002, 0.003, 0.004, math.Inf(1)},
+	}
+	assert.Equal(2*time.Millisecond, histogramQuantile(h, 100, 0.5))
+	assert.Equal(3*time.Millisecond, histogramQuantile(h, 100, 0.95))
+	assert.Equal(4*time.Millisecond, histogramQuantile(h, 100, 0.99))
+	assert.Equal(4*time.Millisecond, histogramQuantile(h, 100, 1), "infinite bucket uses its lower bound")
+}
+
+func TestSchedLatencies(t *testing.T) {
+	for p, v := range schedLatencies() {
+		assert.True(t, v >= 0, p)
+	}
+}
@@ -0,0 +1,152 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingTransport is a transport which always fails to send payloads.
+type failingTransport struct{}
+
+func (failingTransport) send(_ context.Context, _ *payload) (io.ReadCloser, error) {
+	return nil, errors.New("agent unavailable")
+}
+
+func (failingTransport) sendStats(_ context.Context, _ *statsPayload) error {
+	return errors.New("agent unavailable")
+}
+
+func TestHealthMetrics(t *testing.T) {
+	assert := assert.New(t)
+	srv := newTestStatsdServer(t)
+	defer srv.close()
+
+	tracer, _, stop := startTestTracer(
+		WithServiceName("health-svc"),
+		WithDogstatsdAddress(srv.addr()),
+	)
+	root := tracer.newRootSpan("root", "svc", "res")
+	tracer.newChildSpan("child", root).Finish()
+	root.Finish()
+	tracer.newRootSpan("unfinished", "svc", "res")
+	tracer.forceFlush()
+	stop()
+
+	packets := srv.wait(100, 200*time.Millisecond)
+	for _, p := range packets {
+		assert.Contains(p, "|#service:health-svc,lang:go,")
+	}
+	assert.Equal([]string{"1"}, values(find(packets, metricFlushTraces)))
+	assert.Len(find(packets, metricFlushBytes), 1)
+	assert.Len(find(packets, metricFlushDuration), 1)
+	assert.Equal([]string{"3"}, values(find(packets, metricSpansStarted)))
+	assert.Equal([]string{"2"}, values(find(packets, metricSpansFinished)))
+	assert.Equal([]string{"1"}, values(find(packets, metricTracesEnqueued)))
+	assert.Equal([]string{"0"}, values(find(packets, metricQueueDepth)))
+	assert.Len(find(packets, metricTracesDropped), 0)
+}
+
+func TestHealthMetricsDropped(t *testing.T) {
+	assert := assert.New(t)
+	srv := newTestStatsdServer(t)
+	defer srv.close()
+	client, err := newDogStatsd(srv.addr(), nil)
+	assert.NoError(err)
+	defer client.Close()
+
+	tracer := newTracerChannels()
+	tracer.config = &config{statsd: client, transport: failingTransport{}}
+	tracer.payloadQueue = make(chan []*span, 1)
+
+	for i := 0; i < 3; i++ {
+		tracer.pushTrace([]*span{newBasicSpan("trace")})
+	}
+	tracer.reportHealthStats()
+	tracer.pushPayload(<-tracer.payloadQueue)
+	tracer.flushTraces(context.Background())
+
+	packets := srv.wait(100, 200*time.Millisecond)
+	assert.Equal([]string{"1"}, values(find(packets, metricTracesEnqueued)))
+	assert.Equal([]string{
+		metricTracesDropped + ":2|c|#" + dropReasonQueueFull,
+		metricTracesDropped + ":1|c|#" + dropReasonSendFailed,
+	}, find(packets, metricTracesDropped))
+	assert.Equal([]string{"1"}, values(find(packets, metricFlushErrors)))
+}
+
+func TestHealthMetricsDisabled(t *testing.T) {
+	tracer := newTracer()
+	defer tracer.Stop()
+	assert.Equal(t, noopStatsd{}, tracer.config.statsd)
+}
+
+// values returns the values of the given datagrams.
+func values(packets []string) []string {
+	var vals []string
+	for _, p := range packets {
+		p = p[strings.Index(p, ":")+1:]
+		vals = append(vals, p[:strings.Index(p, "|")])
+	}
+	return vals
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	assert := assert.New(t)
+	srv := newTestStatsdServer(t)
+	defer srv.close()
+
+	old := runtimeMetricsInterval
+	runtimeMetricsInterval = 10 * time.Millisecond
+	defer func() { runtimeMetricsInterval = old }()
+
+	tracer := newTracer(
+		WithServiceName("runtime-svc"),
+		WithGlobalTag("env", "test"),
+		WithDogstatsdAddress(srv.addr()),
+		WithRuntimeMetrics(),
+	)
+	var packets []string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		packets = append(packets, srv.wait(1, 10*time.Millisecond)...)
+		if len(find(packets, "runtime.go.gc_stats.pause_quantiles.max")) > 0 {
+			break
+		}
+	}
+	tracer.Stop()
+
+	for _, name := range []string{
+		"runtime.go.num_goroutine",
+		"runtime.go.mem_stats.heap_alloc",
+		"runtime.go.mem_stats.num_gc",
+		"runtime.go.gc_stats.pause_quantiles.50p",
+	} {
+		found := find(packets, name)
+		if assert.NotEmpty(found, name) {
+			assert.Contains(found[0], "|g|#service:runtime-svc,")
+			assert.True(strings.HasSuffix(found[0], ",env:test"), found[0])
+		}
+	}
+}
+
+func TestRuntimeMetricsDefaultAddr(t *testing.T) {
+	tracer := newTracer(WithRuntimeMetrics())
+	defer tracer.Stop()
+	assert.Equal(t, defaultDogstatsdAddr, tracer.config.dogstatsdAddr)
+	_, ok := tracer.config.statsd.(*dogStatsd)
+	assert.True(t, ok)
+}
+
+func TestStatsTags(t *testing.T) {
+	c := &config{serviceName: "svc"}
+	WithGlobalTag("version", 1.2)(c)
+	WithGlobalTag("env", "prod")(c)
+	tags := statsTags(c)
+	assert.Equal(t, "service:svc", tags[0])
+	assert.Equal(t, []string{"env:prod", "version:1.2"}, tags[len(tags)-2:])
+}
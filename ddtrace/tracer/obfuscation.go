@@ -0,0 +1,140 @@
+package tracer
+
+import (
+	"regexp"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/obfuscate"
+)
+
+// redactedValue replaces the values, or the parts of values, which are
+// redacted.
+const redactedValue = "?"
+
+// Obfuscator identifies a built-in obfuscator which removes sensitive values
+// from the tags that integrations set on spans. Obfuscators are enabled using
+// WithObfuscation.
+type Obfuscator int
+
+const (
+	// ObfuscateSQL replaces the literals found in the SQL and CQL queries of
+	// database spans, in their resource and in the "sql.query",
+	// "cassandra.query" and "db.statement" tags.
+	ObfuscateSQL Obfuscator = iota
+
+	// ObfuscateRedis replaces the values of the arguments of the Redis
+	// commands found in the "redis.raw_command" tag.
+	ObfuscateRedis
+
+	// ObfuscateJSON replaces the values of the JSON bodies found in the
+	// "elasticsearch.body" and "mongodb.query" tags, keeping their keys.
+	ObfuscateJSON
+
+	// ObfuscateQueryString replaces the values of the query string parameters
+	// of the URLs found in the "http.url" tag.
+	ObfuscateQueryString
+)
+
+// obfuscatorNames maps the names which can be used with the DD_TRACE_OBFUSCATION
+// environment variable to their obfuscator.
+var obfuscatorNames = map[string]Obfuscator{
+	"sql":          ObfuscateSQL,
+	"redis":        ObfuscateRedis,
+	"json":         ObfuscateJSON,
+	"query_string": ObfuscateQueryString,
+}
+
+// RedactionRule specifies span tags whose values are redacted before being
+// sent to the agent. A rule applies to the string tags having a key matching
+// Key, or to all of them when Key is nil. When Value is nil, the whole value
+// of these tags is redacted, otherwise only the parts matching Value are.
+type RedactionRule struct {
+	// Key specifies the pattern which the keys of redacted tags must match.
+	Key *regexp.Regexp
+
+	// Value specifies the pattern matching the redacted parts of the values.
+	Value *regexp.Regexp
+}
+
+// KeyRedactionRule returns a RedactionRule which redacts the whole value of
+// the tags having a key matching the given glob pattern, e.g. "*.password".
+func KeyRedactionRule(key string) RedactionRule {
+	return RedactionRule{Key: GlobPattern(key)}
+}
+
+// ValueRedactionRule returns a RedactionRule which redacts the parts of all
+// tag values matching the given regular expression.
+func ValueRedactionRule(value *regexp.Regexp) RedactionRule {
+	return RedactionRule{Value: value}
+}
+
+// obfuscationConfig holds the obfuscators and redaction rules applied to the
+// spans of finished traces.
+type obfuscationConfig struct {
+	obfuscators map[Obfuscator]bool
+	rules       []RedactionRule
+}
+
+// enabled reports whether any obfuscation is configured.
+func (o *obfuscationConfig) enabled() bool {
+	return len(o.obfuscators) > 0 || len(o.rules) > 0
+}
+
+// obfuscate applies the configured obfuscators and redaction rules to the spans
+// of trace. It is called by the worker, before the trace is encoded.
+func (o *obfuscationConfig) obfuscate(trace []*span) {
+	if !o.enabled() {
+		return
+	}
+	for _, s := range trace {
+		if o.obfuscatesSQL(s) {
+			s.Resource = obfuscate.SQL(s.Resource)
+			obfuscateTags(s, obfuscate.SQL, ext.SQLQuery, ext.CassandraQuery, ext.DBStatement)
+		}
+		if o.obfuscators[ObfuscateRedis] {
+			obfuscateTags(s, obfuscate.Redis, "redis.raw_command")
+		}
+		if o.obfuscators[ObfuscateJSON] {
+			obfuscateTags(s, obfuscate.JSON, "elasticsearch.body", "mongodb.query")
+		}
+		if o.obfuscators[ObfuscateQueryString] {
+			obfuscateTags(s, obfuscate.QueryString, ext.HTTPURL)
+		}
+		for _, r := range o.rules {
+			for k, v := range s.Meta {
+				if r.Key != nil && !r.Key.MatchString(k) {
+					continue
+				}
+				if r.Value == nil {
+					s.Meta[k] = redactedValue
+				} else {
+					s.Meta[k] = r.Value.ReplaceAllLiteralString(v, redactedValue)
+				}
+			}
+		}
+	}
+}
+
+// obfuscatesSQL reports whether the resource and query tags of s are obfuscated
+// as SQL queries.
+func (o *obfuscationConfig) obfuscatesSQL(s *span) bool {
+	return o.obfuscators[ObfuscateSQL] && (s.Type == ext.SpanTypeSQL || s.Type == ext.SpanTypeCassandra)
+}
+
+// resource returns the resource of s as it is sent to the agent, once obfuscated.
+// The receiver may be nil, in which case nothing is obfuscated.
+func (o *obfuscationConfig) resource(s *span) string {
+	if o != nil && o.obfuscatesSQL(s) {
+		return obfuscate.SQL(s.Resource)
+	}
+	return s.Resource
+}
+
+// obfuscateTags replaces the values of the given tags of s using fn.
+func obfuscateTags(s *span, fn func(string) string, keys ...string) {
+	for _, k := range keys {
+		if v, ok := s.Meta[k]; ok {
+			s.Meta[k] = fn(v)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package tracer
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+func TestObfuscation(t *testing.T) {
+	t.Run("obfuscators", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(
+			WithObfuscation(ObfuscateSQL, ObfuscateRedis, ObfuscateJSON, ObfuscateQueryString),
+		)
+		defer stop()
+
+		const query = "SELECT * FROM users WHERE email = 'a@b.c'"
+		tracer.StartSpan("mysql.query", SpanType(ext.SpanTypeSQL), ResourceName(query), Tag(ext.SQLQuery, query)).Finish()
+		tracer.StartSpan("cassandra.query", SpanType(ext.SpanTypeCassandra), ResourceName("SELECT * FROM t WHERE id = 1")).Finish()
+		tracer.StartSpan("redis.command", SpanType(ext.SpanTypeRedis), Tag("redis.raw_command", "SET key secret")).Finish()
+		tracer.StartSpan("elasticsearch.query", Tag("elasticsearch.body", `{"query":{"term":{"user":"kimchy"}}}`)).Finish()
+		tracer.StartSpan("http.request", Tag(ext.HTTPURL, "/search?q=secret")).Finish()
+		// the resource of non-database spans is kept
+		tracer.StartSpan("web.request", ResourceName("GET /users/'1'")).Finish()
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		if !assert.Len(traces, 6) {
+			return
+		}
+		assert.Equal("SELECT * FROM users WHERE email = ?", traces[0][0].Resource)
+		assert.Equal("SELECT * FROM users WHERE email = ?", traces[0][0].Meta[ext.SQLQuery])
+		assert.Equal("SELECT * FROM t WHERE id = ?", traces[1][0].Resource)
+		assert.Equal("SET key ?", traces[2][0].Meta["redis.raw_command"])
+		assert.Equal(`{"query":{"term":{"user":"?"}}}`, traces[3][0].Meta["elasticsearch.body"])
+		assert.Equal("/search?q=?", traces[4][0].Meta[ext.HTTPURL])
+		assert.Equal("GET /users/'1'", traces[5][0].Resource)
+	})
+
+	t.Run("rules", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(
+			WithRedaction(KeyRedactionRule("*.password")),
+			WithRedaction(ValueRedactionRule(regexp.MustCompile(`\d{4}-\d{4}-\d{4}-\d{4}`))),
+		)
+		defer stop()
+
+		tracer.StartSpan("op",
+			Tag("db.password", "hunter2"),
+			Tag("password", "kept"),
+			Tag("payment", "card 1234-5678-9012-3456 used"),
+		).Finish()
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		if assert.Len(traces, 1) {
+			meta := traces[0][0].Meta
+			assert.Equal("?", meta["db.password"])
+			assert.Equal("kept", meta["password"])
+			assert.Equal("card ? used", meta["payment"])
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer()
+		defer stop()
+
+		tracer.StartSpan("redis.command", SpanType(ext.SpanTypeRedis), Tag("redis.raw_command", "SET key secret")).Finish()
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		if assert.Len(traces, 1) {
+			assert.Equal("SET key secret", traces[0][0].Meta["redis.raw_command"])
+		}
+	})
+}
+
+func TestObfuscationEnv(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		assert := assert.New(t)
+		os.Setenv("DD_TRACE_OBFUSCATION", "SQL, query_string")
+		defer os.Unsetenv("DD_TRACE_OBFUSCATION")
+		os.Setenv("DD_TRACE_REDACTED_TAGS", "*.password, token")
+		defer os.Unsetenv("DD_TRACE_REDACTED_TAGS")
+		os.Setenv("DD_TRACE_REDACTION_PATTERN", `\d+`)
+		defer os.Unsetenv("DD_TRACE_REDACTION_PATTERN")
+
+		c := newConfig(WithObfuscation(ObfuscateRedis))
+		assert.Equal(map[Obfuscator]bool{
+			ObfuscateSQL:         true,
+			ObfuscateQueryString: true,
+			ObfuscateRedis:       true,
+		}, c.obfuscation.obfuscators)
+		if assert.Len(c.obfuscation.rules, 3) {
+			assert.True(c.obfuscation.rules[0].Key.MatchString("db.password"))
+			assert.True(c.obfuscation.rules[1].Key.MatchString("token"))
+			assert.Nil(c.obfuscation.rules[2].Key)
+			assert.Equal(`\d+`, c.obfuscation.rules[2].Value.String())
+		}
+	})
+
+	t.Run("all", func(t *testing.T) {
+		os.Setenv("DD_TRACE_OBFUSCATION", "all")
+		defer os.Unsetenv("DD_TRACE_OBFUSCATION")
+		c := newConfig()
+		assert.Len(t, c.obfuscation.obfuscators, len(obfuscatorNames))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert := assert.New(t)
+		os.Setenv("DD_TRACE_OBFUSCATION", "sql,xml")
+		defer os.Unsetenv("DD_TRACE_OBFUSCATION")
+		os.Setenv("DD_TRACE_REDACTION_PATTERN", `(`)
+		defer os.Unsetenv("DD_TRACE_REDACTION_PATTERN")
+		l := new(recordLogger)
+		c := newConfig(WithLogger(l))
+		assert.Equal(map[Obfuscator]bool{ObfuscateSQL: true}, c.obfuscation.obfuscators)
+		assert.Len(c.obfuscation.rules, 0)
+		lines := l.Lines()
+		if assert.Len(lines, 2) {
+			assert.Equal(`WARN: DD_TRACE_OBFUSCATION: ignoring unknown obfuscator "xml"`, lines[0])
+			assert.Contains(lines[1], `WARN: DD_TRACE_REDACTION_PATTERN: ignoring invalid regular expression "("`)
+		}
+	})
+}
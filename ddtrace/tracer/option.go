@@ -1,9 +1,15 @@
 package tracer
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
@@ -15,16 +21,35 @@ type config struct {
 	// debug, when true, writes details to logs.
 	debug bool
 
+	// logger specifies the logger used for all of the tracer's output.
+	logger ddtrace.Logger
+
+	// enabled reports whether tracing is enabled. It can only be disabled
+	// using the DD_TRACE_ENABLED environment variable.
+	enabled bool
+
+	// warnings holds messages about invalid environment variables found by
+	// defaults, which are logged once the configured logger is known.
+	warnings []string
+
 	// serviceName specifies the name of this application.
 	serviceName string
 
 	// sampler specifies the sampler that will be used for sampling traces.
 	sampler Sampler
 
-	// agentAddr specifies the hostname and  of the agent where the traces
+	// agentAddr specifies the hostname and port of the agent where the traces
 	// are sent to.
 	agentAddr string
 
+	// agentAddrSet reports whether agentAddr was set using WithAgentAddr, in
+	// which case the agent's Unix Domain Socket is not detected.
+	agentAddrSet bool
+
+	// agentSocket specifies the path to the Unix Domain Socket of the agent.
+	// When set, it takes precedence over agentAddr.
+	agentSocket string
+
 	// globalTags holds a set of tags that will be automatically applied to
 	// all spans.
 	globalTags map[string]interface{}
@@ -37,26 +62,593 @@ type config struct {
 
 	// httpRoundTripper defines the http.RoundTripper used by the agent transport.
 	httpRoundTripper http.RoundTripper
+
+	// prioritySampling, when true, enables distributed priority sampling using
+	// the sampling rates returned by the agent.
+	prioritySampling bool
+
+	// dogstatsdAddr specifies the address of DogStatsD, to which the tracer
+	// reports metrics about its health. Metrics are disabled when empty.
+	dogstatsdAddr string
+
+	// statsd is the client used to report metrics. When nil, it is created
+	// using dogstatsdAddr.
+	statsd statsdClient
+
+	// runtimeMetrics, when true, enables the collection of Go runtime metrics.
+	runtimeMetrics bool
+
+	// obfuscation holds the obfuscators and redaction rules applied to
+	// finished traces.
+	obfuscation obfuscationConfig
+
+	// spanProcessors holds the processors which are applied to finished traces,
+	// in order.
+	spanProcessors []SpanProcessor
+
+	// traceID128 specifies whether 128-bit trace IDs are generated.
+	traceID128 bool
+
+	// partialFlushMinSpans specifies the number of finished spans a trace
+	// must have to be partially flushed. Partial flushing is disabled when
+	// it is zero.
+	partialFlushMinSpans int
+
+	// flushInterval specifies the interval at which the payload is flushed.
+	flushInterval time.Duration
+
+	// payloadSizeLimit specifies the size which the payload must exceed to be
+	// flushed before the end of the flush interval. It is at most payloadMaxLimit.
+	payloadSizeLimit int
+
+	// payloadQueueSize specifies the number of finished traces which can wait
+	// to be added to the payload. Traces are dropped when the queue is full.
+	payloadQueueSize int
+
+	// errorBufferSize specifies the number of errors which can wait to be
+	// aggregated and logged. Errors are dropped when the buffer is full.
+	errorBufferSize int
+
+	// httpTimeout specifies the timeout of the requests sent to the agent.
+	httpTimeout time.Duration
+
+	// retryBufferSize specifies the maximum total size in bytes of the payloads
+	// which failed to be sent and are kept to be retried. Failed payloads are
+	// dropped when it is zero.
+	retryBufferSize int
+
+	// retryMaxAge specifies the maximum time during which a payload which failed
+	// to be sent is retried.
+	retryMaxAge time.Duration
+
+	// diskBufferDir specifies the directory where payloads which could not be
+	// sent are spooled. They are not spooled when it is empty.
+	diskBufferDir string
+
+	// diskBufferSize specifies the maximum total size in bytes of the payloads
+	// spooled to disk.
+	diskBufferSize int
+
+	// statsComputation specifies whether the tracer computes the stats of the
+	// traces, rather than the agent.
+	statsComputation bool
+
+	// analyticsRate specifies the default rate at which the spans of the
+	// integrations are marked as APM events, or NaN if analytics are disabled.
+	analyticsRate float64
+
+	// debugAbandonedSpans, when true, enables tracking of the spans which are
+	// started but not finished, to log the traces which are open for longer
+	// than spanTimeout.
+	debugAbandonedSpans bool
+
+	// spanTimeout specifies the time after which a trace which still has open
+	// spans is reported as abandoned.
+	spanTimeout time.Duration
 }
 
 // StartOption represents a function that can be provided as a parameter to Start.
 type StartOption func(*config)
 
-// defaults sets the default values for a config.
+// newConfig returns the configuration resulting from applying the given options
+// on top of the defaults and the environment. Warnings about invalid environment
+// variables are logged using the configured logger.
+func newConfig(opts ...StartOption) *config {
+	c := new(config)
+	defaults(c)
+	for _, fn := range opts {
+		fn(c)
+	}
+	if c.logger == nil {
+		c.logger = defaultLogger{}
+	}
+	for _, msg := range c.warnings {
+		c.logger.Warn(msg)
+	}
+	c.warnings = nil
+	return c
+}
+
+// defaults sets the default values for a config. Values found in the environment
+// replace the built-in defaults, and are in turn replaced by any explicitly given
+// StartOption.
 func defaults(c *config) {
 	c.serviceName = filepath.Base(os.Args[0])
 	c.sampler = NewAllSampler()
 	c.agentAddr = defaultAddress
+	c.logger = defaultLogger{}
+	c.enabled = true
+	c.flushInterval = defaultFlushInterval
+	c.payloadSizeLimit = defaultPayloadSizeLimit
+	c.payloadQueueSize = defaultPayloadQueueSize
+	c.errorBufferSize = defaultErrorBufferSize
+	c.httpTimeout = defaultHTTPTimeout
+	c.retryBufferSize = defaultRetryBufferSize
+	c.retryMaxAge = defaultRetryMaxAge
+	c.analyticsRate = math.NaN()
+	c.spanTimeout = defaultSpanTimeout
+
+	if v := os.Getenv("DD_SERVICE"); v != "" {
+		c.serviceName = v
+	}
+	if v := os.Getenv("DD_TAGS"); v != "" {
+		for _, tag := range strings.Split(v, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+			kv := strings.SplitN(tag, ":", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				c.envWarnf("DD_TAGS: ignoring invalid tag %q, expected key:value", tag)
+				continue
+			}
+			WithGlobalTag(kv[0], kv[1])(c)
+		}
+	}
+	if v := os.Getenv("DD_ENV"); v != "" {
+		WithGlobalTag(ext.Environment, v)(c)
+	}
+	if v := os.Getenv("DD_VERSION"); v != "" {
+		WithGlobalTag(ext.Version, v)(c)
+	}
+	if v := os.Getenv("DD_TRACE_SAMPLE_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			c.envWarnf("DD_TRACE_SAMPLE_RATE: ignoring invalid value %q, expected a number between 0 and 1", v)
+		} else {
+			c.sampler = NewRateSampler(rate)
+		}
+	}
+	c.enabled = c.boolEnv("DD_TRACE_ENABLED", c.enabled)
+	c.debug = c.boolEnv("DD_TRACE_DEBUG", c.debug)
+	c.traceID128 = c.boolEnv("DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED", c.traceID128)
+	c.flushInterval = c.durationEnv("DD_TRACE_FLUSH_INTERVAL", c.flushInterval)
+	c.payloadSizeLimit = c.intEnv("DD_TRACE_PAYLOAD_SIZE_LIMIT", c.payloadSizeLimit, payloadMaxLimit)
+	c.payloadQueueSize = c.intEnv("DD_TRACE_PAYLOAD_QUEUE_SIZE", c.payloadQueueSize, 0)
+	c.errorBufferSize = c.intEnv("DD_TRACE_ERROR_BUFFER_SIZE", c.errorBufferSize, 0)
+	c.httpTimeout = c.durationEnv("DD_TRACE_HTTP_TIMEOUT", c.httpTimeout)
+	c.retryBufferSize = c.intEnv("DD_TRACE_RETRY_BUFFER_SIZE", c.retryBufferSize, 0)
+	c.retryMaxAge = c.durationEnv("DD_TRACE_RETRY_MAX_AGE", c.retryMaxAge)
+	c.statsComputation = c.boolEnv("DD_TRACE_STATS_COMPUTATION_ENABLED", c.statsComputation)
+	c.debugAbandonedSpans = c.boolEnv("DD_TRACE_DEBUG_ABANDONED_SPANS", c.debugAbandonedSpans)
+	c.spanTimeout = c.durationEnv("DD_TRACE_ABANDONED_SPAN_TIMEOUT", c.spanTimeout)
+	if c.boolEnv("DD_TRACE_ANALYTICS_ENABLED", false) {
+		c.analyticsRate = 1.0
+	}
+	inject := c.propagationStyleEnv("DD_PROPAGATION_STYLE_INJECT")
+	extract := c.propagationStyleEnv("DD_PROPAGATION_STYLE_EXTRACT")
+	if inject != nil || extract != nil {
+		if inject == nil {
+			inject = []Propagator{NewPropagator(nil)}
+		}
+		if extract == nil {
+			extract = []Propagator{NewPropagator(nil)}
+		}
+		c.propagator = NewChainedPropagator(inject, extract)
+	}
+	if v := os.Getenv("DD_TRACE_OBFUSCATION"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "all" {
+				for _, o := range obfuscatorNames {
+					WithObfuscation(o)(c)
+				}
+				continue
+			}
+			if o, ok := obfuscatorNames[name]; ok {
+				WithObfuscation(o)(c)
+			} else if name != "" {
+				c.envWarnf("DD_TRACE_OBFUSCATION: ignoring unknown obfuscator %q", name)
+			}
+		}
+	}
+	if v := os.Getenv("DD_TRACE_REDACTED_TAGS"); v != "" {
+		for _, key := range strings.Split(v, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				WithRedaction(KeyRedactionRule(key))(c)
+			}
+		}
+	}
+	if v := os.Getenv("DD_TRACE_REDACTION_PATTERN"); v != "" {
+		if re, err := regexp.Compile(v); err != nil {
+			c.envWarnf("DD_TRACE_REDACTION_PATTERN: ignoring invalid regular expression %q: %v", v, err)
+		} else {
+			WithRedaction(ValueRedactionRule(re))(c)
+		}
+	}
+	if v := os.Getenv("DD_TRACE_AGENT_URL"); v != "" {
+		u, err := url.Parse(v)
+		switch {
+		case err != nil:
+			c.envWarnf("DD_TRACE_AGENT_URL: ignoring invalid URL %q: %v", v, err)
+		case u.Scheme == "unix":
+			c.agentSocket = u.Path
+		case u.Scheme == "http" && u.Host != "":
+			c.agentAddr = u.Host
+		default:
+			c.envWarnf("DD_TRACE_AGENT_URL: ignoring URL %q, the scheme must be http or unix", v)
+		}
+	}
+}
+
+// propagationStyleEnv returns the propagators of the comma separated list of
+// styles found in the environment variable key, or nil when none are valid.
+func (c *config) propagationStyleEnv(key string) []Propagator {
+	var ps []Propagator
+	for _, style := range strings.Split(os.Getenv(key), ",") {
+		style = strings.ToLower(strings.TrimSpace(style))
+		if style == "" {
+			continue
+		}
+		fn, ok := propagatorStyles[style]
+		if !ok {
+			c.envWarnf("%s: ignoring unknown propagation style %q", key, style)
+			continue
+		}
+		ps = append(ps, fn())
+	}
+	return ps
+}
+
+// boolEnv returns the boolean value of the environment variable key, or def
+// when it is not set or invalid.
+func (c *config) boolEnv(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		c.envWarnf("%s: ignoring invalid value %q, expected a boolean", key, v)
+		return def
+	}
+	return b
+}
+
+// intEnv returns the value of the environment variable key, which must be a
+// positive integer no greater than max, unless max is zero. It returns def
+// when the variable is not set or invalid.
+func (c *config) intEnv(key string, def, max int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || (max > 0 && n > max) {
+		if max > 0 {
+			c.envWarnf("%s: ignoring invalid value %q, expected an integer between 1 and %d", key, v, max)
+		} else {
+			c.envWarnf("%s: ignoring invalid value %q, expected a positive integer", key, v)
+		}
+		return def
+	}
+	return n
+}
+
+// durationEnv returns the value of the environment variable key, which must be
+// a positive duration such as "500ms" or "2s", or def when it is not set or
+// invalid.
+func (c *config) durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		c.envWarnf("%s: ignoring invalid value %q, expected a positive duration such as \"2s\"", key, v)
+		return def
+	}
+	return d
+}
+
+// envWarnf records a warning about the environment formatted using fmt.Sprintf,
+// to be logged by newConfig.
+func (c *config) envWarnf(format string, a ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, a...))
+}
+
+// globalTag returns the value of the global tag with the given key as a string,
+// or an empty string if it is not set.
+func (c *config) globalTag(key string) string {
+	if v, ok := c.globalTags[key]; ok {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// resolveAgentSocket detects whether the agent's Unix Domain Socket should be used
+// when neither a socket nor an address were configured.
+func resolveAgentSocket(c *config) {
+	if c.agentSocket != "" || c.agentAddrSet || c.httpRoundTripper != nil {
+		// explicitly configured
+		return
+	}
+	if os.Getenv("DD_AGENT_HOST") != "" || os.Getenv("DD_TRACE_AGENT_PORT") != "" || os.Getenv("DD_TRACE_AGENT_URL") != "" {
+		// configured through the environment
+		return
+	}
+	if _, err := os.Stat(defaultSocketAPM); err == nil {
+		c.agentSocket = defaultSocketAPM
+	}
 }
 
 // WithDebugMode enables debug mode on the tracer, resulting in more verbose logging.
+// It can also be enabled using the DD_TRACE_DEBUG environment variable.
 func WithDebugMode(enabled bool) StartOption {
 	return func(c *config) {
 		c.debug = enabled
 	}
 }
 
-// WithPropagator sets an alternative propagator to be used by the tracer.
+// WithDebugSpansMode enables tracking of the spans which are started but never
+// finished, for example when a call to Finish is missed on an error path, which
+// prevents their whole trace from ever being sent. The traces which have been open
+// for longer than timeout are logged once as warnings, listing their unfinished spans
+// along with the file:line where each of them was started, and all the spans still
+// open are logged when the tracer stops. The number of open spans and abandoned
+// traces are also reported as health metrics. The default timeout is 10 minutes,
+// and non-positive values keep it. It can also be enabled using the
+// DD_TRACE_DEBUG_ABANDONED_SPANS environment variable, with the timeout set by
+// DD_TRACE_ABANDONED_SPAN_TIMEOUT, e.g. "5m". Tracking spans adds overhead to
+// starting and finishing them, so it is meant for debugging.
+func WithDebugSpansMode(timeout time.Duration) StartOption {
+	return func(c *config) {
+		c.debugAbandonedSpans = true
+		if timeout > 0 {
+			c.spanTimeout = timeout
+		}
+	}
+}
+
+// WithLogger sets l as the logger used for all of the tracer's output, such as
+// errors, warnings and debug messages. Errors are aggregated so that at most one
+// line per type of error is logged at each flush interval. By default, the
+// standard library's logger is used.
+func WithLogger(l ddtrace.Logger) StartOption {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// WithObfuscation enables the given built-in obfuscators, which remove sensitive
+// values from the tags set by integrations, such as the literals of SQL queries.
+// Obfuscators can also be enabled using the DD_TRACE_OBFUSCATION environment
+// variable, e.g. "sql,redis,json,query_string" or "all". Obfuscation is applied
+// on the worker goroutine, after span processors and before traces are encoded.
+func WithObfuscation(obfuscators ...Obfuscator) StartOption {
+	return func(c *config) {
+		if c.obfuscation.obfuscators == nil {
+			c.obfuscation.obfuscators = make(map[Obfuscator]bool)
+		}
+		for _, o := range obfuscators {
+			c.obfuscation.obfuscators[o] = true
+		}
+	}
+}
+
+// WithRedaction adds the given rules to the redaction rules applied to the string
+// tags of spans before they are sent to the agent. This option may be used multiple
+// times. Rules can also be added using the DD_TRACE_REDACTED_TAGS environment variable,
+// holding comma separated glob patterns of tag keys, and DD_TRACE_REDACTION_PATTERN,
+// holding a regular expression matching the redacted parts of all tag values.
+func WithRedaction(rules ...RedactionRule) StartOption {
+	return func(c *config) {
+		c.obfuscation.rules = append(c.obfuscation.rules, rules...)
+	}
+}
+
+// WithSpanProcessor adds fn to the processors which are applied to finished traces
+// before they are sent to the agent, allowing spans to be modified, for example to
+// scrub sensitive tags, or dropped. This option may be used multiple times, in which
+// case processors are applied in order, until one drops the trace. The number of
+// spans and traces dropped by processors is reported in the health metrics. They
+// are still counted in the stats computed by the tracer, see WithStatsComputation.
+func WithSpanProcessor(fn SpanProcessor) StartOption {
+	return func(c *config) {
+		c.spanProcessors = append(c.spanProcessors, fn)
+	}
+}
+
+// With128BitTraceIDs enables the generation of 128-bit trace IDs for new traces.
+// The lower 64 bits are used as the trace ID of spans, while the upper 64 bits are
+// reported in a tag and propagated to other services. The full ID is returned by
+// the TraceID128 method of ddtrace.SpanContextW3C. It can also be enabled using the
+// DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED environment variable.
+func With128BitTraceIDs() StartOption {
+	return func(c *config) {
+		c.traceID128 = true
+	}
+}
+
+// WithPrioritySampling enables priority sampling on the active tracer. Root spans
+// which pass the client-side sampler are assigned a sampling priority based on
+// per-service rates computed by the agent, and the priority is propagated to all
+// descendant spans, including those in other processes. Traces are still sent to
+// the agent regardless of their priority, allowing it to compute accurate statistics.
+func WithPrioritySampling() StartOption {
+	return func(c *config) {
+		c.prioritySampling = true
+	}
+}
+
+// WithPartialFlushing enables partial flushing of traces. When a trace which has
+// not yet completed has at least minSpans finished spans, these are sent to the
+// agent without waiting for the rest of the trace. This is useful for long-running
+// or very large traces, which would otherwise be held in memory until their root
+// span finishes, or dropped altogether when reaching the maximum trace size.
+func WithPartialFlushing(minSpans int) StartOption {
+	return func(c *config) {
+		if minSpans > 0 {
+			c.partialFlushMinSpans = minSpans
+		}
+	}
+}
+
+// WithFlushInterval sets the interval at which finished traces are sent to the
+// agent. The default is 2 seconds. Shorter intervals reduce the delay before
+// traces are available and the memory used to buffer them, at the cost of more
+// requests. It can also be set using the DD_TRACE_FLUSH_INTERVAL environment
+// variable, e.g. "500ms". Non-positive values are ignored.
+func WithFlushInterval(d time.Duration) StartOption {
+	return func(c *config) {
+		if d > 0 {
+			c.flushInterval = d
+		}
+	}
+}
+
+// WithPayloadSizeLimit sets the size in bytes above which the buffered traces
+// are sent to the agent without waiting for the end of the flush interval. The
+// default is about 4.75 MB, and it can not exceed the 9.5 MB accepted by the agent.
+// It can also be set using the DD_TRACE_PAYLOAD_SIZE_LIMIT environment variable.
+// Values which are not positive or which exceed the maximum are ignored.
+func WithPayloadSizeLimit(bytes int) StartOption {
+	return func(c *config) {
+		if bytes > 0 && bytes <= payloadMaxLimit {
+			c.payloadSizeLimit = bytes
+		}
+	}
+}
+
+// WithPayloadQueueSize sets the number of finished traces which can be queued
+// while the tracer is busy, e.g. sending a payload. Traces finished while the
+// queue is full are dropped. The default is 1000, which can be increased for
+// services finishing large bursts of traces. It can also be set using the
+// DD_TRACE_PAYLOAD_QUEUE_SIZE environment variable. Non-positive values are ignored.
+func WithPayloadQueueSize(n int) StartOption {
+	return func(c *config) {
+		if n > 0 {
+			c.payloadQueueSize = n
+		}
+	}
+}
+
+// WithErrorBufferSize sets the number of errors which can be buffered before
+// being aggregated and logged. Errors occurring while the buffer is full are
+// dropped. The default is 200. It can also be set using the DD_TRACE_ERROR_BUFFER_SIZE
+// environment variable. Non-positive values are ignored.
+func WithErrorBufferSize(n int) StartOption {
+	return func(c *config) {
+		if n > 0 {
+			c.errorBufferSize = n
+		}
+	}
+}
+
+// WithHTTPTimeout sets the timeout of the requests sending traces to the agent.
+// The default is 1 second. It can also be set using the DD_TRACE_HTTP_TIMEOUT
+// environment variable, e.g. "2s". It has no effect when a custom transport is
+// used. Non-positive values are ignored.
+func WithHTTPTimeout(d time.Duration) StartOption {
+	return func(c *config) {
+		if d > 0 {
+			c.httpTimeout = d
+		}
+	}
+}
+
+// WithRetryBuffer configures how payloads are retried when they could not be sent
+// to the agent, for example while it restarts. Such payloads are kept in memory,
+// up to maxBytes in total and for at most maxAge, and are retried with exponential
+// backoff on later flushes, before any new traces. Payloads rejected by the agent
+// are not retried, unless it responded with 429 Too Many Requests or a 5xx status,
+// in which case its Retry-After header is honoured. The oldest payloads are dropped
+// when the buffer is full. The default is 16 MB and one minute, and a zero maxBytes
+// disables retries. They can also be set using the DD_TRACE_RETRY_BUFFER_SIZE and
+// DD_TRACE_RETRY_MAX_AGE environment variables. Negative values are ignored, as is
+// a zero maxAge.
+func WithRetryBuffer(maxBytes int, maxAge time.Duration) StartOption {
+	return func(c *config) {
+		if maxBytes >= 0 {
+			c.retryBufferSize = maxBytes
+		}
+		if maxAge > 0 {
+			c.retryMaxAge = maxAge
+		}
+	}
+}
+
+// WithDiskBuffer enables spooling the payloads which could not be sent to the
+// agent to files in the given directory, so that traces survive agent outages
+// which last longer than the retry buffer allows, as well as process restarts.
+// Payloads are spooled when they are dropped from the retry buffer, or when
+// failing to be sent if retries are disabled, and the ones which are still
+// pending when the tracer stops. A background goroutine sends the spooled
+// payloads in order as soon as the agent responds again. The oldest payloads
+// are dropped once maxBytes is reached. Each tracer should use its own
+// directory. Disabled by default.
+func WithDiskBuffer(dir string, maxBytes int) StartOption {
+	return func(c *config) {
+		if dir != "" && maxBytes > 0 {
+			c.diskBufferDir = dir
+			c.diskBufferSize = maxBytes
+		}
+	}
+}
+
+// WithDogstatsdAddress enables reporting metrics about the tracer's health, such
+// as the number of started spans or dropped traces, to the DogStatsD server found
+// at addr. The address is either a host and port (e.g. "localhost:8125") or the path
+// of a Unix Domain Socket prefixed with "unix://". An empty address uses the default,
+// "localhost:8125".
+func WithDogstatsdAddress(addr string) StartOption {
+	return func(c *config) {
+		if addr == "" {
+			addr = defaultDogstatsdAddr
+		}
+		c.dogstatsdAddr = addr
+	}
+}
+
+// WithRuntimeMetrics enables the periodic collection of metrics about the Go runtime,
+// such as the number of goroutines, heap usage and garbage collection pauses. The
+// metrics are sent to DogStatsD, at the address given with WithDogstatsdAddress or
+// at "localhost:8125", and are tagged with the service name and global tags.
+func WithRuntimeMetrics() StartOption {
+	return func(c *config) {
+		c.runtimeMetrics = true
+	}
+}
+
+// WithStatsComputation enables computing the stats of the traces, such as the
+// number of hits and errors and the latency distributions, in the tracer rather
+// than in the agent. The stats of all traces are computed, including the ones
+// which are dropped by the sampler, so that they are accurate regardless of the
+// sampling rate. It can also be enabled using the DD_TRACE_STATS_COMPUTATION_ENABLED
+// environment variable. It requires a version of the agent which supports
+// receiving stats. Disabled by default.
+func WithStatsComputation(enabled bool) StartOption {
+	return func(c *config) {
+		c.statsComputation = enabled
+	}
+}
+
+// WithPropagator sets an alternative propagator to be used by the tracer. Use
+// NewChainedPropagator to propagate several formats at once. It overrides the
+// propagation styles set using the DD_PROPAGATION_STYLE_INJECT and
+// DD_PROPAGATION_STYLE_EXTRACT environment variables, which take a comma
+// separated list of the styles "datadog" (the default), "b3" or "b3multi",
+// "b3 single header" and "tracecontext", e.g. "datadog,tracecontext".
 func WithPropagator(p Propagator) StartOption {
 	return func(c *config) {
 		c.propagator = p
@@ -64,6 +656,7 @@ func WithPropagator(p Propagator) StartOption {
 }
 
 // WithServiceName sets the default service name to be used with the tracer.
+// It can also be set using the DD_SERVICE environment variable.
 func WithServiceName(name string) StartOption {
 	return func(c *config) {
 		c.serviceName = name
@@ -71,15 +664,35 @@ func WithServiceName(name string) StartOption {
 }
 
 // WithAgentAddr sets the address where the agent is located. The default is
-// localhost:8126. It should contain both host and port.
+// localhost:8126. It should contain both host and port. It can also be set using
+// the DD_TRACE_AGENT_URL environment variable, e.g. "http://10.0.0.1:8126", and
+// the DD_AGENT_HOST and DD_TRACE_AGENT_PORT environment variables override the
+// host and port of the address.
 func WithAgentAddr(addr string) StartOption {
 	return func(c *config) {
 		c.agentAddr = addr
+		c.agentAddrSet = true
+		c.agentSocket = ""
+	}
+}
+
+// WithUDS configures the tracer to connect to the agent using the Unix Domain
+// Socket found at socketPath. It overrides any previously given WithAgentAddr
+// and takes precedence over WithHTTPRoundTripper. By default, the socket found
+// at "/var/run/datadog/apm.socket" is used when it exists and no other address
+// was configured. The socket can also be set using the DD_TRACE_AGENT_URL
+// environment variable, e.g. "unix:///var/run/datadog/apm.socket".
+func WithUDS(socketPath string) StartOption {
+	return func(c *config) {
+		c.agentSocket = socketPath
 	}
 }
 
 // WithGlobalTag sets a key/value pair which will be set as a tag on all spans
-// created by tracer. This option may be used multiple times.
+// created by tracer. This option may be used multiple times. Global tags can also
+// be set using the DD_TAGS environment variable, e.g. "team:apm,region:eu", and
+// the "env" and "version" tags using DD_ENV and DD_VERSION, which take precedence
+// over DD_TAGS.
 func WithGlobalTag(k string, v interface{}) StartOption {
 	return func(c *config) {
 		if c.globalTags == nil {
@@ -90,7 +703,8 @@ func WithGlobalTag(k string, v interface{}) StartOption {
 }
 
 // WithSampler sets the given sampler to be used with the tracer. By default
-// an all-permissive sampler is used.
+// an all-permissive sampler is used. A RateSampler can also be set using the
+// DD_TRACE_SAMPLE_RATE environment variable, between 0 and 1.
 func WithSampler(s Sampler) StartOption {
 	return func(c *config) {
 		c.sampler = s
@@ -98,14 +712,41 @@ func WithSampler(s Sampler) StartOption {
 }
 
 // WithHTTPRoundTripper allows customizing the underlying HTTP transport for
-// emitting spans. This is useful for advanced customization such as emitting
-// spans to a unix domain socket. The default should be used in most cases.
+// emitting spans. This is useful for advanced customization such as using custom
+// proxies or certificates. To connect to the agent over a unix domain socket,
+// prefer WithUDS. The default should be used in most cases.
 func WithHTTPRoundTripper(r http.RoundTripper) StartOption {
 	return func(c *config) {
 		c.httpRoundTripper = r
 	}
 }
 
+// WithAnalytics enables trace analytics for all the integrations, so that all
+// of their spans are marked as APM events, unless they are configured otherwise.
+// It can also be enabled using the DD_TRACE_ANALYTICS_ENABLED environment variable.
+func WithAnalytics(on bool) StartOption {
+	return func(c *config) {
+		if on {
+			c.analyticsRate = 1.0
+		} else {
+			c.analyticsRate = math.NaN()
+		}
+	}
+}
+
+// WithAnalyticsRate sets the rate at which the spans of all the integrations are
+// marked as APM events, between 0 and 1, unless they are configured otherwise.
+// Invalid rates disable analytics.
+func WithAnalyticsRate(rate float64) StartOption {
+	return func(c *config) {
+		if rate >= 0 && rate <= 1 {
+			c.analyticsRate = rate
+		} else {
+			c.analyticsRate = math.NaN()
+		}
+	}
+}
+
 // StartSpanOption is a configuration option for StartSpan. It is aliased in order
 // to help godoc group all the functions returning it together. It is considered
 // more correct to refer to it as the type as the origin, ddtrace.StartSpanOption.
@@ -1,9 +1,17 @@
 package tracer
 
 import (
+	"math"
+	"net/http"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 )
 
 func withTransport(t transport) StartOption {
@@ -20,6 +28,17 @@ func TestTracerOptionsDefaults(t *testing.T) {
 	assert.Equal("tracer.test", c.serviceName)
 	assert.Equal("localhost:8126", c.agentAddr)
 	assert.Equal(nil, c.httpRoundTripper)
+	assert.False(c.prioritySampling)
+	assert.Equal(defaultLogger{}, c.logger)
+	assert.True(c.enabled)
+	assert.Equal(defaultFlushInterval, c.flushInterval)
+	assert.EqualValues(defaultPayloadSizeLimit, c.payloadSizeLimit)
+	assert.Equal(defaultPayloadQueueSize, c.payloadQueueSize)
+	assert.Equal(defaultErrorBufferSize, c.errorBufferSize)
+	assert.Equal(defaultHTTPTimeout, c.httpTimeout)
+	assert.Equal(defaultRetryBufferSize, c.retryBufferSize)
+	assert.Equal(defaultRetryMaxAge, c.retryMaxAge)
+	assert.True(math.IsNaN(c.analyticsRate))
 }
 
 func TestTracerOptions(t *testing.T) {
@@ -30,7 +49,20 @@ func TestTracerOptions(t *testing.T) {
 		WithAgentAddr("ddagent.consul.local:58126"),
 		WithGlobalTag("k", "v"),
 		WithDebugMode(true),
+		WithPrioritySampling(),
+		WithPartialFlushing(100),
+		WithLogger(new(recordLogger)),
+		With128BitTraceIDs(),
+		WithFlushInterval(time.Second),
+		WithPayloadSizeLimit(1024),
+		WithPayloadQueueSize(5000),
+		WithErrorBufferSize(10),
+		WithHTTPTimeout(3*time.Second),
+		WithRetryBuffer(1<<20, 10*time.Second),
+		WithStatsComputation(true),
+		WithAnalyticsRate(0.4),
 	)
+	defer tracer.Stop()
 	c := tracer.config
 	assert.Equal(float64(0.5), c.sampler.(RateSampler).Rate())
 	assert.Equal("api-intake", c.serviceName)
@@ -38,4 +70,269 @@ func TestTracerOptions(t *testing.T) {
 	assert.NotNil(c.globalTags)
 	assert.Equal("v", c.globalTags["k"])
 	assert.True(c.debug)
+	assert.True(c.prioritySampling)
+	assert.Equal(100, c.partialFlushMinSpans)
+	assert.IsType(new(recordLogger), c.logger)
+	assert.True(c.traceID128)
+	assert.Equal(time.Second, c.flushInterval)
+	assert.Equal(1024, c.payloadSizeLimit)
+	assert.Equal(5000, cap(tracer.payloadQueue))
+	assert.Equal(10, cap(tracer.errorBuffer))
+	assert.Equal(3*time.Second, c.transport.(*httpTransport).client.Timeout)
+	assert.Equal(1<<20, tracer.retries.maxSize)
+	assert.Equal(10*time.Second, tracer.retries.maxAge)
+	assert.NotNil(tracer.stats)
+	assert.Equal("yes", c.transport.(*httpTransport).headers[computedStatsHeader])
+	assert.Equal(0.4, c.analyticsRate)
+	assert.Equal(0.4, globalconfig.AnalyticsRate())
+
+	t.Run("invalid", func(t *testing.T) {
+		c := newConfig(
+			WithFlushInterval(0),
+			WithPayloadSizeLimit(payloadMaxLimit+1),
+			WithPayloadQueueSize(-1),
+			WithErrorBufferSize(0),
+			WithHTTPTimeout(-time.Second),
+			WithRetryBuffer(-1, 0),
+			WithAnalyticsRate(2),
+		)
+		assert.Equal(defaultFlushInterval, c.flushInterval)
+		assert.EqualValues(defaultPayloadSizeLimit, c.payloadSizeLimit)
+		assert.Equal(defaultPayloadQueueSize, c.payloadQueueSize)
+		assert.Equal(defaultErrorBufferSize, c.errorBufferSize)
+		assert.Equal(defaultHTTPTimeout, c.httpTimeout)
+		assert.Equal(defaultRetryBufferSize, c.retryBufferSize)
+		assert.Equal(defaultRetryMaxAge, c.retryMaxAge)
+		assert.True(math.IsNaN(c.analyticsRate))
+	})
+
+	t.Run("analytics", func(t *testing.T) {
+		assert.Equal(1.0, newConfig(WithAnalytics(true)).analyticsRate)
+		assert.True(math.IsNaN(newConfig(WithAnalyticsRate(0.5), WithAnalytics(false)).analyticsRate))
+	})
+
+	t.Run("no-retry", func(t *testing.T) {
+		c := newConfig(WithRetryBuffer(0, time.Minute))
+		assert.Zero(c.retryBufferSize)
+	})
+
+	t.Run("disk-buffer", func(t *testing.T) {
+		c := newConfig(WithDiskBuffer("/var/spool/ddtrace", 1<<30))
+		assert.Equal("/var/spool/ddtrace", c.diskBufferDir)
+		assert.Equal(1<<30, c.diskBufferSize)
+		c = newConfig(WithDiskBuffer("/var/spool/ddtrace", 0))
+		assert.Equal("", c.diskBufferDir)
+	})
+}
+
+func TestTracerOptionsUDS(t *testing.T) {
+	t.Run("option", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer := newTracer(WithUDS("/tmp/agent.sock"))
+		defer tracer.Stop()
+		assert.Equal("/tmp/agent.sock", tracer.config.agentSocket)
+		tr, ok := tracer.config.transport.(*httpTransport)
+		assert.True(ok)
+		assert.Equal("http://UDS__tmp_agent_sock/v0.4/traces", tr.traceURL)
+	})
+
+	t.Run("override", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer := newTracer(WithUDS("/tmp/agent.sock"), WithAgentAddr("host:1234"))
+		defer tracer.Stop()
+		assert.Equal("", tracer.config.agentSocket)
+		assert.Equal("http://host:1234/v0.4/traces", tracer.config.transport.(*httpTransport).traceURL)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		os.Setenv("DD_TRACE_AGENT_URL", "unix:///var/run/agent.sock")
+		defer os.Unsetenv("DD_TRACE_AGENT_URL")
+		var c config
+		defaults(&c)
+		assert.Equal(t, "/var/run/agent.sock", c.agentSocket)
+	})
+
+	t.Run("detect", func(t *testing.T) {
+		assert := assert.New(t)
+		var hits int
+		socketPath, cleanup := newUDSAgent(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+		}))
+		defer cleanup()
+		old := defaultSocketAPM
+		defaultSocketAPM = socketPath
+		defer func() { defaultSocketAPM = old }()
+
+		tracer := newTracer()
+		defer tracer.Stop()
+		assert.Equal(socketPath, tracer.config.agentSocket)
+		tracer.pushPayload([]*span{newBasicSpan("uds.test")})
+		tracer.forceFlush()
+		assert.Equal(1, hits)
+
+		// an explicit address disables detection
+		tracer2 := newTracer(WithAgentAddr("localhost:1234"))
+		defer tracer2.Stop()
+		assert.Equal("", tracer2.config.agentSocket)
+
+		// even when it is the default address
+		tracer3 := newTracer(WithAgentAddr(defaultAddress))
+		defer tracer3.Stop()
+		assert.Equal("", tracer3.config.agentSocket)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		old := defaultSocketAPM
+		defaultSocketAPM = "/this/path/does/not/exist.socket"
+		defer func() { defaultSocketAPM = old }()
+
+		var c config
+		defaults(&c)
+		resolveAgentSocket(&c)
+		assert.Equal(t, "", c.agentSocket)
+	})
+}
+
+func TestTracerOptionsEnv(t *testing.T) {
+	setenv := func(env map[string]string) {
+		for k, v := range env {
+			os.Setenv(k, v)
+		}
+	}
+	unsetenv := func(env map[string]string) {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+
+	t.Run("values", func(t *testing.T) {
+		env := map[string]string{
+			"DD_SERVICE":           "svc",
+			"DD_ENV":               "prod",
+			"DD_VERSION":           "1.2.3",
+			"DD_TAGS":              "team:apm, env:staging,url:http://x",
+			"DD_TRACE_SAMPLE_RATE": "0.25",
+			"DD_TRACE_ENABLED":     "false",
+			"DD_TRACE_DEBUG":       "true",
+			"DD_TRACE_AGENT_URL":   "http://10.0.0.1:1234",
+
+			"DD_TRACE_FLUSH_INTERVAL":     "500ms",
+			"DD_TRACE_PAYLOAD_SIZE_LIMIT": "1048576",
+			"DD_TRACE_PAYLOAD_QUEUE_SIZE": "10000",
+			"DD_TRACE_ERROR_BUFFER_SIZE":  "50",
+			"DD_TRACE_HTTP_TIMEOUT":       "5s",
+			"DD_TRACE_RETRY_BUFFER_SIZE":  "1048576",
+			"DD_TRACE_RETRY_MAX_AGE":      "30s",
+
+			"DD_TRACE_STATS_COMPUTATION_ENABLED": "true",
+			"DD_TRACE_ANALYTICS_ENABLED":         "true",
+			"DD_TRACE_DEBUG_ABANDONED_SPANS":     "true",
+			"DD_TRACE_ABANDONED_SPAN_TIMEOUT":    "5m",
+
+			"DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED": "true",
+		}
+		setenv(env)
+		defer unsetenv(env)
+		assert := assert.New(t)
+		l := new(recordLogger)
+		c := newConfig(WithLogger(l))
+		assert.Equal("svc", c.serviceName)
+		assert.Equal(map[string]interface{}{
+			"env":     "prod",
+			"version": "1.2.3",
+			"team":    "apm",
+			"url":     "http://x",
+		}, c.globalTags)
+		assert.Equal(0.25, c.sampler.(RateSampler).Rate())
+		assert.False(c.enabled)
+		assert.True(c.debug)
+		assert.Equal("10.0.0.1:1234", c.agentAddr)
+		assert.True(c.traceID128)
+		assert.Equal(500*time.Millisecond, c.flushInterval)
+		assert.Equal(1048576, c.payloadSizeLimit)
+		assert.Equal(10000, c.payloadQueueSize)
+		assert.Equal(50, c.errorBufferSize)
+		assert.Equal(5*time.Second, c.httpTimeout)
+		assert.Equal(1048576, c.retryBufferSize)
+		assert.Equal(30*time.Second, c.retryMaxAge)
+		assert.True(c.statsComputation)
+		assert.Equal(1.0, c.analyticsRate)
+		assert.True(c.debugAbandonedSpans)
+		assert.Equal(5*time.Minute, c.spanTimeout)
+		assert.Len(l.Lines(), 0)
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		env := map[string]string{
+			"DD_SERVICE":           "svc",
+			"DD_ENV":               "prod",
+			"DD_TRACE_SAMPLE_RATE": "0.25",
+			"DD_TRACE_DEBUG":       "true",
+			"DD_TRACE_AGENT_URL":   "unix:///tmp/agent.sock",
+		}
+		setenv(env)
+		defer unsetenv(env)
+		assert := assert.New(t)
+		c := newConfig(
+			WithServiceName("api"),
+			WithGlobalTag(ext.Environment, "dev"),
+			WithSampler(NewRateSampler(0.5)),
+			WithDebugMode(false),
+			WithAgentAddr("localhost:9000"),
+		)
+		assert.Equal("api", c.serviceName)
+		assert.Equal("dev", c.globalTags[ext.Environment])
+		assert.Equal(0.5, c.sampler.(RateSampler).Rate())
+		assert.False(c.debug)
+		assert.Equal("localhost:9000", c.agentAddr)
+		assert.Equal("", c.agentSocket)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		env := map[string]string{
+			"DD_TAGS":              "team:apm,invalid",
+			"DD_TRACE_SAMPLE_RATE": "2",
+			"DD_TRACE_ENABLED":     "nope",
+			"DD_TRACE_DEBUG":       "yes",
+			"DD_TRACE_AGENT_URL":   "ftp://localhost",
+
+			"DD_TRACE_FLUSH_INTERVAL":     "2",
+			"DD_TRACE_PAYLOAD_SIZE_LIMIT": "10485760",
+			"DD_TRACE_PAYLOAD_QUEUE_SIZE": "0",
+			"DD_TRACE_HTTP_TIMEOUT":       "-1s",
+		}
+		setenv(env)
+		defer unsetenv(env)
+		assert := assert.New(t)
+		l := new(recordLogger)
+		c := newConfig(WithLogger(l))
+		assert.Equal(map[string]interface{}{"team": "apm"}, c.globalTags)
+		assert.Equal(1.0, c.sampler.(RateSampler).Rate())
+		assert.True(c.enabled)
+		assert.False(c.debug)
+		assert.Equal(defaultAddress, c.agentAddr)
+		assert.Equal(defaultFlushInterval, c.flushInterval)
+		assert.EqualValues(defaultPayloadSizeLimit, c.payloadSizeLimit)
+		assert.Equal(defaultPayloadQueueSize, c.payloadQueueSize)
+		assert.Equal(defaultHTTPTimeout, c.httpTimeout)
+		assert.Equal([]string{
+			`WARN: DD_TAGS: ignoring invalid tag "invalid", expected key:value`,
+			`WARN: DD_TRACE_SAMPLE_RATE: ignoring invalid value "2", expected a number between 0 and 1`,
+			`WARN: DD_TRACE_ENABLED: ignoring invalid value "nope", expected a boolean`,
+			`WARN: DD_TRACE_DEBUG: ignoring invalid value "yes", expected a boolean`,
+			`WARN: DD_TRACE_FLUSH_INTERVAL: ignoring invalid value "2", expected a positive duration such as "2s"`,
+			`WARN: DD_TRACE_PAYLOAD_SIZE_LIMIT: ignoring invalid value "10485760", expected an integer between 1 and 9961472`,
+			`WARN: DD_TRACE_PAYLOAD_QUEUE_SIZE: ignoring invalid value "0", expected a positive integer`,
+			`WARN: DD_TRACE_HTTP_TIMEOUT: ignoring invalid value "-1s", expected a positive duration such as "2s"`,
+			`WARN: DD_TRACE_AGENT_URL: ignoring URL "ftp://localhost", the scheme must be http or unix`,
+		}, l.Lines())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		os.Setenv("DD_TRACE_ENABLED", "false")
+		defer os.Unsetenv("DD_TRACE_ENABLED")
+		Start()
+		defer Stop()
+		assert.IsType(t, &internal.NoopTracer{}, internal.GetGlobalTracer())
+	})
 }
@@ -37,6 +37,10 @@ type payload struct {
 
 	// buf holds the sequence of msgpack-encoded items.
 	buf bytes.Buffer
+
+	// roff specifies the current read position in buf. Reading does not consume
+	// buf, so that the payload can be rewound and read again.
+	roff int
 }
 
 var _ io.Reader = (*payload)(nil)
@@ -74,10 +78,22 @@ func (p *payload) size() int {
 // reset resets the internal buffer, counter and read offset.
 func (p *payload) reset() {
 	p.off = 8
+	p.roff = 0
 	p.count = 0
 	p.buf.Reset()
 }
 
+// rewind resets the read offsets to the beginning of the stream, allowing the
+// payload to be read again, for example when a request needs to be retried.
+func (p *payload) rewind() {
+	p.roff = 0
+	if p.count == 0 {
+		p.off = len(p.header)
+		return
+	}
+	p.updateHeader()
+}
+
 // https://github.com/msgpack/msgpack/blob/master/spec.md#array-format-family
 const (
 	msgpackArrayFix byte = 144  // up to 15 items
@@ -112,5 +128,13 @@ func (p *payload) Read(b []byte) (n int, err error) {
 		p.off += n
 		return n, nil
 	}
-	return p.buf.Read(b)
+	if p.roff >= p.buf.Len() {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n = copy(b, p.buf.Bytes()[p.roff:])
+	p.roff += n
+	return n, nil
 }
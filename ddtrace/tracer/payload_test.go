@@ -49,6 +49,11 @@ func TestPayloadIntegrity(t *testing.T) {
 			got, err := ioutil.ReadAll(p)
 			assert.NoError(err)
 			assert.Equal(want.Bytes(), got)
+
+			p.rewind()
+			got, err = ioutil.ReadAll(p)
+			assert.NoError(err)
+			assert.Equal(want.Bytes(), got, "rewound payload should read the same")
 		})
 	}
 }
@@ -0,0 +1,259 @@
+package tracer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SpanProcessor processes finished traces before they are sent to the agent. It
+// can modify the spans of the trace, drop some of them using DropSpan, or drop
+// the whole trace by returning false. Processors are called in order on the
+// tracer's worker goroutine, so they must be fast and must not retain the trace
+// or its spans after returning. When partial flushing is enabled, processors are
+// called with each chunk of the trace instead. When the tracer computes the stats
+// of the traces, they are computed before the processors are called, so they are
+// not affected by the changes which processors make.
+type SpanProcessor func(t *FinishedTrace) (keep bool)
+
+// FinishedTrace gives a SpanProcessor access to the spans of a finished trace.
+type FinishedTrace struct {
+	spans   []*span
+	dropped map[*span]struct{}
+}
+
+// Spans returns the spans of the trace which have not been dropped.
+func (t *FinishedTrace) Spans() []FinishedSpan {
+	spans := make([]FinishedSpan, 0, len(t.spans)-len(t.dropped))
+	for _, s := range t.spans {
+		if _, ok := t.dropped[s]; !ok {
+			spans = append(spans, FinishedSpan{s})
+		}
+	}
+	return spans
+}
+
+// DropSpan drops the given span from the trace. The children of the span are
+// re-parented to its closest ancestor which is not dropped. Spans which are not
+// part of the trace, such as the spans of another trace, are ignored.
+func (t *FinishedTrace) DropSpan(s FinishedSpan) {
+	if !t.contains(s.s) {
+		return
+	}
+	if t.dropped == nil {
+		t.dropped = make(map[*span]struct{})
+	}
+	t.dropped[s.s] = struct{}{}
+}
+
+// contains reports whether s is one of the spans of the trace.
+func (t *FinishedTrace) contains(s *span) bool {
+	if s == nil {
+		return false
+	}
+	for _, v := range t.spans {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// FinishedSpan provides read and write access to a span of a FinishedTrace.
+// It is only valid while the SpanProcessor which received it is running.
+type FinishedSpan struct{ s *span }
+
+// Name returns the operation name of the span.
+func (f FinishedSpan) Name() string {
+	f.s.RLock()
+	defer f.s.RUnlock()
+	return f.s.Name
+}
+
+// SetName sets the operation name of the span.
+func (f FinishedSpan) SetName(name string) {
+	f.s.Lock()
+	defer f.s.Unlock()
+	f.s.Name = name
+}
+
+// Service returns the service name of the span.
+func (f FinishedSpan) Service() string {
+	f.s.RLock()
+	defer f.s.RUnlock()
+	return f.s.Service
+}
+
+// SetService sets the service name of the span.
+func (f FinishedSpan) SetService(service string) {
+	f.s.Lock()
+	defer f.s.Unlock()
+	f.s.Service = service
+}
+
+// Resource returns the resource name of the span.
+func (f FinishedSpan) Resource() string {
+	f.s.RLock()
+	defer f.s.RUnlock()
+	return f.s.Resource
+}
+
+// SetResource sets the resource name of the span.
+func (f FinishedSpan) SetResource(resource string) {
+	f.s.Lock()
+	defer f.s.Unlock()
+	f.s.Resource = resource
+}
+
+// Type returns the type of the span, such as "web" or "db".
+func (f FinishedSpan) Type() string {
+	f.s.RLock()
+	defer f.s.RUnlock()
+	return f.s.Type
+}
+
+// SpanID returns the ID of the span.
+func (f FinishedSpan) SpanID() uint64 { return f.s.SpanID }
+
+// TraceID returns the ID of the trace which the span belongs to.
+func (f FinishedSpan) TraceID() uint64 { return f.s.TraceID }
+
+// ParentID returns the ID of the parent of the span, or zero for root spans.
+func (f FinishedSpan) ParentID() uint64 {
+	return f.s.ParentID
+}
+
+// StartTime returns the time at which the span started.
+func (f FinishedSpan) StartTime() time.Time {
+	return time.Unix(0, f.s.Start)
+}
+
+// Duration returns the duration of the span.
+func (f FinishedSpan) Duration() time.Duration {
+	return time.Duration(f.s.Duration)
+}
+
+// IsError reports whether the span is marked as an error.
+func (f FinishedSpan) IsError() bool {
+	f.s.RLock()
+	defer f.s.RUnlock()
+	return f.s.Error != 0
+}
+
+// Tag returns the value of the string tag with the given key.
+func (f FinishedSpan) Tag(key string) (string, bool) {
+	f.s.RLock()
+	defer f.s.RUnlock()
+	v, ok := f.s.Meta[key]
+	return v, ok
+}
+
+// SetTag sets the string tag key to value.
+func (f FinishedSpan) SetTag(key, value string) {
+	f.s.Lock()
+	defer f.s.Unlock()
+	f.s.Meta[key] = value
+}
+
+// Metric returns the value of the numeric tag with the given key.
+func (f FinishedSpan) Metric(key string) (float64, bool) {
+	f.s.RLock()
+	defer f.s.RUnlock()
+	v, ok := f.s.Metrics[key]
+	return v, ok
+}
+
+// SetMetric sets the numeric tag key to value.
+func (f FinishedSpan) SetMetric(key string, value float64) {
+	f.s.Lock()
+	defer f.s.Unlock()
+	f.s.Metrics[key] = value
+}
+
+// DeleteTag removes the string or numeric tag with the given key.
+func (f FinishedSpan) DeleteTag(key string) {
+	f.s.Lock()
+	defer f.s.Unlock()
+	delete(f.s.Meta, key)
+	delete(f.s.Metrics, key)
+}
+
+// ForeachTag calls fn with each string tag of the span, stopping when it
+// returns false. The tags are copied beforehand, so the span may be modified
+// from fn.
+func (f FinishedSpan) ForeachTag(fn func(key, value string) bool) {
+	f.s.RLock()
+	meta := make(map[string]string, len(f.s.Meta))
+	for k, v := range f.s.Meta {
+		meta[k] = v
+	}
+	f.s.RUnlock()
+	for k, v := range meta {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+// chunkMetricKeys and chunkMetaKeys hold the keys of the tags which are set
+// on the first span of a trace chunk, and which need to be carried over to the
+// next span when it is dropped by a SpanProcessor.
+var (
+	chunkMetricKeys = []string{partialFlushMetricKey, samplingPriorityKey, sampleRateMetricKey}
+	chunkMetaKeys   = []string{traceIDHighMetaKey}
+)
+
+// processTrace runs the configured span processors on trace, returning the
+// spans which should be sent to the agent, or nil if the whole trace was
+// dropped. It is called by the worker.
+func (t *tracer) processTrace(trace []*span) []*span {
+	if len(t.config.spanProcessors) == 0 || len(trace) == 0 {
+		return trace
+	}
+	ft := &FinishedTrace{spans: trace}
+	for _, fn := range t.config.spanProcessors {
+		if !fn(ft) || len(ft.dropped) == len(trace) {
+			atomic.AddUint64(&t.health.tracesDroppedProcessor, 1)
+			atomic.AddUint64(&t.health.spansDroppedProcessor, uint64(len(trace)))
+			return nil
+		}
+	}
+	if len(ft.dropped) == 0 {
+		return trace
+	}
+	atomic.AddUint64(&t.health.spansDroppedProcessor, uint64(len(ft.dropped)))
+	parents := make(map[uint64]uint64, len(ft.dropped)) // dropped span ID -> parent ID
+	for s := range ft.dropped {
+		parents[s.SpanID] = s.ParentID
+	}
+	kept := make([]*span, 0, len(trace)-len(ft.dropped))
+	for _, s := range trace {
+		if _, ok := ft.dropped[s]; ok {
+			continue
+		}
+		for i := 0; i < len(parents); i++ {
+			p, ok := parents[s.ParentID]
+			if !ok {
+				break
+			}
+			s.ParentID = p
+		}
+		kept = append(kept, s)
+	}
+	if first := trace[0]; first != kept[0] {
+		for _, k := range chunkMetricKeys {
+			if v, ok := first.Metrics[k]; ok {
+				if _, ok := kept[0].Metrics[k]; !ok {
+					kept[0].Metrics[k] = v
+				}
+			}
+		}
+		for _, k := range chunkMetaKeys {
+			if v, ok := first.Meta[k]; ok {
+				if _, ok := kept[0].Meta[k]; !ok {
+					kept[0].Meta[k] = v
+				}
+			}
+		}
+	}
+	return kept
+}
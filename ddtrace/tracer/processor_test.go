@@ -0,0 +1,212 @@
+package tracer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+func TestSpanProcessor(t *testing.T) {
+	t.Run("modify", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(WithSpanProcessor(func(t *FinishedTrace) bool {
+			for _, s := range t.Spans() {
+				if _, ok := s.Tag("user.email"); ok {
+					s.SetTag("user.email", "?")
+				}
+				if s.Service() == "old" {
+					s.SetService("new")
+				}
+				s.SetResource(s.Resource() + "!")
+				s.DeleteTag("secret")
+				s.SetMetric("processed", 1)
+			}
+			return true
+		}))
+		defer stop()
+
+		root := tracer.StartSpan("root", ServiceName("old"), ResourceName("res"), Tag("user.email", "a@b.c"), Tag("secret", 42))
+		root.Finish()
+		root.SetTag("after", "finish") // ignored
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		if assert.Len(traces, 1) && assert.Len(traces[0], 1) {
+			s := traces[0][0]
+			assert.Equal("new", s.Service)
+			assert.Equal("res!", s.Resource)
+			assert.Equal("?", s.Meta["user.email"])
+			assert.NotContains(s.Meta, "after")
+			assert.NotContains(s.Metrics, "secret")
+			assert.Equal(1., s.Metrics["processed"])
+		}
+	})
+
+	t.Run("finished-parent", func(t *testing.T) {
+		// children may still be started from a span which is being processed
+		tracer, _, stop := startTestTracer(WithSpanProcessor(func(t *FinishedTrace) bool {
+			for _, s := range t.Spans() {
+				s.SetService(s.Service() + "!")
+			}
+			return true
+		}))
+		defer stop()
+
+		root := tracer.StartSpan("root")
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				tracer.StartSpan("child", ChildOf(root.Context()))
+			}
+		}()
+		root.Finish()
+		wg.Wait()
+	})
+
+	t.Run("drop-spans", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(WithSpanProcessor(func(t *FinishedTrace) bool {
+			for _, s := range t.Spans() {
+				if s.Name() == "drop" {
+					t.DropSpan(s)
+				}
+			}
+			return true
+		}))
+		defer stop()
+
+		// root -> drop -> drop -> leaf, root -> keep
+		root := tracer.StartSpan("root")
+		a := tracer.StartSpan("drop", ChildOf(root.Context()))
+		b := tracer.StartSpan("drop", ChildOf(a.Context()))
+		leaf := tracer.StartSpan("leaf", ChildOf(b.Context()))
+		keep := tracer.StartSpan("keep", ChildOf(root.Context()))
+		for _, s := range []ddtrace.Span{leaf, b, a, keep, root} {
+			s.Finish()
+		}
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		if assert.Len(traces, 1) && assert.Len(traces[0], 3) {
+			parents := make(map[string]uint64)
+			for _, s := range traces[0] {
+				parents[s.Name] = s.ParentID
+			}
+			assert.Equal(map[string]uint64{
+				"root": 0,
+				"leaf": root.Context().SpanID(),
+				"keep": root.Context().SpanID(),
+			}, parents)
+		}
+		assert.EqualValues(2, atomic.LoadUint64(&tracer.health.spansDroppedProcessor))
+		assert.EqualValues(0, atomic.LoadUint64(&tracer.health.tracesDroppedProcessor))
+	})
+
+	t.Run("drop-root", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(With128BitTraceIDs(), WithSpanProcessor(func(t *FinishedTrace) bool {
+			for _, s := range t.Spans() {
+				if s.ParentID() == 0 {
+					t.DropSpan(s)
+				}
+			}
+			return true
+		}))
+		defer stop()
+
+		root := tracer.StartSpan("root", Tag(ext.SamplingPriority, ext.PriorityUserKeep))
+		child := tracer.StartSpan("child", ChildOf(root.Context()))
+		child.Finish()
+		root.Finish()
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		if assert.Len(traces, 1) && assert.Len(traces[0], 1) {
+			s := traces[0][0]
+			assert.Equal("child", s.Name)
+			assert.Zero(s.ParentID)
+			assert.Contains(s.Meta, traceIDHighMetaKey)
+			assert.EqualValues(ext.PriorityUserKeep, s.Metrics[samplingPriorityKey])
+		}
+	})
+
+	t.Run("drop-trace", func(t *testing.T) {
+		assert := assert.New(t)
+		var calls int
+		tracer, transport, stop := startTestTracer(
+			WithSpanProcessor(func(t *FinishedTrace) bool {
+				return t.Spans()[0].Resource() != "/healthz"
+			}),
+			WithSpanProcessor(func(t *FinishedTrace) bool {
+				calls++
+				return true
+			}),
+		)
+		defer stop()
+
+		root := tracer.StartSpan("http.request", ResourceName("/healthz"))
+		tracer.StartSpan("child", ChildOf(root.Context())).Finish()
+		root.Finish()
+		tracer.StartSpan("http.request", ResourceName("/users")).Finish()
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		if assert.Len(traces, 1) {
+			assert.Equal("/users", traces[0][0].Resource)
+		}
+		assert.Equal(1, calls)
+		assert.EqualValues(2, atomic.LoadUint64(&tracer.health.spansDroppedProcessor))
+		assert.EqualValues(1, atomic.LoadUint64(&tracer.health.tracesDroppedProcessor))
+	})
+
+	t.Run("drop-foreign-spans", func(t *testing.T) {
+		assert := assert.New(t)
+		var other *FinishedTrace
+		tracer, transport, stop := startTestTracer(WithSpanProcessor(func(t *FinishedTrace) bool {
+			if other == nil {
+				other = t
+				return true
+			}
+			// spans of another trace and zero spans are ignored
+			for _, s := range other.Spans() {
+				t.DropSpan(s)
+			}
+			t.DropSpan(FinishedSpan{})
+			t.DropSpan(FinishedSpan{})
+			assert.Len(t.Spans(), 1)
+			return true
+		}))
+		defer stop()
+
+		tracer.StartSpan("first").Finish()
+		tracer.StartSpan("second").Finish()
+		tracer.forceFlush()
+
+		assert.Len(transport.Traces(), 2)
+		assert.EqualValues(0, atomic.LoadUint64(&tracer.health.spansDroppedProcessor))
+	})
+
+	t.Run("drop-all-spans", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(WithSpanProcessor(func(t *FinishedTrace) bool {
+			for _, s := range t.Spans() {
+				t.DropSpan(s)
+			}
+			return true
+		}))
+		defer stop()
+
+		tracer.StartSpan("root").Finish()
+		tracer.forceFlush()
+
+		assert.Len(transport.Traces(), 0)
+		assert.EqualValues(1, atomic.LoadUint64(&tracer.health.tracesDroppedProcessor))
+	})
+}
@@ -2,7 +2,7 @@ package tracer
 
 import (
 	cryptorand "crypto/rand"
-	"log"
+	"fmt"
 	"math"
 	"math/big"
 	"math/rand"
@@ -19,7 +19,7 @@ func init() {
 	if err == nil {
 		seed = n.Int64()
 	} else {
-		log.Printf("%scannot generate random seed: %v; using current time\n", errorPrefix, err)
+		defaultLogger{}.Warn(fmt.Sprintf("cannot generate random seed: %v; using current time", err))
 		seed = time.Now().UnixNano()
 	}
 	random = rand.New(&safeSource{
@@ -48,3 +48,11 @@ func (rs *safeSource) Seed(seed int64) {
 	rs.Seed(seed)
 	rs.Unlock()
 }
+
+// generateTraceIDHigh returns the upper 64 bits of a new 128-bit trace ID. As
+// recommended by the W3C Trace Context specification, they hold the current
+// Unix time in seconds followed by 32 random bits, keeping IDs unique even
+// when the lower 64 bits collide.
+func generateTraceIDHigh() uint64 {
+	return uint64(time.Now().Unix())<<32 | uint64(random.Uint32())
+}
@@ -0,0 +1,160 @@
+package tracer
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultRetryBufferSize is the default maximum total size, in bytes, of
+	// the payloads kept to be retried.
+	defaultRetryBufferSize = 16 * 1024 * 1024 // 16 MB
+
+	// defaultRetryMaxAge is the default maximum time during which a payload
+	// which failed to be sent is retried.
+	defaultRetryMaxAge = time.Minute
+
+	// retryMinBackoff and retryMaxBackoff bound the delay between two attempts
+	// at sending the payloads of the retry buffer, before jitter is applied.
+	retryMinBackoff = time.Second
+	retryMaxBackoff = 30 * time.Second
+)
+
+// retryBuffer holds the payloads which failed to be sent to the agent, oldest
+// first, until they are sent or dropped because they are too old or the buffer
+// is full. It is only accessed by the worker.
+type retryBuffer struct {
+	maxSize int           // maximum total size of the payloads, zero to disable retries
+	maxAge  time.Duration // maximum age of the payloads
+
+	entries []retryEntry
+	size    int // total size of the entries
+	count   int // total number of traces in the entries
+
+	retryBackoff
+}
+
+// retryEntry is a payload of the retry buffer.
+type retryEntry struct {
+	p       *payload
+	size    int
+	created time.Time
+}
+
+// newRetryBuffer returns a retry buffer holding payloads of at most maxSize
+// bytes in total, for at most maxAge.
+func newRetryBuffer(maxSize int, maxAge time.Duration) retryBuffer {
+	return retryBuffer{maxSize: maxSize, maxAge: maxAge}
+}
+
+// enabled reports whether failed payloads are retried.
+func (b *retryBuffer) enabled() bool { return b.maxSize > 0 }
+
+// len returns the number of payloads in the buffer.
+func (b *retryBuffer) len() int { return len(b.entries) }
+
+// push adds p to the buffer, evicting the oldest payloads if needed to keep the
+// buffer within its maximum size. It returns the payloads evicted, which include
+// p when it is larger than the buffer.
+func (b *retryBuffer) push(p *payload, now time.Time) (evicted []*payload) {
+	p.rewind()
+	size := p.size()
+	if size > b.maxSize {
+		return []*payload{p}
+	}
+	for b.size+size > b.maxSize {
+		evicted = append(evicted, b.pop())
+	}
+	b.entries = append(b.entries, retryEntry{p: p, size: size, created: now})
+	b.size += size
+	b.count += p.itemCount()
+	return evicted
+}
+
+// peek returns the oldest payload of the buffer, rewound so that it can be sent.
+func (b *retryBuffer) peek() *payload {
+	p := b.entries[0].p
+	p.rewind()
+	return p
+}
+
+// pop removes the oldest payload from the buffer and returns it.
+func (b *retryBuffer) pop() *payload {
+	e := b.entries[0]
+	b.entries[0] = retryEntry{}
+	b.entries = b.entries[1:]
+	b.size -= e.size
+	b.count -= e.p.itemCount()
+	return e.p
+}
+
+// expire removes the payloads which are older than the maximum age from the
+// buffer and returns them.
+func (b *retryBuffer) expire(now time.Time) (expired []*payload) {
+	for len(b.entries) > 0 && now.Sub(b.entries[0].created) > b.maxAge {
+		expired = append(expired, b.pop())
+	}
+	return expired
+}
+
+// retryBackoff delays attempts at sending payloads after failures.
+type retryBackoff struct {
+	attempts int       // number of consecutive failed attempts
+	next     time.Time // time before which sending should not be attempted
+}
+
+// ready reports whether sending can be attempted again.
+func (b *retryBackoff) ready(now time.Time) bool {
+	return !now.Before(b.next)
+}
+
+// backoff records a failed attempt at sending a payload and delays the next one,
+// by retryAfter if it is positive, and otherwise exponentially, with jitter.
+func (b *retryBackoff) backoff(now time.Time, retryAfter time.Duration) {
+	b.attempts++
+	d := retryAfter
+	if d <= 0 {
+		d = retryMaxBackoff
+		if b.attempts <= 5 {
+			d = retryMinBackoff << uint(b.attempts-1)
+		}
+		// pick a random delay between d/2 and d, so that tracers which lost
+		// their agent at the same time do not all retry at once.
+		d = d/2 + time.Duration(random.Int63n(int64(d/2)+1))
+	}
+	b.next = now.Add(d)
+}
+
+// resetBackoff allows sending to be attempted again right away.
+func (b *retryBackoff) resetBackoff() {
+	b.attempts = 0
+	b.next = time.Time{}
+}
+
+// traceCount returns the total number of traces in the given payloads.
+func traceCount(ps []*payload) int {
+	var n int
+	for _, p := range ps {
+		n += p.itemCount()
+	}
+	return n
+}
+
+// retryable reports whether a payload which could not be sent because of err
+// should be retried. Payloads rejected by the agent are not retried, unless it
+// was overloaded.
+func retryable(err error) bool {
+	if e, ok := err.(*agentError); ok {
+		return e.status == http.StatusTooManyRequests || e.status >= 500
+	}
+	return true
+}
+
+// retryAfter returns the delay requested by the agent before sending payloads
+// again, or zero if none.
+func retryAfter(err error) time.Duration {
+	if e, ok := err.(*agentError); ok {
+		return e.retryAfter
+	}
+	return 0
+}
@@ -0,0 +1,248 @@
+package tracer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinylib/msgp/msgp"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+)
+
+// flakyAgent is a test agent which fails requests with the given responses, in
+// order, and succeeds once they have all been used.
+type flakyAgent struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	failures  []flakyResponse
+	requests  int
+	received  []string // names of the root spans of the traces received
+	succeeded chan struct{}
+}
+
+// flakyResponse is an error response returned by a flakyAgent.
+type flakyResponse struct {
+	status     int
+	retryAfter string
+}
+
+func newFlakyAgent(failures ...flakyResponse) *flakyAgent {
+	a := &flakyAgent{failures: failures, succeeded: make(chan struct{}, 100)}
+	a.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.requests++
+		if len(a.failures) > 0 {
+			f := a.failures[0]
+			a.failures = a.failures[1:]
+			if f.retryAfter != "" {
+				w.Header().Set("Retry-After", f.retryAfter)
+			}
+			w.WriteHeader(f.status)
+			return
+		}
+		var traces spanLists
+		if err := msgp.Decode(r.Body, &traces); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, t := range traces {
+			a.received = append(a.received, t[0].Name)
+		}
+		a.succeeded <- struct{}{}
+	}))
+	return a
+}
+
+// Received returns the names of the root spans of the traces received so far,
+// and the number of requests.
+func (a *flakyAgent) Received() ([]string, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.received...), a.requests
+}
+
+// newRetryTestTracer returns a tracer without a worker, which sends traces to
+// the given agent.
+func newRetryTestTracer(a *flakyAgent) *tracer {
+	tracer := newTracerChannels()
+	tracer.config.transport = newHTTPTransport(strings.TrimPrefix(a.URL, "http://"), defaultRoundTripper)
+	tracer.config.statsd = noopStatsd{}
+	tracer.retries = newRetryBuffer(defaultRetryBufferSize, defaultRetryMaxAge)
+	return tracer
+}
+
+func TestTracerRetry(t *testing.T) {
+	t.Run("unavailable", func(t *testing.T) {
+		assert := assert.New(t)
+		agent := newFlakyAgent(flakyResponse{status: 503}, flakyResponse{status: 503})
+		defer agent.Close()
+		tracer := newRetryTestTracer(agent)
+
+		tracer.pushPayload([]*span{newBasicSpan("a")})
+		assert.Error(tracer.flushTraces(context.Background()))
+		assert.Equal(1, tracer.retries.len())
+
+		// the backoff has not elapsed, so b is queued behind a
+		tracer.pushPayload([]*span{newBasicSpan("b")})
+		assert.NoError(tracer.flushTraces(context.Background()))
+		_, requests := agent.Received()
+		assert.Equal(1, requests)
+		assert.Equal(2, tracer.retries.len())
+
+		tracer.retries.next = time.Time{}
+		assert.Error(tracer.flushTraces(context.Background()))
+		assert.Equal(2, tracer.retries.len())
+		assert.Equal(2, tracer.retries.attempts)
+
+		tracer.retries.next = time.Time{}
+		tracer.pushPayload([]*span{newBasicSpan("c")})
+		assert.NoError(tracer.flushTraces(context.Background()))
+		received, requests := agent.Received()
+		assert.Equal([]string{"a", "b", "c"}, received)
+		assert.Equal(5, requests)
+		assert.Equal(0, tracer.retries.len())
+		assert.Equal(0, tracer.retries.attempts)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		assert := assert.New(t)
+		agent := newFlakyAgent(flakyResponse{status: 400})
+		defer agent.Close()
+		tracer := newRetryTestTracer(agent)
+
+		tracer.pushPayload([]*span{newBasicSpan("a")})
+		assert.Error(tracer.flushTraces(context.Background()))
+		assert.Equal(0, tracer.retries.len())
+		assert.Equal(1, len(tracer.errorBuffer))
+		assert.IsType(&dataLossError{}, <-tracer.errorBuffer)
+	})
+
+	t.Run("retry-after", func(t *testing.T) {
+		assert := assert.New(t)
+		agent := newFlakyAgent(flakyResponse{status: 429, retryAfter: "120"})
+		defer agent.Close()
+		tracer := newRetryTestTracer(agent)
+
+		tracer.pushPayload([]*span{newBasicSpan("a")})
+		start := time.Now()
+		assert.Error(tracer.flushTraces(context.Background()))
+		assert.Equal(1, tracer.retries.len())
+		assert.WithinDuration(start.Add(120*time.Second), tracer.retries.next, time.Second)
+		assert.IsType(&sendRetryError{}, <-tracer.errorBuffer)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		agent := newFlakyAgent(flakyResponse{status: 503})
+		defer agent.Close()
+		tracer := newRetryTestTracer(agent)
+		tracer.retries = newRetryBuffer(0, defaultRetryMaxAge)
+
+		tracer.pushPayload([]*span{newBasicSpan("a")})
+		assert.Error(tracer.flushTraces(context.Background()))
+		assert.Equal(0, tracer.retries.len())
+	})
+
+	t.Run("worker", func(t *testing.T) {
+		assert := assert.New(t)
+		agent := newFlakyAgent(flakyResponse{status: 503})
+		defer agent.Close()
+		tracer := newTracer(
+			WithAgentAddr(strings.TrimPrefix(agent.URL, "http://")),
+			WithFlushInterval(100*time.Millisecond),
+		)
+		internal.SetGlobalTracer(tracer)
+		defer internal.SetGlobalTracer(&internal.NoopTracer{})
+
+		tracer.StartSpan("a").Finish()
+		select {
+		case <-agent.succeeded:
+		case <-time.After(5 * time.Second):
+			assert.Fail("trace was not retried")
+		}
+		received, requests := agent.Received()
+		assert.Equal([]string{"a"}, received)
+		assert.Equal(2, requests)
+	})
+}
+
+func TestRetryBuffer(t *testing.T) {
+	newTestPayload := func(n int) *payload {
+		p := newPayload()
+		for i := 0; i < n; i++ {
+			p.push(spanList{newBasicSpan("op")})
+		}
+		return p
+	}
+
+	t.Run("size", func(t *testing.T) {
+		assert := assert.New(t)
+		size := newTestPayload(2).size()
+		b := newRetryBuffer(2*size, time.Minute)
+		now := time.Now()
+		assert.Empty(b.push(newTestPayload(2), now))
+		assert.Empty(b.push(newTestPayload(2), now))
+		assert.Equal(4, b.count)
+		assert.Equal(2, traceCount(b.push(newTestPayload(2), now)))
+		assert.Equal(2, b.len())
+		// larger than the buffer
+		big := newTestPayload(5)
+		assert.Equal([]*payload{big}, b.push(big, now))
+		assert.Equal(2, b.len())
+		// evicts both payloads
+		assert.Len(b.push(newTestPayload(3), now), 2)
+		assert.Equal(1, b.len())
+		assert.Equal(3, b.count)
+	})
+
+	t.Run("age", func(t *testing.T) {
+		assert := assert.New(t)
+		b := newRetryBuffer(defaultRetryBufferSize, time.Minute)
+		now := time.Now()
+		b.push(newTestPayload(1), now.Add(-2*time.Minute))
+		b.push(newTestPayload(2), now.Add(-time.Second))
+		assert.Equal(1, traceCount(b.expire(now)))
+		assert.Equal(1, b.len())
+		assert.Equal(2, b.count)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		assert := assert.New(t)
+		var b retryBackoff
+		now := time.Now()
+		for _, max := range []time.Duration{1, 2, 4, 8, 16, 30, 30} {
+			b.backoff(now, 0)
+			max *= time.Second
+			d := b.next.Sub(now)
+			assert.True(d >= max/2 && d <= max, "%s not in [%s, %s]", d, max/2, max)
+			assert.False(b.ready(now))
+			assert.True(b.ready(b.next))
+		}
+		b.backoff(now, time.Minute)
+		assert.Equal(now.Add(time.Minute), b.next)
+		b.resetBackoff()
+		assert.True(b.ready(now))
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	for in, out := range map[string]time.Duration{
+		"":    0,
+		"120": 2 * time.Minute,
+		"-1":  0,
+		"abc": 0,
+		now.Add(time.Minute).Format(http.TimeFormat):  time.Minute,
+		now.Add(-time.Minute).Format(http.TimeFormat): 0,
+	} {
+		assert.Equal(t, out, parseRetryAfter(in, now), in)
+	}
+}
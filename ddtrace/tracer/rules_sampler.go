@@ -0,0 +1,255 @@
+package tracer
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+)
+
+const (
+	// ruleSampleRateMetricKey is the metric key holding the sample rate of the
+	// rule which was applied to the span.
+	ruleSampleRateMetricKey = "_dd.rule_psr"
+
+	// limiterRateMetricKey is the metric key holding the effective rate of the
+	// rate limiter at the time the span was sampled.
+	limiterRateMetricKey = "_dd.limit_psr"
+)
+
+// SamplingRule is used for applying sampling rates to spans that match the
+// service name, operation name, resource and tags. A nil pattern matches any
+// value. All non-nil patterns must match for the rule to be applied.
+type SamplingRule struct {
+	// Service specifies the pattern which the span's service name must match.
+	Service *regexp.Regexp
+
+	// Name specifies the pattern which the span's operation name must match.
+	Name *regexp.Regexp
+
+	// Resource specifies the pattern which the span's resource must match.
+	Resource *regexp.Regexp
+
+	// Tags specifies a set of patterns which the values of the span's tags
+	// must match. Spans which do not have one of the tags will not match.
+	Tags map[string]*regexp.Regexp
+
+	// Rate specifies the rate at which traces matching this rule are kept,
+	// between 0 and 1.
+	Rate float64
+}
+
+// RateRule returns a SamplingRule that applies the provided sampling rate
+// to all spans.
+func RateRule(rate float64) SamplingRule {
+	return SamplingRule{Rate: rate}
+}
+
+// ServiceRule returns a SamplingRule that applies the provided sampling rate
+// to spans having a service name matching the given glob pattern.
+func ServiceRule(service string, rate float64) SamplingRule {
+	return SamplingRule{
+		Service: GlobPattern(service),
+		Rate:    rate,
+	}
+}
+
+// NameRule returns a SamplingRule that applies the provided sampling rate
+// to spans having an operation name matching the given glob pattern.
+func NameRule(name string, rate float64) SamplingRule {
+	return SamplingRule{
+		Name: GlobPattern(name),
+		Rate: rate,
+	}
+}
+
+// NameServiceRule returns a SamplingRule that applies the provided sampling rate
+// to spans matching both the operation name and service name glob patterns.
+func NameServiceRule(name, service string, rate float64) SamplingRule {
+	return SamplingRule{
+		Service: GlobPattern(service),
+		Name:    GlobPattern(name),
+		Rate:    rate,
+	}
+}
+
+// ResourceRule returns a SamplingRule that applies the provided sampling rate
+// to spans having a resource matching the given glob pattern.
+func ResourceRule(resource string, rate float64) SamplingRule {
+	return SamplingRule{
+		Resource: GlobPattern(resource),
+		Rate:     rate,
+	}
+}
+
+// GlobPattern returns a regular expression which fully matches the given glob
+// pattern. In the pattern, '*' matches any sequence of characters and '?' matches
+// any single character. All other characters are matched literally.
+func GlobPattern(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i, part := range strings.Split(glob, "*") {
+		if i > 0 {
+			b.WriteString(".*")
+		}
+		for j, q := range strings.Split(part, "?") {
+			if j > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(regexp.QuoteMeta(q))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// match returns true when the given span matches all the patterns of the rule.
+// Callers must guard the span.
+func (sr *SamplingRule) match(s *span) bool {
+	if sr.Service != nil && !sr.Service.MatchString(s.Service) {
+		return false
+	}
+	if sr.Name != nil && !sr.Name.MatchString(s.Name) {
+		return false
+	}
+	if sr.Resource != nil && !sr.Resource.MatchString(s.Resource) {
+		return false
+	}
+	for k, re := range sr.Tags {
+		v, ok := s.Meta[k]
+		if !ok {
+			f, ok := s.Metrics[k]
+			if !ok {
+				return false
+			}
+			v = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		if !re.MatchString(v) {
+			return false
+		}
+	}
+	return true
+}
+
+var _ Sampler = (*RulesSampler)(nil)
+
+// RulesSampler is a Sampler which keeps traces at the rate of the first
+// SamplingRule matching their root span. Traces matching no rule are kept.
+// The total number of kept traces, whether they matched a rule or not, can
+// additionally be capped using a rate limiter. Rules are matched against the
+// root span as it is at the time it is started. Traces matching a rule are given
+// a user sampling priority, which is propagated downstream and takes precedence
+// over the rates of the agent when priority sampling is enabled.
+type RulesSampler struct {
+	rules   []SamplingRule
+	limiter *rateLimiter
+}
+
+// NewRulesSampler returns a new RulesSampler which applies the given rules in
+// order, keeping at most limit traces per second in total. A negative limit
+// disables the rate limiter.
+func NewRulesSampler(rules []SamplingRule, limit float64) *RulesSampler {
+	rs := &RulesSampler{rules: rules}
+	if limit >= 0 {
+		rs.limiter = newRateLimiter(limit)
+	}
+	return rs
+}
+
+// Sample implements Sampler. It records the rate of the applied rule and the
+// effective rate of the limiter as metrics on the span.
+func (rs *RulesSampler) Sample(spn ddtrace.Span) bool {
+	s, ok := spn.(*span)
+	if !ok {
+		return false
+	}
+	s.Lock()
+	defer s.Unlock()
+	var rule *SamplingRule
+	for i := range rs.rules {
+		if rs.rules[i].match(s) {
+			rule = &rs.rules[i]
+			break
+		}
+	}
+	if rule != nil {
+		rate := rule.Rate
+		if math.IsNaN(rate) || rate < 0 {
+			rate = 0
+		}
+		s.Metrics[ruleSampleRateMetricKey] = rate
+		if !sampledByRate(s.TraceID, rate) {
+			return false
+		}
+	}
+	if rs.limiter == nil {
+		return true
+	}
+	sampled, effectiveRate := rs.limiter.allowOne(time.Now())
+	s.Metrics[limiterRateMetricKey] = effectiveRate
+	return sampled
+}
+
+// rateLimiter is a token bucket allowing up to rate events per second, with
+// bursts of at most one second's worth of events. It also tracks the ratio of
+// allowed events over the current and previous second.
+type rateLimiter struct {
+	mu     sync.Mutex // guards below fields
+	rate   float64    // number of tokens added per second
+	burst  float64    // maximum number of tokens in the bucket
+	tokens float64    // number of tokens currently in the bucket
+	last   time.Time  // last time the bucket was refilled
+
+	window   time.Time // start of the current one second window
+	allowed  float64   // number of allowed events in the current window
+	seen     float64   // number of seen events in the current window
+	prevRate float64   // ratio of allowed events in the previous window, or -1
+}
+
+// newRateLimiter returns a rate limiter allowing up to rate events per second.
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{
+		rate:     rate,
+		burst:    math.Max(math.Ceil(rate), 1),
+		tokens:   math.Max(math.Ceil(rate), 1),
+		prevRate: -1,
+	}
+}
+
+// allowOne reports whether an event happening at the given time is allowed,
+// along with the effective rate of the limiter.
+func (l *rateLimiter) allowOne(now time.Time) (allowed bool, effectiveRate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if d := now.Sub(l.window); d >= time.Second {
+		if d < 2*time.Second && l.seen > 0 {
+			// the previous window is the one that just ended
+			l.prevRate = l.allowed / l.seen
+		} else {
+			l.prevRate = -1
+		}
+		l.window = now
+		l.allowed, l.seen = 0, 0
+	}
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	if l.last.IsZero() || now.After(l.last) {
+		l.last = now
+	}
+	l.seen++
+	if l.rate > 0 && l.tokens >= 1 {
+		l.tokens--
+		l.allowed++
+		allowed = true
+	}
+	effectiveRate = l.allowed / l.seen
+	if l.prevRate >= 0 {
+		effectiveRate = (effectiveRate + l.prevRate) / 2
+	}
+	return allowed, effectiveRate
+}
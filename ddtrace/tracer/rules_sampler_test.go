@@ -0,0 +1,267 @@
+package tracer
+
+import (
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobPattern(t *testing.T) {
+	for _, tt := range []struct {
+		glob    string
+		match   []string
+		nomatch []string
+	}{
+		{"payments", []string{"payments"}, []string{"payments-api", "my-payments", ""}},
+		{"*", []string{"", "anything", "a.b.c"}, nil},
+		{"payments*", []string{"payments", "payments-api"}, []string{"my-payments"}},
+		{"*payments", []string{"payments", "my-payments"}, []string{"payments-api"}},
+		{"http.*", []string{"http.request", "http."}, []string{"httpXrequest", "grpc.server"}},
+		{"GET /user/?", []string{"GET /user/1", "GET /user/x"}, []string{"GET /user/", "GET /user/12"}},
+		{"a*b?c", []string{"abxc", "a123b4c"}, []string{"abc", "a123bc"}},
+		{"(.+)", []string{"(.+)"}, []string{"abc"}},
+	} {
+		t.Run(tt.glob, func(t *testing.T) {
+			re := GlobPattern(tt.glob)
+			for _, s := range tt.match {
+				assert.True(t, re.MatchString(s), s)
+			}
+			for _, s := range tt.nomatch {
+				assert.False(t, re.MatchString(s), s)
+			}
+		})
+	}
+}
+
+func TestSamplingRuleMatch(t *testing.T) {
+	mkSpan := func() *span {
+		s := newSpan("http.request", "payments", "GET /charge", 1, 1, 0)
+		s.Meta["http.method"] = "GET"
+		s.Metrics["http.status_code"] = 200
+		return s
+	}
+	for i, tt := range []struct {
+		rule  SamplingRule
+		match bool
+	}{
+		{RateRule(1), true},
+		{ServiceRule("payments", 1), true},
+		{ServiceRule("pay*", 1), true},
+		{ServiceRule("healthcheck", 1), false},
+		{NameRule("http.*", 1), true},
+		{NameRule("grpc.*", 1), false},
+		{NameServiceRule("http.request", "payments", 1), true},
+		{NameServiceRule("http.request", "other", 1), false},
+		{ResourceRule("GET /*", 1), true},
+		{ResourceRule("/healthz", 1), false},
+		{SamplingRule{Resource: regexp.MustCompile("^GET /ch[a-z]+$")}, true},
+		{SamplingRule{Tags: map[string]*regexp.Regexp{"http.method": GlobPattern("GET")}}, true},
+		{SamplingRule{Tags: map[string]*regexp.Regexp{"http.method": GlobPattern("POST")}}, false},
+		{SamplingRule{Tags: map[string]*regexp.Regexp{"http.status_code": GlobPattern("2??")}}, true},
+		{SamplingRule{Tags: map[string]*regexp.Regexp{"missing": GlobPattern("*")}}, false},
+		{SamplingRule{
+			Service: GlobPattern("payments"),
+			Tags:    map[string]*regexp.Regexp{"http.method": GlobPattern("POST")},
+		}, false},
+	} {
+		assert.Equal(t, tt.match, tt.rule.match(mkSpan()), i)
+	}
+}
+
+func TestRulesSampler(t *testing.T) {
+	t.Run("no-match", func(t *testing.T) {
+		assert := assert.New(t)
+		rs := NewRulesSampler([]SamplingRule{ServiceRule("other", 0)}, -1)
+		s := newSpan("http.request", "payments", "/", 1, 1, 0)
+		assert.True(rs.Sample(s))
+		_, ok := s.Metrics[ruleSampleRateMetricKey]
+		assert.False(ok)
+		_, ok = s.Metrics[limiterRateMetricKey]
+		assert.False(ok)
+	})
+
+	t.Run("order", func(t *testing.T) {
+		assert := assert.New(t)
+		rs := NewRulesSampler([]SamplingRule{
+			ServiceRule("payments", 1),
+			ResourceRule("/healthz", 0),
+			RateRule(0.5),
+		}, -1)
+
+		s := newSpan("http.request", "payments", "/healthz", 1, 1, 0)
+		assert.True(rs.Sample(s))
+		assert.Equal(1., s.Metrics[ruleSampleRateMetricKey])
+
+		s = newSpan("http.request", "web", "/healthz", 1, 1, 0)
+		assert.False(rs.Sample(s))
+		assert.Equal(0., s.Metrics[ruleSampleRateMetricKey])
+
+		s = newSpan("http.request", "web", "/", 1, 1, 0)
+		rs.Sample(s)
+		assert.Equal(0.5, s.Metrics[ruleSampleRateMetricKey])
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		rs := NewRulesSampler([]SamplingRule{RateRule(0.3)}, -1)
+		var kept int
+		for i := 0; i < 10000; i++ {
+			id := random.Uint64()
+			if rs.Sample(newSpan("test", "", "", id, id, 0)) {
+				kept++
+			}
+		}
+		assert.InDelta(t, 3000, kept, 500)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		assert := assert.New(t)
+		rs := NewRulesSampler([]SamplingRule{RateRule(1)}, 10)
+		var kept int
+		for i := 0; i < 100; i++ {
+			s := newBasicSpan("test")
+			if rs.Sample(s) {
+				kept++
+			}
+			assert.Equal(1., s.Metrics[ruleSampleRateMetricKey])
+			_, ok := s.Metrics[limiterRateMetricKey]
+			assert.True(ok)
+		}
+		// the test should run well within a second, but allow for some slack
+		assert.True(kept >= 10 && kept < 20, kept)
+	})
+
+	t.Run("limit-no-match", func(t *testing.T) {
+		assert := assert.New(t)
+		rs := NewRulesSampler([]SamplingRule{ServiceRule("other", 1)}, 10)
+		var kept int
+		for i := 0; i < 100; i++ {
+			s := newBasicSpan("test")
+			if rs.Sample(s) {
+				kept++
+			}
+			_, ok := s.Metrics[ruleSampleRateMetricKey]
+			assert.False(ok)
+			_, ok = s.Metrics[limiterRateMetricKey]
+			assert.True(ok)
+		}
+		assert.True(kept >= 10 && kept < 20, kept)
+	})
+
+	t.Run("invalid-span", func(t *testing.T) {
+		rs := NewRulesSampler(nil, -1)
+		assert.False(t, rs.Sample(internal.NoopSpan{}))
+	})
+
+	t.Run("tracer", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(WithSampler(NewRulesSampler([]SamplingRule{
+			ServiceRule("payments", 1),
+			ResourceRule("/healthz", 0),
+		}, -1)))
+		defer stop()
+
+		tracer.newRootSpan("http.request", "web", "/healthz").Finish()
+		tracer.newRootSpan("http.request", "payments", "/healthz").Finish()
+		tracer.newRootSpan("http.request", "web", "/").Finish()
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		assert.Len(traces, 2)
+		assert.Equal("payments", traces[0][0].Service)
+		assert.Equal("/", traces[1][0].Resource)
+	})
+
+	t.Run("priority-sampling", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(
+			WithSampler(NewRulesSampler([]SamplingRule{
+				ServiceRule("payments", 1),
+				ResourceRule("/healthz", 0),
+			}, -1)),
+			WithPrioritySampling(),
+		)
+		defer stop()
+		// the agent rejects everything
+		err := tracer.prioritySampling.readRatesJSON(
+			ioutil.NopCloser(strings.NewReader(`{"rate_by_service":{"service:,env:":0}}`)),
+		)
+		assert.NoError(err)
+
+		kept := tracer.newRootSpan("http.request", "payments", "/")
+		assert.Equal(ext.PriorityUserKeep, kept.context.samplingPriority())
+		assert.NotContains(kept.Metrics, samplingPriorityRateKey)
+		kept.Finish()
+
+		// rejected traces are dropped, but their priority is propagated
+		rejected := tracer.newRootSpan("http.request", "web", "/healthz")
+		carrier := TextMapCarrier(map[string]string{})
+		assert.NoError(tracer.Inject(rejected.Context(), carrier))
+		assert.Equal("-1", carrier[DefaultPriorityHeader])
+		rejected.Finish()
+
+		// traces matching no rule use the rates of the agent
+		other := tracer.newRootSpan("http.request", "web", "/")
+		assert.Equal(ext.PriorityAutoReject, other.context.samplingPriority())
+		other.Finish()
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		assert.Len(traces, 2)
+		assert.EqualValues(ext.PriorityUserKeep, traces[0][0].Metrics[samplingPriorityKey])
+		assert.EqualValues(ext.PriorityAutoReject, traces[1][0].Metrics[samplingPriorityKey])
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("bucket", func(t *testing.T) {
+		assert := assert.New(t)
+		l := newRateLimiter(2)
+		now := time.Now()
+		allowed := func(n int, at time.Time) (count int) {
+			for i := 0; i < n; i++ {
+				if ok, _ := l.allowOne(at); ok {
+					count++
+				}
+			}
+			return count
+		}
+		assert.Equal(2, allowed(5, now))
+		assert.Equal(1, allowed(5, now.Add(500*time.Millisecond)))
+		assert.Equal(2, allowed(5, now.Add(10*time.Second)), "bucket should not exceed burst")
+	})
+
+	t.Run("zero", func(t *testing.T) {
+		l := newRateLimiter(0)
+		ok, rate := l.allowOne(time.Now())
+		assert.False(t, ok)
+		assert.Equal(t, 0., rate)
+	})
+
+	t.Run("effective-rate", func(t *testing.T) {
+		assert := assert.New(t)
+		l := newRateLimiter(1)
+		now := time.Now()
+		_, rate := l.allowOne(now)
+		assert.Equal(1., rate)
+		_, rate = l.allowOne(now)
+		assert.Equal(0.5, rate)
+		_, rate = l.allowOne(now)
+		assert.True(math.Abs(rate-1./3) < 1e-9)
+
+		// the previous window is taken into account
+		_, rate = l.allowOne(now.Add(1500 * time.Millisecond))
+		assert.True(math.Abs(rate-(1+1./3)/2) < 1e-9)
+
+		// until it is too old
+		_, rate = l.allowOne(now.Add(5 * time.Second))
+		assert.Equal(1., rate)
+	})
+}
@@ -0,0 +1,184 @@
+package tracer
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	// sketchRelativeAccuracy is the relative accuracy of the sketches used to
+	// compute latency distributions: any quantile they return is within 1% of
+	// the exact value.
+	sketchRelativeAccuracy = 0.01
+
+	// sketchMaxBins is the maximum number of bins of a sketch. When it is
+	// reached, the lowest bins are collapsed, so that the accuracy is only
+	// lost for the lowest quantiles.
+	sketchMaxBins = 2048
+)
+
+// sketch is a DDSketch: a quantile sketch which guarantees that the quantiles
+// it returns are within a relative accuracy of the exact values, using a fixed
+// amount of memory. Values are counted in bins whose bounds grow exponentially,
+// so that bin i holds the values between gamma^i and gamma^(i+1). See
+// https://arxiv.org/abs/1908.10693.
+//
+// sketch only holds non-negative values, such as durations: negative values are
+// counted as zero. It is not safe for concurrent use.
+type sketch struct {
+	gamma      float64
+	multiplier float64 // 1/log(gamma)
+
+	bins      []float64 // counts of the bins, starting at index offset
+	offset    int
+	zeroCount float64
+	count     float64
+}
+
+// newSketch returns a sketch with the given relative accuracy, between 0 and 1.
+func newSketch(relativeAccuracy float64) *sketch {
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &sketch{gamma: gamma, multiplier: 1 / math.Log(gamma)}
+}
+
+// add adds the value v to the sketch.
+func (s *sketch) add(v float64) {
+	s.count++
+	if v <= 0 {
+		s.zeroCount++
+		return
+	}
+	i := s.index(v)
+	switch {
+	case len(s.bins) == 0:
+		s.bins = append(s.bins, 0)
+		s.offset = i
+	case i < s.offset:
+		if n := s.offset - i; len(s.bins)+n > sketchMaxBins {
+			// collapse into the lowest bin
+			i = s.offset
+		} else {
+			bins := make([]float64, len(s.bins)+n)
+			copy(bins[n:], s.bins)
+			s.bins, s.offset = bins, i
+		}
+	case i >= s.offset+len(s.bins):
+		n := i - s.offset - len(s.bins) + 1
+		s.bins = append(s.bins, make([]float64, n)...)
+		if extra := len(s.bins) - sketchMaxBins; extra > 0 {
+			s.collapse(extra)
+		}
+	}
+	s.bins[i-s.offset]++
+}
+
+// collapse merges the n lowest bins into the next one.
+func (s *sketch) collapse(n int) {
+	var sum float64
+	for _, c := range s.bins[:n] {
+		sum += c
+	}
+	s.bins = s.bins[n:]
+	s.bins[0] += sum
+	s.offset += n
+}
+
+// index returns the index of the bin holding v.
+func (s *sketch) index(v float64) int {
+	return int(math.Floor(math.Log(v) * s.multiplier))
+}
+
+// value returns the value representing the bin at the given index, which is
+// within the relative accuracy of all the values of the bin.
+func (s *sketch) value(index int) float64 {
+	return math.Pow(s.gamma, float64(index)) * 2 * s.gamma / (1 + s.gamma)
+}
+
+// quantile returns the value at quantile q, between 0 and 1, or 0 if the
+// sketch is empty.
+func (s *sketch) quantile(q float64) float64 {
+	if s.count == 0 || q < 0 || q > 1 {
+		return 0
+	}
+	rank := q * (s.count - 1)
+	n := s.zeroCount
+	if n > rank {
+		return 0
+	}
+	for i, c := range s.bins {
+		n += c
+		if n > rank {
+			return s.value(i + s.offset)
+		}
+	}
+	return s.value(s.offset + len(s.bins) - 1)
+}
+
+// encode returns the protobuf encoding of the sketch, as specified by the
+// DDSketch message of github.com/DataDog/sketches-go, which is understood by
+// the agent. Bins are encoded as contiguous bin counts.
+func (s *sketch) encode() []byte {
+	var mapping, store, b []byte
+	mapping = appendProtoDouble(mapping, 1, s.gamma) // gamma
+	// indexOffset (2) and interpolation (3) are zero
+
+	if len(s.bins) > 0 {
+		var counts []byte
+		for _, c := range s.bins {
+			counts = appendFixed64(counts, math.Float64bits(c))
+		}
+		store = appendProtoBytes(store, 2, counts)           // contiguousBinCounts
+		store = appendProtoSint32(store, 3, int32(s.offset)) // contiguousBinIndexOffset
+	}
+
+	b = appendProtoBytes(b, 1, mapping) // mapping
+	b = appendProtoBytes(b, 2, store)   // positiveValues
+	// negativeValues (3) are empty
+	if s.zeroCount != 0 {
+		b = appendProtoDouble(b, 4, s.zeroCount) // zeroCount
+	}
+	return b
+}
+
+// Protocol buffer wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// appendProtoDouble appends the given double field to b.
+func appendProtoDouble(b []byte, field int, v float64) []byte {
+	b = appendUvarint(b, uint64(field)<<3|protoFixed64)
+	return appendFixed64(b, math.Float64bits(v))
+}
+
+// appendProtoBytes appends the given length-delimited field, such as a message
+// or a packed repeated field, to b.
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendUvarint(b, uint64(field)<<3|protoBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendProtoSint32 appends the given zigzag-encoded sint32 field to b.
+func appendProtoSint32(b []byte, field int, v int32) []byte {
+	b = appendUvarint(b, uint64(field)<<3|protoVarint)
+	return appendUvarint(b, uint64(uint32(v<<1)^uint32(v>>31)))
+}
+
+// appendUvarint appends the varint encoding of v to b.
+func appendUvarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendFixed64 appends the little endian encoding of v to b.
+func appendFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
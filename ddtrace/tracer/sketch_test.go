@@ -0,0 +1,142 @@
+package tracer
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeSketch decodes the protobuf encoding of a sketch, as returned by encode.
+func decodeSketch(b []byte) (*sketch, error) {
+	s := new(sketch)
+	err := readProto(b, func(field int, v []byte, x uint64) error {
+		switch field {
+		case 1: // mapping
+			return readProto(v, func(field int, _ []byte, x uint64) error {
+				if field == 1 {
+					s.gamma = math.Float64frombits(x)
+					s.multiplier = 1 / math.Log(s.gamma)
+				}
+				return nil
+			})
+		case 2: // positiveValues
+			return readProto(v, func(field int, v []byte, x uint64) error {
+				switch field {
+				case 2:
+					for ; len(v) >= 8; v = v[8:] {
+						c := math.Float64frombits(binary.LittleEndian.Uint64(v))
+						s.bins = append(s.bins, c)
+						s.count += c
+					}
+				case 3:
+					s.offset = int(int32(uint32(x>>1) ^ -uint32(x&1)))
+				}
+				return nil
+			})
+		case 4: // zeroCount
+			s.zeroCount = math.Float64frombits(x)
+			s.count += s.zeroCount
+		}
+		return nil
+	})
+	return s, err
+}
+
+// readProto calls fn with each field of the protobuf message b, along with the
+// contents of length-delimited fields or the value of the others.
+func readProto(b []byte, fn func(field int, v []byte, x uint64) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("invalid tag")
+		}
+		b = b[n:]
+		var v []byte
+		var x uint64
+		switch tag & 7 {
+		case protoVarint:
+			x, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errors.New("invalid varint")
+			}
+			b = b[n:]
+		case protoFixed64:
+			if len(b) < 8 {
+				return errors.New("invalid fixed64")
+			}
+			x, b = binary.LittleEndian.Uint64(b), b[8:]
+		case protoBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errors.New("invalid length")
+			}
+			v, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return errors.New("unsupported wire type")
+		}
+		if err := fn(int(tag>>3), v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSketch(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	for name, gen := range map[string]func() float64{
+		"uniform":     func() float64 { return float64(r.Int63n(1e9)) },
+		"exponential": func() float64 { return math.Round(r.ExpFloat64() * 1e6) },
+		"lognormal":   func() float64 { return math.Round(math.Exp(r.NormFloat64()*3 + 10)) },
+		"constant":    func() float64 { return 1234 },
+		"zeros":       func() float64 { return float64(r.Intn(3)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newSketch(sketchRelativeAccuracy)
+			values := make([]float64, 10000)
+			for i := range values {
+				values[i] = gen()
+				s.add(values[i])
+			}
+			sort.Float64s(values)
+			decoded, err := decodeSketch(s.encode())
+			assert.NoError(t, err)
+			assert.Equal(t, s.count, decoded.count)
+			for _, q := range []float64{0, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.95, 0.99, 0.999, 1} {
+				want := values[int(q*float64(len(values)-1))]
+				for _, s := range []*sketch{s, decoded} {
+					got := s.quantile(q)
+					assert.InDelta(t, want, got, want*sketchRelativeAccuracy+1e-9, "quantile %v", q)
+				}
+			}
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		s := newSketch(sketchRelativeAccuracy)
+		assert.Zero(t, s.quantile(0.5))
+		decoded, err := decodeSketch(s.encode())
+		assert.NoError(t, err)
+		assert.Zero(t, decoded.count)
+	})
+
+	t.Run("collapse", func(t *testing.T) {
+		s := newSketch(sketchRelativeAccuracy)
+		// spans more bins than allowed, in both directions
+		for _, v := range []float64{1e10, 1e-20, 1e-30, 1e20, 1e30} {
+			s.add(v)
+		}
+		assert.Len(t, s.bins, sketchMaxBins)
+		assert.Equal(t, 5.0, s.count)
+		assert.InEpsilon(t, 1e30, s.quantile(1), sketchRelativeAccuracy)
+		assert.InEpsilon(t, 1e20, s.quantile(0.75), sketchRelativeAccuracy)
+		decoded, err := decodeSketch(s.encode())
+		assert.NoError(t, err)
+		assert.Equal(t, s.offset, decoded.offset)
+		assert.InEpsilon(t, 1e30, decoded.quantile(1), sketchRelativeAccuracy)
+	})
+}
@@ -8,11 +8,13 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tinylib/msgp/msgp"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 )
 
 type (
@@ -49,6 +51,7 @@ type span struct {
 	Error    int32              `msg:"error"`             // error status of the span; 0 means no errors
 
 	finished bool         `msg:"-"` // true if the span has been submitted to a tracer.
+	topLevel bool         `msg:"-"` // true if the span is the root of its trace or service in this process.
 	context  *spanContext `msg:"-"` // span propagation context
 }
 
@@ -84,6 +87,12 @@ func (s *span) SetTag(key string, value interface{}) {
 		s.setTagError(value, true)
 		return
 	}
+	if key == ext.ManualKeep || key == ext.ManualDrop {
+		if internal.IsManualPriority(value) {
+			s.setUserPriority(key)
+		}
+		return
+	}
 	if v, ok := value.(string); ok {
 		s.setTagString(key, v)
 		return
@@ -157,6 +166,19 @@ func (s *span) setTagNumeric(key string, v float64) {
 	}
 }
 
+// setUserPriority sets the sampling priority of the whole trace, as chosen by
+// the user with the ext.ManualKeep or ext.ManualDrop tag key. It overrides the
+// decisions of the samplers. This method is not safe for concurrent use.
+func (s *span) setUserPriority(key string) {
+	p := ext.PriorityUserKeep
+	if key == ext.ManualDrop {
+		p = ext.PriorityUserReject
+	}
+	s.Metrics[samplingPriorityKey] = float64(p)
+	s.context.setSamplingPriority(p)
+	s.context.trace.setUserPriority(p)
+}
+
 // Finish closes this Span (but not its children) providing the duration
 // of its part of the tracing session.
 func (s *span) Finish(opts ...ddtrace.FinishOption) {
@@ -188,23 +210,32 @@ func (s *span) SetOperationName(operationName string) {
 
 func (s *span) finish(finishTime int64) {
 	s.Lock()
-	defer s.Unlock()
 	// We don't lock spans when flushing, so we could have a data race when
 	// modifying a span as it's being flushed. This protects us against that
 	// race, since spans are marked `finished` before we flush them.
 	if s.finished {
 		// already finished
+		s.Unlock()
 		return
 	}
 	if s.Duration == 0 {
 		s.Duration = finishTime - s.Start
 	}
 	s.finished = true
-
-	if !s.context.sampled {
-		// not sampled
-		return
+	// Once finished, the span can no longer be modified by its owner. It is
+	// unlocked before being acknowledged, as span processors lock it while
+	// the trace is processed, which may happen before ackFinish returns.
+	s.Unlock()
+	t, ok := internal.GetGlobalTracer().(*tracer)
+	if ok {
+		atomic.AddUint64(&t.health.spansFinished, 1)
+		if t.openSpans != nil {
+			t.openSpans.remove(s)
+		}
 	}
+
+	// the trace is acknowledged even when it is not sampled, as the user may
+	// still decide to keep it until it completes
 	s.context.finish()
 }
 
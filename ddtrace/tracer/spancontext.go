@@ -1,13 +1,15 @@
 package tracer
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 )
 
-var _ ddtrace.SpanContext = (*spanContext)(nil)
+var _ ddtrace.SpanContextW3C = (*spanContext)(nil)
 
 // SpanContext represents a span state that can propagate to descendant spans
 // and across process boundaries. It contains all the information needed to
@@ -25,6 +27,18 @@ type spanContext struct {
 	traceID uint64
 	spanID  uint64
 
+	// traceIDHigh holds the upper 64 bits of 128-bit trace IDs received from
+	// other tracers, allowing them to be propagated back unchanged.
+	traceIDHigh uint64
+
+	// tracestate holds the W3C tracestate received along with the context,
+	// preserving the entries of other vendors.
+	tracestate string
+
+	// origin holds the origin of the trace received along with the context,
+	// such as "synthetics" for traces started by synthetic tests.
+	origin string
+
 	mu          sync.RWMutex // guards below fields
 	baggage     map[string]string
 	priority    int
@@ -50,6 +64,9 @@ func newSpanContext(span *span, parent *spanContext) *spanContext {
 	if parent != nil {
 		context.trace = parent.trace
 		context.sampled = parent.sampled
+		context.traceIDHigh = parent.traceIDHigh
+		context.tracestate = parent.tracestate
+		context.origin = parent.origin
 		context.hasPriority = parent.hasSamplingPriority()
 		context.priority = parent.samplingPriority()
 		parent.ForeachBaggageItem(func(k, v string) bool {
@@ -65,6 +82,11 @@ func newSpanContext(span *span, parent *spanContext) *spanContext {
 	return context
 }
 
+// TraceID128 implements ddtrace.SpanContextW3C.
+func (c *spanContext) TraceID128() string {
+	return fmt.Sprintf("%016x%016x", c.traceIDHigh, c.traceID)
+}
+
 // SpanID implements ddtrace.SpanContext.
 func (c *spanContext) SpanID() uint64 { return c.spanID }
 
@@ -90,12 +112,18 @@ func (c *spanContext) setSamplingPriority(p int) {
 }
 
 func (c *spanContext) samplingPriority() int {
+	if p, ok := c.trace.userPriority(); ok {
+		return p
+	}
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.priority
 }
 
 func (c *spanContext) hasSamplingPriority() bool {
+	if _, ok := c.trace.userPriority(); ok {
+		return true
+	}
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.hasPriority
@@ -116,8 +144,17 @@ func (c *spanContext) baggageItem(key string) string {
 	return c.baggage[key]
 }
 
+// isSampled reports whether the trace of this span is kept, as decided by the
+// user or otherwise by the samplers.
+func (c *spanContext) isSampled() bool {
+	if p, ok := c.trace.userPriority(); ok {
+		return p > 0
+	}
+	return c.sampled
+}
+
 // finish marks this span as finished in the trace.
-func (c *spanContext) finish() { c.trace.ackFinish() }
+func (c *spanContext) finish() { c.trace.ackFinish(c.span) }
 
 // trace holds information about a specific trace. This structure is shared
 // between all spans in a trace.
@@ -126,6 +163,22 @@ type trace struct {
 	spans    []*span      // all the spans that are part of this trace
 	finished int          // the number of finished spans
 	full     bool         // signifies that the span buffer is full
+
+	// partial holds the finished spans which are awaiting a partial flush.
+	// It is only used when partial flushing is enabled.
+	partial []*span
+	// chunks holds the number of chunks of this trace which were partially
+	// flushed so far.
+	chunks int
+
+	// priority holds the sampling priority chosen by the user with ext.ManualKeep
+	// or ext.ManualDrop, when locked is true. It overrides the priorities of the
+	// spans and the decisions of the samplers, even if they already ran. These
+	// are guarded by prioMu rather than mu, as the worker reads them while the
+	// trace is locked to push it.
+	prioMu   sync.RWMutex
+	priority int
+	locked   bool
 }
 
 var (
@@ -141,12 +194,68 @@ var (
 	traceMaxSize = int(1e5)
 )
 
+// partialFlushMetricKey is the metric set on the first span of every chunk of
+// a partially flushed trace. It holds the sequence number of the chunk, starting
+// at 1, allowing the agent to identify and reassemble the chunks of a trace,
+// which all share the same trace ID.
+const partialFlushMetricKey = "_dd.partial_flush"
+
+// traceIDHighMetaKey is the meta tag set on the first span of every chunk of a
+// trace having a 128-bit trace ID. It holds the upper 64 bits of the trace ID,
+// as 16 lowercase hex characters.
+const traceIDHighMetaKey = "_dd.p.tid"
+
+// originMetaKey is the meta tag set on every span of a trace having an origin.
+// It holds the origin received along with the context of the trace.
+const originMetaKey = "_dd.origin"
+
+// setTraceIDHigh sets the upper 64 bits of the trace ID of the finished span s
+// as a tag, if they are not zero.
+func setTraceIDHigh(s *span) {
+	if h := s.context.traceIDHigh; h != 0 {
+		s.Meta[traceIDHighMetaKey] = fmt.Sprintf("%016x", h)
+	}
+}
+
 // newTrace creates a new trace using the given callback which will be called
 // upon completion of the trace.
 func newTrace() *trace {
 	return &trace{spans: make([]*span, 0, traceStartSize)}
 }
 
+// setUserPriority sets the sampling priority p chosen by the user for the trace.
+func (t *trace) setUserPriority(p int) {
+	t.prioMu.Lock()
+	defer t.prioMu.Unlock()
+	t.priority = p
+	t.locked = true
+}
+
+// userPriority returns the sampling priority chosen by the user for the trace,
+// if any. The trace may be nil, as for contexts extracted from carriers.
+func (t *trace) userPriority() (p int, ok bool) {
+	if t == nil {
+		return 0, false
+	}
+	t.prioMu.RLock()
+	defer t.prioMu.RUnlock()
+	return t.priority, t.locked
+}
+
+// keep reports whether the given chunk of the trace is kept, rather than only
+// being needed to compute stats. If the user chose a sampling priority, it is
+// set on all the spans of the chunk. Callers must guard the trace.
+func (t *trace) keep(chunk []*span) bool {
+	p, ok := t.userPriority()
+	if !ok {
+		return chunk[0].context.sampled
+	}
+	for _, s := range chunk {
+		s.Metrics[samplingPriorityKey] = float64(p)
+	}
+	return p > 0
+}
+
 // push pushes a new span into the trace. If the buffer is full, it returns
 // a errBufferFull error.
 func (t *trace) push(sp *span) {
@@ -161,6 +270,7 @@ func (t *trace) push(sp *span) {
 		t.spans = nil // GC
 		if tr, ok := internal.GetGlobalTracer().(*tracer); ok {
 			// we have a tracer we can submit errors too.
+			atomic.AddUint64(&tr.health.tracesDroppedTooLarge, 1)
 			tr.pushError(&spanBufferFullError{})
 		}
 		return
@@ -169,8 +279,10 @@ func (t *trace) push(sp *span) {
 }
 
 // ackFinish aknowledges that another span in the trace has finished, and checks
-// if the trace is complete, in which case it calls the onFinish function.
-func (t *trace) ackFinish() {
+// if the trace is complete, in which case it is pushed to the tracer. When partial
+// flushing is enabled and enough spans have finished, these are pushed as a
+// chunk of the trace, without waiting for the rest of the trace to complete.
+func (t *trace) ackFinish(s *span) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	if t.full {
@@ -181,13 +293,55 @@ func (t *trace) ackFinish() {
 		return
 	}
 	t.finished++
-	if len(t.spans) != t.finished {
+	tr, ok := internal.GetGlobalTracer().(*tracer)
+	if len(t.spans) == t.finished {
+		if ok && (t.keep(t.spans) || tr.stats != nil) {
+			// we have a tracer that can receive completed traces, and the
+			// trace is either kept or needed to compute stats.
+			if t.chunks > 0 {
+				t.spans[0].Metrics[partialFlushMetricKey] = float64(t.chunks + 1)
+			}
+			setTraceIDHigh(t.spans[0])
+			tr.pushTrace(t.spans)
+		}
+		t.spans = nil
+		t.partial = nil
+		t.chunks = 0
+		t.finished = 0 // important, because a buffer can be used for several flushes
+		return
+	}
+	if !ok || tr.config.partialFlushMinSpans <= 0 {
 		return
 	}
-	if tr, ok := internal.GetGlobalTracer().(*tracer); ok {
-		// we have a tracer that can receive completed traces.
-		tr.pushTrace(t.spans)
+	t.partial = append(t.partial, s)
+	if len(t.partial) >= tr.config.partialFlushMinSpans {
+		t.flushPartial(tr)
+	}
+}
+
+// flushPartial pushes the finished spans awaiting a partial flush to the tracer
+// and removes them from the trace. Callers must guard the trace.
+func (t *trace) flushPartial(tr *tracer) {
+	chunk := t.partial
+	flushed := make(map[*span]struct{}, len(chunk))
+	for _, s := range chunk {
+		flushed[s] = struct{}{}
+	}
+	leftover := make([]*span, 0, len(t.spans)-len(chunk))
+	for _, s := range t.spans {
+		if _, ok := flushed[s]; !ok {
+			leftover = append(leftover, s)
+		}
+	}
+	t.chunks++
+	// All spans in the chunk are finished and can no longer be modified
+	// by their owners, so it is safe to set metadata on them.
+	chunk[0].Metrics[partialFlushMetricKey] = float64(t.chunks)
+	setTraceIDHigh(chunk[0])
+	if t.keep(chunk) || tr.stats != nil {
+		tr.pushPartialTrace(chunk)
 	}
-	t.spans = nil
-	t.finished = 0 // important, because a buffer can be used for several flushes
+	t.spans = leftover
+	t.finished -= len(chunk)
+	t.partial = nil
 }
@@ -1,6 +1,7 @@
 package tracer
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -149,6 +150,78 @@ func TestSpanTracePushSeveral(t *testing.T) {
 	}
 }
 
+func TestSpanTracePartialFlush(t *testing.T) {
+	assert := assert.New(t)
+
+	tracer, transport, stop := startTestTracer(WithPartialFlushing(2))
+	defer stop()
+
+	root := tracer.newRootSpan("root", "s", "r")
+	children := make([]*span, 5)
+	for i := range children {
+		children[i] = tracer.newChildSpan("child", root)
+	}
+	trace := root.context.trace
+
+	children[0].Finish()
+	tracer.forceFlush()
+	assert.Len(transport.Traces(), 0, "not enough spans finished")
+
+	children[1].Finish()
+	tracer.forceFlush()
+	traces := transport.Traces()
+	assert.Len(traces, 1)
+	assert.Len(traces[0], 2)
+	assert.Equal(children[0].SpanID, traces[0][0].SpanID)
+	assert.Equal(children[1].SpanID, traces[0][1].SpanID)
+	assert.Equal(1., traces[0][0].Metrics[partialFlushMetricKey])
+	assert.Len(trace.spans, 4, "flushed spans are removed from the trace")
+	assert.Equal(0, trace.finished)
+
+	children[2].Finish()
+	children[3].Finish()
+	children[4].Finish()
+	root.Finish()
+	assert.EqualValues(1, atomic.LoadUint64(&tracer.partialFlushes), "the last chunk is not a partial flush")
+	tracer.forceFlush()
+	traces = transport.Traces()
+	assert.Len(traces, 2)
+	assert.Len(traces[0], 2)
+	assert.Equal(2., traces[0][0].Metrics[partialFlushMetricKey])
+	assert.Len(traces[1], 2, "the remaining spans are flushed when the trace completes")
+	assert.Equal(3., traces[1][0].Metrics[partialFlushMetricKey])
+	for _, trc := range traces {
+		for _, s := range trc {
+			assert.Equal(root.TraceID, s.TraceID)
+		}
+	}
+	assert.Len(trace.spans, 0)
+	assert.EqualValues(0, atomic.LoadUint64(&tracer.partialFlushes))
+}
+
+func TestSpanTracePartialFlushMaxSize(t *testing.T) {
+	defer setupteardown(2, 5)()
+	assert := assert.New(t)
+
+	tracer, transport, stop := startTestTracer(WithPartialFlushing(3))
+	defer stop()
+
+	root := tracer.newRootSpan("root", "s", "r")
+	for i := 0; i < 12; i++ {
+		// without partial flushing, the trace would exceed traceMaxSize
+		tracer.newChildSpan("child", root).Finish()
+	}
+	root.Finish()
+	tracer.forceFlush()
+
+	assert.Len(tracer.errorBuffer, 0)
+	var n int
+	for _, trc := range transport.Traces() {
+		n += len(trc)
+	}
+	assert.Equal(13, n)
+}
+
 func TestNewSpanContext(t *testing.T) {
 	t.Run("basic", func(t *testing.T) {
 		span := &span{
@@ -0,0 +1,207 @@
+package tracer
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSpanTimeout is the default time after which a trace which still has
+	// open spans is reported as abandoned in debug spans mode.
+	defaultSpanTimeout = 10 * time.Minute
+
+	// maxReportedTraces is the maximum number of abandoned traces logged at each
+	// periodic report. The others are logged by the following reports.
+	maxReportedTraces = 100
+
+	// openSpansMaxInterval is the maximum interval at which abandoned traces
+	// are looked for.
+	openSpansMaxInterval = time.Minute
+
+	// ddtracePackagePrefix is the prefix of the functions of the tracing library,
+	// which are skipped when looking for the location where a span is started.
+	ddtracePackagePrefix = "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/"
+)
+
+// spanTracker tracks the spans which were started but not yet finished, along
+// with the location where they were started, in order to find the spans which
+// are never finished, preventing their trace from being sent. It is only used
+// in debug spans mode, see WithDebugSpansMode.
+type spanTracker struct {
+	mu     sync.Mutex // guards traces
+	traces map[*trace]*openTrace
+}
+
+// openTrace holds the open spans of a trace.
+type openTrace struct {
+	start    time.Time        // start time of the first span tracked, normally the root
+	spans    map[*span]string // open spans, and the file:line where they were started
+	reported bool             // whether the trace was already logged as abandoned
+}
+
+// openSpan is an open span, along with the location where it was started.
+type openSpan struct {
+	span     *span
+	location string
+}
+
+// abandonedTrace describes a trace having open spans, as logged by the tracer.
+type abandonedTrace struct {
+	start time.Time
+	spans []openSpan
+}
+
+func newSpanTracker() *spanTracker {
+	return &spanTracker{traces: make(map[*trace]*openTrace)}
+}
+
+// add tracks the span s, which was just started at the given location.
+func (st *spanTracker) add(s *span, location string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	ot, ok := st.traces[s.context.trace]
+	if !ok {
+		ot = &openTrace{start: time.Unix(0, s.Start), spans: make(map[*span]string)}
+		st.traces[s.context.trace] = ot
+	}
+	ot.spans[s] = location
+}
+
+// remove stops tracking the span s, which just finished.
+func (st *spanTracker) remove(s *span) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	ot, ok := st.traces[s.context.trace]
+	if !ok {
+		return
+	}
+	delete(ot.spans, s)
+	if len(ot.spans) == 0 {
+		delete(st.traces, s.context.trace)
+	}
+}
+
+// counts returns the number of open spans, and the number of traces which have
+// been open for longer than timeout at the given time.
+func (st *spanTracker) counts(now time.Time, timeout time.Duration) (spans, abandoned int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, ot := range st.traces {
+		spans += len(ot.spans)
+		if now.Sub(ot.start) > timeout {
+			abandoned++
+		}
+	}
+	return spans, abandoned
+}
+
+// abandoned returns up to max traces, oldest first, which have been open for
+// longer than timeout at the given time and were not yet returned, along with
+// the number of such traces left out. When max is zero, all the open traces are
+// returned, including those which were already returned.
+func (st *spanTracker) abandoned(now time.Time, timeout time.Duration, max int) (traces []abandonedTrace, more int) {
+	st.mu.Lock()
+	var open []*openTrace
+	for _, ot := range st.traces {
+		if max == 0 || (!ot.reported && now.Sub(ot.start) > timeout) {
+			open = append(open, ot)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].start.Before(open[j].start) })
+	if max > 0 && len(open) > max {
+		open, more = open[:max], len(open)-max
+	}
+	for _, ot := range open {
+		ot.reported = true
+		at := abandonedTrace{start: ot.start, spans: make([]openSpan, 0, len(ot.spans))}
+		for s, loc := range ot.spans {
+			at.spans = append(at.spans, openSpan{span: s, location: loc})
+		}
+		traces = append(traces, at)
+	}
+	st.mu.Unlock()
+
+	for _, at := range traces {
+		sort.Slice(at.spans, func(i, j int) bool { return at.spans[i].span.Start < at.spans[j].span.Start })
+	}
+	return traces, more
+}
+
+// describe returns the description of the trace which is logged at the given
+// time. It must not be called while holding the tracker's lock, as the spans
+// are locked to read their names, while finishing spans lock the tracker.
+func (at abandonedTrace) describe(now time.Time) string {
+	var b strings.Builder
+	for i, o := range at.spans {
+		if i == 0 {
+			fmt.Fprintf(&b, "trace %d open for %s, unfinished spans: ", o.span.TraceID, now.Sub(at.start).Round(time.Second))
+		} else {
+			b.WriteString(", ")
+		}
+		o.span.RLock()
+		name := o.span.Name
+		o.span.RUnlock()
+		fmt.Fprintf(&b, "%s (%s)", name, o.location)
+	}
+	return b.String()
+}
+
+// spanStartLocation returns the file:line of the first caller outside of the
+// tracing library, which is where the span being started is created.
+func spanStartLocation() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, ddtracePackagePrefix) || strings.HasSuffix(f.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// reportOpenSpans logs the traces which have been open for longer than the
+// span timeout at the given interval, until the tracer is stopped, at which
+// point all the spans which are still open are logged.
+func (t *tracer) reportOpenSpans(interval time.Duration) {
+	defer t.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.logOpenSpans(false)
+		case <-t.stopped:
+			t.logOpenSpans(true)
+			return
+		}
+	}
+}
+
+// logOpenSpans logs the traces which have been open for longer than the span
+// timeout and were not yet logged, or all the open traces when stopping.
+func (t *tracer) logOpenSpans(stopping bool) {
+	now := time.Now()
+	max := maxReportedTraces
+	if stopping {
+		max = 0
+	}
+	traces, more := t.openSpans.abandoned(now, t.config.spanTimeout, max)
+	for _, at := range traces {
+		if stopping {
+			t.config.logger.Warn("Tracer stopped with open spans: " + at.describe(now))
+		} else {
+			t.config.logger.Warn("Abandoned " + at.describe(now))
+		}
+	}
+	if more > 0 {
+		t.config.logger.Warn(fmt.Sprintf("%d more abandoned traces will be logged later", more))
+	}
+}
@@ -0,0 +1,96 @@
+package tracer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpanTracker(t *testing.T) {
+	t.Run("abandoned", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, stop := startTestTracer(WithDebugSpansMode(time.Hour))
+		defer stop()
+
+		root := tracer.StartSpan("http.request")
+		child := tracer.StartSpan("db.query", ChildOf(root.Context()))
+		tracer.StartSpan("done", ChildOf(root.Context())).Finish()
+		other := tracer.StartSpan("other")
+
+		now := time.Now()
+		spans, abandoned := tracer.openSpans.counts(now, time.Hour)
+		assert.Equal(3, spans)
+		assert.Equal(0, abandoned)
+		_, abandoned = tracer.openSpans.counts(now.Add(2*time.Hour), time.Hour)
+		assert.Equal(2, abandoned)
+
+		traces, more := tracer.openSpans.abandoned(now.Add(2*time.Hour), time.Hour, 1)
+		assert.Equal(1, more)
+		if assert.Len(traces, 1) {
+			desc := traces[0].describe(now.Add(2 * time.Hour))
+			assert.True(strings.HasPrefix(desc, "trace "), desc)
+			assert.Contains(desc, " open for 2h0m0s, unfinished spans: http.request (")
+			assert.Contains(desc, "spantracker_test.go:")
+			assert.Contains(desc, "), db.query (")
+			assert.NotContains(desc, "done")
+		}
+		// traces are only returned once
+		traces, more = tracer.openSpans.abandoned(now.Add(2*time.Hour), time.Hour, 1)
+		assert.Equal(0, more)
+		if assert.Len(traces, 1) {
+			assert.Contains(traces[0].describe(now), "other (")
+		}
+		traces, _ = tracer.openSpans.abandoned(now.Add(2*time.Hour), time.Hour, 1)
+		assert.Len(traces, 0)
+
+		child.Finish()
+		root.Finish()
+		other.Finish()
+		spans, _ = tracer.openSpans.counts(now, time.Hour)
+		assert.Equal(0, spans)
+		assert.Len(tracer.openSpans.traces, 0)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		assert := assert.New(t)
+		l := new(recordLogger)
+		tracer, _, stop := startTestTracer(WithLogger(l), WithDebugSpansMode(time.Hour))
+		tracer.StartSpan("leaked")
+		tracer.StartSpan("finished").Finish()
+		stop()
+
+		lines := l.Lines()
+		if assert.Len(lines, 1) {
+			assert.True(strings.HasPrefix(lines[0], "WARN: Tracer stopped with open spans: trace "), lines[0])
+			assert.Contains(lines[0], "unfinished spans: leaked (")
+			assert.Contains(lines[0], "spantracker_test.go:")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer, _, stop := startTestTracer()
+		defer stop()
+		assert.Nil(t, tracer.openSpans)
+		tracer.StartSpan("op").Finish()
+	})
+}
+
+func TestSpanTrackerMetrics(t *testing.T) {
+	assert := assert.New(t)
+	srv := newTestStatsdServer(t)
+	defer srv.close()
+
+	tracer, _, stop := startTestTracer(
+		WithDogstatsdAddress(srv.addr()),
+		WithDebugSpansMode(time.Hour),
+	)
+	tracer.StartSpan("leaked")
+	tracer.reportHealthStats()
+	stop()
+
+	packets := srv.wait(100, 200*time.Millisecond)
+	assert.Contains(values(find(packets, metricSpansOpen)), "1")
+	assert.Contains(values(find(packets, metricTracesAbandoned)), "0")
+}
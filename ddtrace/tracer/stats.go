@@ -0,0 +1,230 @@
+package tracer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// defaultStatsBucketSize is the default duration of the time buckets in which
+// the stats of spans are aggregated.
+const defaultStatsBucketSize = 10 * time.Second
+
+// aggregationKey identifies the spans whose stats are aggregated together.
+type aggregationKey struct {
+	service, name, resource, typ string
+	httpStatusCode               uint32
+}
+
+// groupedStats holds the stats of the spans sharing an aggregation key.
+type groupedStats struct {
+	hits, topLevelHits, errors uint64
+	duration                   uint64 // total duration of the spans, in nanoseconds
+
+	// okDistribution and errDistribution hold the durations of the spans
+	// without and with errors.
+	okDistribution, errDistribution *sketch
+}
+
+// concentrator aggregates the stats of finished top-level spans into time
+// buckets, by the time at which they ended, and releases them once they are
+// complete. The stats of all traces are aggregated, whether they are sampled
+// or not, so that they are accurate regardless of sampling. It is only
+// accessed by the worker.
+type concentrator struct {
+	bucketSize int64 // in nanoseconds
+
+	// buckets holds the stats of each bucket not yet flushed, by start time.
+	buckets map[int64]map[aggregationKey]*groupedStats
+
+	// oldest is the start time of the oldest bucket which was not yet flushed.
+	// Spans ending before are aggregated into it.
+	oldest int64
+
+	// obfuscation is used to aggregate the spans by their obfuscated resource, as
+	// the stats are computed before the traces are obfuscated. It may be nil.
+	obfuscation *obfuscationConfig
+}
+
+// newConcentrator returns a concentrator aggregating stats into buckets of the
+// given duration.
+func newConcentrator(bucketSize time.Duration) *concentrator {
+	return &concentrator{
+		bucketSize: int64(bucketSize),
+		buckets:    make(map[int64]map[aggregationKey]*groupedStats),
+	}
+}
+
+// add aggregates the stats of the top-level spans of the given trace chunk.
+// A span is top-level when it is the root of the trace in this process, or
+// when its service differs from its parent's.
+func (c *concentrator) add(trace []*span) {
+	for _, s := range trace {
+		if !s.topLevel {
+			continue
+		}
+		end := s.Start + s.Duration
+		start := end - end%c.bucketSize
+		if start < c.oldest {
+			start = c.oldest
+		}
+		bucket, ok := c.buckets[start]
+		if !ok {
+			bucket = make(map[aggregationKey]*groupedStats)
+			c.buckets[start] = bucket
+		}
+		key := aggregationKey{
+			service:  s.Service,
+			name:     s.Name,
+			resource: c.obfuscation.resource(s),
+			typ:      s.Type,
+		}
+		if code, err := strconv.ParseUint(s.Meta[ext.HTTPCode], 10, 32); err == nil {
+			key.httpStatusCode = uint32(code)
+		}
+		gs, ok := bucket[key]
+		if !ok {
+			gs = &groupedStats{
+				okDistribution:  newSketch(sketchRelativeAccuracy),
+				errDistribution: newSketch(sketchRelativeAccuracy),
+			}
+			bucket[key] = gs
+		}
+		gs.hits++
+		gs.topLevelHits++
+		gs.duration += uint64(s.Duration)
+		if s.Error != 0 {
+			gs.errors++
+			gs.errDistribution.add(float64(s.Duration))
+		} else {
+			gs.okDistribution.add(float64(s.Duration))
+		}
+	}
+}
+
+// flush removes the buckets which are complete at the given time from the
+// concentrator and returns them, or all of them when all is true. Buckets are
+// considered complete once the next one has ended too, so that the spans which
+// are late to be reported still make it in their bucket.
+func (c *concentrator) flush(now time.Time, all bool) []statsBucket {
+	ts := now.UnixNano()
+	var flushed []statsBucket
+	for start, bucket := range c.buckets {
+		if !all && start+2*c.bucketSize > ts {
+			continue
+		}
+		b := statsBucket{Start: uint64(start), Duration: uint64(c.bucketSize)}
+		for key, gs := range bucket {
+			b.Stats = append(b.Stats, groupedStatsPayload{
+				Service:        key.service,
+				Name:           key.name,
+				Resource:       key.resource,
+				Type:           key.typ,
+				HTTPStatusCode: key.httpStatusCode,
+				Hits:           gs.hits,
+				TopLevelHits:   gs.topLevelHits,
+				Errors:         gs.errors,
+				Duration:       gs.duration,
+				OkSummary:      gs.okDistribution.encode(),
+				ErrorSummary:   gs.errDistribution.encode(),
+			})
+		}
+		flushed = append(flushed, b)
+		delete(c.buckets, start)
+		if start+c.bucketSize > c.oldest {
+			c.oldest = start + c.bucketSize
+		}
+	}
+	return flushed
+}
+
+// statsPayload holds the stats buckets sent to the agent's stats endpoint. It
+// is encoded as the ClientStatsPayload understood by the agent.
+type statsPayload struct {
+	Hostname      string
+	Env           string
+	Version       string
+	Service       string
+	Lang          string
+	TracerVersion string
+	Sequence      uint64
+	Stats         []statsBucket
+}
+
+// statsBucket holds the stats aggregated over a time bucket.
+type statsBucket struct {
+	Start    uint64 // in nanoseconds since epoch
+	Duration uint64 // in nanoseconds
+	Stats    []groupedStatsPayload
+}
+
+// groupedStatsPayload holds the stats of the spans sharing an aggregation key,
+// with their latency distributions encoded as DDSketch protobuf messages.
+type groupedStatsPayload struct {
+	Service        string
+	Name           string
+	Resource       string
+	Type           string
+	HTTPStatusCode uint32
+	Hits           uint64
+	TopLevelHits   uint64
+	Errors         uint64
+	Duration       uint64
+	OkSummary      []byte
+	ErrorSummary   []byte
+}
+
+// encode returns the msgpack encoding of the payload.
+func (p *statsPayload) encode() []byte {
+	b := msgp.AppendMapHeader(nil, 8)
+	b = appendStringField(b, "Hostname", p.Hostname)
+	b = appendStringField(b, "Env", p.Env)
+	b = appendStringField(b, "Version", p.Version)
+	b = appendStringField(b, "Service", p.Service)
+	b = appendStringField(b, "Lang", p.Lang)
+	b = appendStringField(b, "TracerVersion", p.TracerVersion)
+	b = msgp.AppendString(b, "Sequence")
+	b = msgp.AppendUint64(b, p.Sequence)
+	b = msgp.AppendString(b, "Stats")
+	b = msgp.AppendArrayHeader(b, uint32(len(p.Stats)))
+	for _, bucket := range p.Stats {
+		b = msgp.AppendMapHeader(b, 3)
+		b = msgp.AppendString(b, "Start")
+		b = msgp.AppendUint64(b, bucket.Start)
+		b = msgp.AppendString(b, "Duration")
+		b = msgp.AppendUint64(b, bucket.Duration)
+		b = msgp.AppendString(b, "Stats")
+		b = msgp.AppendArrayHeader(b, uint32(len(bucket.Stats)))
+		for _, gs := range bucket.Stats {
+			b = msgp.AppendMapHeader(b, 11)
+			b = appendStringField(b, "Service", gs.Service)
+			b = appendStringField(b, "Name", gs.Name)
+			b = appendStringField(b, "Resource", gs.Resource)
+			b = appendStringField(b, "Type", gs.Type)
+			b = msgp.AppendString(b, "HTTPStatusCode")
+			b = msgp.AppendUint32(b, gs.HTTPStatusCode)
+			b = msgp.AppendString(b, "Hits")
+			b = msgp.AppendUint64(b, gs.Hits)
+			b = msgp.AppendString(b, "TopLevelHits")
+			b = msgp.AppendUint64(b, gs.TopLevelHits)
+			b = msgp.AppendString(b, "Errors")
+			b = msgp.AppendUint64(b, gs.Errors)
+			b = msgp.AppendString(b, "Duration")
+			b = msgp.AppendUint64(b, gs.Duration)
+			b = msgp.AppendString(b, "OkSummary")
+			b = msgp.AppendBytes(b, gs.OkSummary)
+			b = msgp.AppendString(b, "ErrorSummary")
+			b = msgp.AppendBytes(b, gs.ErrorSummary)
+		}
+	}
+	return b
+}
+
+// appendStringField appends the key and value of a string field of a map to b.
+func appendStringField(b []byte, key, value string) []byte {
+	b = msgp.AppendString(b, key)
+	return msgp.AppendString(b, value)
+}
@@ -0,0 +1,252 @@
+package tracer
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinylib/msgp/msgp"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// newStatsTestSpan returns a finished top-level span ending at the given time.
+func newStatsTestSpan(name, resource string, end time.Time, duration time.Duration) *span {
+	s := newSpan(name, "svc", resource, 1, 1, 0)
+	s.Start = end.Add(-duration).UnixNano()
+	s.Duration = int64(duration)
+	s.topLevel = true
+	return s
+}
+
+func TestConcentrator(t *testing.T) {
+	bucketSize := 10 * time.Second
+	start := time.Unix(1600000000, 0) // aligned on a bucket
+
+	t.Run("aggregation", func(t *testing.T) {
+		assert := assert.New(t)
+		c := newConcentrator(bucketSize)
+		ok := newStatsTestSpan("http.request", "GET /", start.Add(time.Second), time.Millisecond)
+		ok.Meta[ext.HTTPCode] = "200"
+		failed := newStatsTestSpan("http.request", "GET /", start.Add(2*time.Second), 3*time.Millisecond)
+		failed.Meta[ext.HTTPCode] = "200"
+		failed.Error = 1
+		notFound := newStatsTestSpan("http.request", "GET /", start.Add(3*time.Second), time.Millisecond)
+		notFound.Meta[ext.HTTPCode] = "404"
+		child := newStatsTestSpan("db.query", "SELECT", start.Add(time.Second), time.Millisecond)
+		child.topLevel = false
+		other := newStatsTestSpan("http.request", "GET /", start.Add(bucketSize), time.Millisecond)
+		c.add([]*span{ok, failed, notFound, child, other})
+
+		buckets := c.flush(start.Add(3*bucketSize), false)
+		assert.Len(buckets, 2)
+		var b statsBucket
+		for _, bucket := range buckets {
+			if bucket.Start == uint64(start.UnixNano()) {
+				b = bucket
+			}
+		}
+		assert.EqualValues(bucketSize, b.Duration)
+		assert.Len(b.Stats, 2)
+		for _, gs := range b.Stats {
+			assert.Equal("svc", gs.Service)
+			assert.Equal("http.request", gs.Name)
+			assert.Equal("GET /", gs.Resource)
+			switch gs.HTTPStatusCode {
+			case 200:
+				assert.EqualValues(2, gs.Hits)
+				assert.EqualValues(2, gs.TopLevelHits)
+				assert.EqualValues(1, gs.Errors)
+				assert.EqualValues(4*time.Millisecond, gs.Duration)
+				okDist, err := decodeSketch(gs.OkSummary)
+				assert.NoError(err)
+				assert.Equal(1.0, okDist.count)
+				assert.InEpsilon(float64(time.Millisecond), okDist.quantile(0.5), sketchRelativeAccuracy)
+				errDist, err := decodeSketch(gs.ErrorSummary)
+				assert.NoError(err)
+				assert.Equal(1.0, errDist.count)
+				assert.InEpsilon(float64(3*time.Millisecond), errDist.quantile(0.5), sketchRelativeAccuracy)
+			case 404:
+				assert.EqualValues(1, gs.Hits)
+				assert.EqualValues(0, gs.Errors)
+			default:
+				assert.Fail("unexpected status code", gs.HTTPStatusCode)
+			}
+		}
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		assert := assert.New(t)
+		c := newConcentrator(bucketSize)
+		c.add([]*span{newStatsTestSpan("a", "a", start.Add(time.Second), time.Millisecond)})
+
+		// the bucket is kept until the next one ends
+		assert.Len(c.flush(start.Add(bucketSize), false), 0)
+		assert.Len(c.flush(start.Add(2*bucketSize-1), false), 0)
+		buckets := c.flush(start.Add(2*bucketSize), false)
+		assert.Len(buckets, 1)
+		assert.EqualValues(start.UnixNano(), buckets[0].Start)
+
+		// spans ending in a flushed bucket go into the oldest one
+		c.add([]*span{newStatsTestSpan("a", "a", start.Add(2*time.Second), time.Millisecond)})
+		buckets = c.flush(start.Add(2*bucketSize), true)
+		assert.Len(buckets, 1)
+		assert.EqualValues(start.Add(bucketSize).UnixNano(), buckets[0].Start)
+		assert.Len(c.buckets, 0)
+	})
+}
+
+func TestTracerStats(t *testing.T) {
+	t.Run("sampled-out", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(
+			WithStatsComputation(true),
+			WithSampler(NewRateSampler(0)),
+			WithServiceName("web"),
+			WithGlobalTag(ext.Environment, "prod"),
+		)
+		defer stop()
+
+		root := tracer.StartSpan("http.request")
+		db := tracer.StartSpan("db.query", ChildOf(root.Context()), ServiceName("db"))
+		local := tracer.StartSpan("render", ChildOf(root.Context()))
+		local.Finish()
+		db.Finish()
+		root.Finish()
+		tracer.forceFlush()
+
+		assert.Len(transport.Traces(), 0)
+		stats := transport.Stats()
+		assert.Len(stats, 1)
+		p := stats[0]
+		assert.Equal("web", p.Service)
+		assert.Equal("prod", p.Env)
+		assert.Equal("go", p.Lang)
+		assert.EqualValues(1, p.Sequence)
+		assert.Len(p.Stats, 1)
+		hits := make(map[string]uint64)
+		for _, gs := range p.Stats[0].Stats {
+			hits[gs.Service+"/"+gs.Name] += gs.Hits
+		}
+		assert.Equal(map[string]uint64{"web/http.request": 1, "db/db.query": 1}, hits)
+	})
+
+	t.Run("processors", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(
+			WithStatsComputation(true),
+			WithServiceName("web"),
+			WithSpanProcessor(func(t *FinishedTrace) bool {
+				for _, s := range t.Spans() {
+					switch s.Name() {
+					case "health.check":
+						return false
+					case "db.query":
+						t.DropSpan(s)
+					default:
+						s.SetService("renamed")
+					}
+				}
+				return true
+			}),
+		)
+		defer stop()
+
+		tracer.StartSpan("health.check").Finish()
+		root := tracer.StartSpan("http.request")
+		tracer.StartSpan("db.query", ChildOf(root.Context()), ServiceName("db")).Finish()
+		root.Finish()
+		tracer.forceFlush()
+
+		assert.Len(transport.Traces(), 1)
+		stats := transport.Stats()
+		assert.Len(stats, 1)
+		hits := make(map[string]uint64)
+		for _, gs := range stats[0].Stats[0].Stats {
+			hits[gs.Service+"/"+gs.Name] += gs.Hits
+		}
+		assert.Equal(map[string]uint64{"web/health.check": 1, "web/http.request": 1, "db/db.query": 1}, hits)
+	})
+
+	t.Run("obfuscation", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(
+			WithStatsComputation(true),
+			WithObfuscation(ObfuscateSQL),
+		)
+		defer stop()
+
+		for _, id := range []string{"1", "2"} {
+			tracer.StartSpan("db.query", SpanType(ext.SpanTypeSQL), ResourceName("SELECT * FROM users WHERE id = "+id)).Finish()
+		}
+		tracer.forceFlush()
+
+		stats := transport.Stats()
+		assert.Len(stats, 1)
+		groups := stats[0].Stats[0].Stats
+		assert.Len(groups, 1)
+		assert.Equal("SELECT * FROM users WHERE id = ?", groups[0].Resource)
+		assert.EqualValues(2, groups[0].Hits)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer()
+		defer stop()
+		tracer.StartSpan("http.request").Finish()
+		tracer.forceFlush()
+		assert.Len(transport.Traces(), 1)
+		assert.Len(transport.Stats(), 0)
+	})
+}
+
+func TestTransportStats(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		path    string
+		decoded interface{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		decoded, _ = msgp.NewReader(bytes.NewReader(body)).ReadIntf()
+	}))
+	defer srv.Close()
+
+	s := newSketch(sketchRelativeAccuracy)
+	s.add(100)
+	transport := newHTTPTransport(strings.TrimPrefix(srv.URL, "http://"), defaultRoundTripper)
+	err := transport.sendStats(context.Background(), &statsPayload{
+		Service:  "web",
+		Lang:     "go",
+		Sequence: 3,
+		Stats: []statsBucket{{
+			Start:    10,
+			Duration: 10,
+			Stats: []groupedStatsPayload{{
+				Service:        "web",
+				Name:           "http.request",
+				HTTPStatusCode: 200,
+				Hits:           1,
+				OkSummary:      s.encode(),
+			}},
+		}},
+	})
+	assert.NoError(err)
+	assert.Equal("/v0.6/stats", path)
+	m := decoded.(map[string]interface{})
+	assert.Equal("web", m["Service"])
+	assert.EqualValues(3, m["Sequence"])
+	bucket := m["Stats"].([]interface{})[0].(map[string]interface{})
+	assert.EqualValues(10, bucket["Start"])
+	gs := bucket["Stats"].([]interface{})[0].(map[string]interface{})
+	assert.Equal("http.request", gs["Name"])
+	assert.EqualValues(200, gs["HTTPStatusCode"])
+	assert.Equal(s.encode(), gs["OkSummary"])
+}
@@ -1,11 +1,13 @@
 package tracer
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 )
 
 // HTTPHeadersCarrier wraps an http.Header as a TextMapWriter and TextMapReader, allowing
@@ -72,6 +74,15 @@ const (
 	DefaultPriorityHeader = "x-datadog-sampling-priority"
 )
 
+// traceTagsHeader is the key used in HTTP headers or text maps to store the
+// comma separated key=value tags propagated along with the trace, such as the
+// upper 64 bits of 128-bit trace IDs.
+const traceTagsHeader = "x-datadog-tags"
+
+// originHeader is the key used in HTTP headers or text maps to store the origin
+// of the trace, such as "synthetics" for traces started by synthetic tests.
+const originHeader = "x-datadog-origin"
+
 // PropagatorConfig defines the configuration for initializing a propagator.
 type PropagatorConfig struct {
 	// BaggagePrefix specifies the prefix that will be used to store baggage
@@ -92,7 +103,8 @@ type PropagatorConfig struct {
 }
 
 // NewPropagator returns a new propagator which uses TextMap to inject
-// and extract values. It propagates trace and span IDs and baggage.
+// and extract values. It propagates trace and span IDs, the origin of the
+// trace and baggage.
 // To use the defaults, nil may be provided in place of the config.
 func NewPropagator(cfg *PropagatorConfig) Propagator {
 	if cfg == nil {
@@ -141,6 +153,12 @@ func (p *propagator) injectTextMap(spanCtx ddtrace.SpanContext, writer TextMapWr
 	if ctx.hasSamplingPriority() {
 		writer.Set(p.cfg.PriorityHeader, strconv.Itoa(ctx.samplingPriority()))
 	}
+	if ctx.traceIDHigh != 0 {
+		writer.Set(traceTagsHeader, fmt.Sprintf("%s=%016x", traceIDHighMetaKey, ctx.traceIDHigh))
+	}
+	if ctx.origin != "" {
+		writer.Set(originHeader, ctx.origin)
+	}
 	// propagate OpenTracing baggage
 	for k, v := range ctx.baggage {
 		writer.Set(p.cfg.BaggagePrefix+k, v)
@@ -180,6 +198,11 @@ func (p *propagator) extractTextMap(reader TextMapReader) (ddtrace.SpanContext,
 				return ErrSpanContextCorrupted
 			}
 			ctx.hasPriority = true
+		case traceTagsHeader:
+			// invalid tags are ignored, as they do not prevent propagation
+			ctx.traceIDHigh = parseTraceTagsIDHigh(v)
+		case originHeader:
+			ctx.origin = v
 		default:
 			if strings.HasPrefix(key, p.cfg.BaggagePrefix) {
 				ctx.setBaggageItem(strings.TrimPrefix(key, p.cfg.BaggagePrefix), v)
@@ -195,3 +218,516 @@ func (p *propagator) extractTextMap(reader TextMapReader) (ddtrace.SpanContext,
 	}
 	return &ctx, nil
 }
+
+// parseTraceTagsIDHigh returns the upper 64 bits of the trace ID found in the
+// trace tags header value v, or zero if there are none.
+func parseTraceTagsIDHigh(v string) uint64 {
+	for _, tag := range strings.Split(v, ",") {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 || kv[0] != traceIDHighMetaKey || len(kv[1]) != 16 || !isLowerHex(kv[1]) {
+			continue
+		}
+		id, _ := strconv.ParseUint(kv[1], 16, 64)
+		return id
+	}
+	return 0
+}
+
+const (
+	b3TraceIDHeader = "x-b3-traceid"
+	b3SpanIDHeader  = "x-b3-spanid"
+	b3SampledHeader = "x-b3-sampled"
+	b3FlagsHeader   = "x-b3-flags"
+	b3SingleHeader  = "b3"
+)
+
+// NewB3Propagator returns a new propagator which uses the B3 (Zipkin) format,
+// injecting the multiple "X-B3-*" headers. It extracts span contexts from both
+// the multiple headers and the single "b3" header. Trace and span IDs are hex
+// encoded, and the sampled flag is mapped to the sampling priority. Baggage is
+// not propagated.
+func NewB3Propagator() Propagator {
+	return &propagatorB3{}
+}
+
+// NewB3SingleHeaderPropagator returns a new propagator which uses the B3 (Zipkin)
+// format, injecting the single "b3" header. Extraction works as with the propagator
+// returned by NewB3Propagator.
+func NewB3SingleHeaderPropagator() Propagator {
+	return &propagatorB3{singleHeader: true}
+}
+
+// propagatorB3 implements Propagator using the B3 format.
+// See https://github.com/openzipkin/b3-propagation
+type propagatorB3 struct {
+	singleHeader bool // inject the single "b3" header
+}
+
+// Inject implements Propagator.
+func (p *propagatorB3) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	switch v := carrier.(type) {
+	case TextMapWriter:
+		return p.injectTextMap(spanCtx, v)
+	default:
+		return ErrInvalidCarrier
+	}
+}
+
+func (p *propagatorB3) injectTextMap(spanCtx ddtrace.SpanContext, writer TextMapWriter) error {
+	ctx, ok := spanCtx.(*spanContext)
+	if !ok || ctx.traceID == 0 || ctx.spanID == 0 {
+		return ErrInvalidSpanContext
+	}
+	traceID, spanID := fmt.Sprintf("%016x", ctx.traceID), fmt.Sprintf("%016x", ctx.spanID)
+	if ctx.traceIDHigh != 0 {
+		traceID = fmt.Sprintf("%016x%s", ctx.traceIDHigh, traceID)
+	}
+	var sampled string
+	if ctx.hasSamplingPriority() {
+		switch prio := ctx.samplingPriority(); {
+		case prio >= ext.PriorityUserKeep:
+			sampled = "d"
+		case prio > 0:
+			sampled = "1"
+		default:
+			sampled = "0"
+		}
+	}
+	if p.singleHeader {
+		v := traceID + "-" + spanID
+		if sampled != "" {
+			v += "-" + sampled
+		}
+		writer.Set(b3SingleHeader, v)
+		return nil
+	}
+	writer.Set(b3TraceIDHeader, traceID)
+	writer.Set(b3SpanIDHeader, spanID)
+	switch sampled {
+	case "d":
+		writer.Set(b3FlagsHeader, "1")
+	case "1", "0":
+		writer.Set(b3SampledHeader, sampled)
+	}
+	return nil
+}
+
+// Extract implements Propagator.
+func (p *propagatorB3) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	switch v := carrier.(type) {
+	case TextMapReader:
+		return p.extractTextMap(v)
+	default:
+		return nil, ErrInvalidCarrier
+	}
+}
+
+func (p *propagatorB3) extractTextMap(reader TextMapReader) (ddtrace.SpanContext, error) {
+	var ctx spanContext
+	err := reader.ForeachKey(func(k, v string) error {
+		var err error
+		switch strings.ToLower(k) {
+		case b3TraceIDHeader:
+			ctx.traceIDHigh, ctx.traceID, err = parseB3TraceID(v)
+		case b3SpanIDHeader:
+			ctx.spanID, err = parseB3ID(v)
+		case b3SampledHeader:
+			if ctx.hasPriority && ctx.priority == ext.PriorityUserKeep {
+				// the debug flag takes precedence
+				return nil
+			}
+			err = setB3Sampled(&ctx, v)
+		case b3FlagsHeader:
+			if v == "1" {
+				ctx.setSamplingPriority(ext.PriorityUserKeep)
+			}
+		case b3SingleHeader:
+			err = extractB3Single(&ctx, v)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ctx.traceID == 0 || ctx.spanID == 0 {
+		return nil, ErrSpanContextNotFound
+	}
+	return &ctx, nil
+}
+
+// extractB3Single extracts the single "b3" header value v into ctx. Its format
+// is {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}, where the last two parts
+// are optional, or only {SamplingState}.
+func extractB3Single(ctx *spanContext, v string) error {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) == 1 {
+		// only the sampling decision is propagated
+		return setB3Sampled(ctx, parts[0])
+	}
+	if len(parts) > 4 {
+		return ErrSpanContextCorrupted
+	}
+	var err error
+	if ctx.traceIDHigh, ctx.traceID, err = parseB3TraceID(parts[0]); err != nil {
+		return err
+	}
+	if ctx.spanID, err = parseB3ID(parts[1]); err != nil {
+		return err
+	}
+	if len(parts) > 2 {
+		return setB3Sampled(ctx, parts[2])
+	}
+	return nil
+}
+
+// setB3Sampled sets the sampling priority of ctx based on the B3 sampling state v.
+func setB3Sampled(ctx *spanContext, v string) error {
+	switch v {
+	case "1", "true":
+		ctx.setSamplingPriority(ext.PriorityAutoKeep)
+	case "0", "false":
+		ctx.setSamplingPriority(ext.PriorityAutoReject)
+	case "d":
+		ctx.setSamplingPriority(ext.PriorityUserKeep)
+	default:
+		return ErrSpanContextCorrupted
+	}
+	return nil
+}
+
+// parseB3TraceID parses the hex encoded 64 or 128-bit B3 trace ID v, returning
+// its upper and lower 64 bits.
+func parseB3TraceID(v string) (high, low uint64, err error) {
+	if len(v) > 32 {
+		return 0, 0, ErrSpanContextCorrupted
+	}
+	if len(v) > 16 {
+		if high, err = parseB3ID(v[:len(v)-16]); err != nil {
+			return 0, 0, err
+		}
+		v = v[len(v)-16:]
+	}
+	low, err = parseB3ID(v)
+	return high, low, err
+}
+
+// parseB3ID parses the hex encoded B3 identifier v of at most 64 bits.
+func parseB3ID(v string) (uint64, error) {
+	if len(v) > 16 || len(v) == 0 {
+		return 0, ErrSpanContextCorrupted
+	}
+	id, err := strconv.ParseUint(v, 16, 64)
+	if err != nil {
+		return 0, ErrSpanContextCorrupted
+	}
+	return id, nil
+}
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+
+	// tracestateMaxEntries is the maximum number of list members in tracestate.
+	tracestateMaxEntries = 32
+)
+
+// NewW3CPropagator returns a new propagator which uses the W3C Trace Context
+// format, reading and writing the "traceparent" and "tracestate" headers. The
+// upper 64 bits of 128-bit trace IDs are kept so that they can be propagated
+// back unchanged, and the sampled flag is mapped to the sampling priority. The
+// entries of other vendors found in tracestate are preserved, while a "dd" entry
+// holding the sampling priority, or the sampling decision when priority sampling
+// is disabled, is written first. Baggage is not propagated.
+// See https://www.w3.org/TR/trace-context/
+func NewW3CPropagator() Propagator {
+	return &propagatorW3C{}
+}
+
+// propagatorW3C implements Propagator using the W3C Trace Context format.
+type propagatorW3C struct{}
+
+// Inject implements Propagator.
+func (p *propagatorW3C) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	switch v := carrier.(type) {
+	case TextMapWriter:
+		return p.injectTextMap(spanCtx, v)
+	default:
+		return ErrInvalidCarrier
+	}
+}
+
+func (p *propagatorW3C) injectTextMap(spanCtx ddtrace.SpanContext, writer TextMapWriter) error {
+	ctx, ok := spanCtx.(*spanContext)
+	if !ok || ctx.traceID == 0 || ctx.spanID == 0 {
+		return ErrInvalidSpanContext
+	}
+	// without priority sampling, the decision of the client-side sampler is used
+	sampled, priority := ctx.isSampled(), 0
+	if ctx.hasSamplingPriority() {
+		priority = ctx.samplingPriority()
+		sampled = priority > 0
+	} else if sampled {
+		priority = 1
+	}
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	writer.Set(traceparentHeader, fmt.Sprintf("00-%016x%016x-%016x-%s", ctx.traceIDHigh, ctx.traceID, ctx.spanID, flags))
+
+	entries := make([]string, 0, tracestateMaxEntries)
+	entries = append(entries, "dd=s:"+strconv.Itoa(priority))
+	for _, e := range strings.Split(ctx.tracestate, ",") {
+		if len(entries) == tracestateMaxEntries {
+			break
+		}
+		if e = strings.TrimSpace(e); e == "" || strings.HasPrefix(e, "dd=") {
+			// replaced by our own entry
+			continue
+		}
+		entries = append(entries, e)
+	}
+	writer.Set(tracestateHeader, strings.Join(entries, ","))
+	return nil
+}
+
+// Extract implements Propagator.
+func (p *propagatorW3C) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	switch v := carrier.(type) {
+	case TextMapReader:
+		return p.extractTextMap(v)
+	default:
+		return nil, ErrInvalidCarrier
+	}
+}
+
+func (p *propagatorW3C) extractTextMap(reader TextMapReader) (ddtrace.SpanContext, error) {
+	var (
+		traceparent string
+		found       int
+		tracestate  []string
+	)
+	err := reader.ForeachKey(func(k, v string) error {
+		switch strings.ToLower(k) {
+		case traceparentHeader:
+			traceparent = v
+			found++
+		case tracestateHeader:
+			// multiple headers are combined, in order
+			tracestate = append(tracestate, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == 0 {
+		return nil, ErrSpanContextNotFound
+	}
+	if found > 1 {
+		return nil, ErrSpanContextCorrupted
+	}
+	var ctx spanContext
+	sampled, err := parseTraceparent(&ctx, traceparent)
+	if err != nil {
+		return nil, err
+	}
+	priority := ext.PriorityAutoReject
+	if sampled {
+		priority = ext.PriorityAutoKeep
+	}
+	if ts, ok := parseTracestate(strings.Join(tracestate, ",")); ok {
+		ctx.tracestate = ts
+		// the priority set by a Datadog tracer is more precise, as long as it
+		// agrees with the sampled flag
+		if prio, ok := tracestateDatadogPriority(ts); ok && (prio > 0) == sampled {
+			priority = prio
+		}
+	}
+	ctx.setSamplingPriority(priority)
+	return &ctx, nil
+}
+
+// parseTraceparent parses the traceparent header value v into ctx, returning
+// the value of the sampled flag.
+func parseTraceparent(ctx *spanContext, v string) (sampled bool, err error) {
+	v = strings.Trim(v, " \t")
+	// version "00" has exactly 55 characters, later versions may append fields
+	if len(v) < 55 || !isLowerHex(v[:2]) || v[:2] == "ff" || (v[:2] == "00" && len(v) != 55) || (len(v) > 55 && v[55] != '-') {
+		return false, ErrSpanContextCorrupted
+	}
+	if v[2] != '-' || v[35] != '-' || v[52] != '-' {
+		return false, ErrSpanContextCorrupted
+	}
+	traceID, spanID, flags := v[3:35], v[36:52], v[53:55]
+	if !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return false, ErrSpanContextCorrupted
+	}
+	ctx.traceIDHigh, _ = strconv.ParseUint(traceID[:16], 16, 64)
+	ctx.traceID, _ = strconv.ParseUint(traceID[16:], 16, 64)
+	ctx.spanID, _ = strconv.ParseUint(spanID, 16, 64)
+	if ctx.traceID == 0 || ctx.spanID == 0 {
+		// all zero IDs are invalid, and trace IDs with zero lower bits can not
+		// be represented
+		return false, ErrSpanContextCorrupted
+	}
+	f, _ := strconv.ParseUint(flags, 16, 8)
+	return f&1 == 1, nil
+}
+
+// isLowerHex reports whether s only contains lowercase hexadecimal characters.
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTracestate validates the tracestate header value v, returning its
+// normalized list members. When it is invalid, ok is false and the header
+// must be discarded.
+func parseTracestate(v string) (ts string, ok bool) {
+	var entries []string
+	keys := make(map[string]bool)
+	for _, e := range strings.Split(v, ",") {
+		if e = strings.Trim(e, " \t"); e == "" {
+			continue
+		}
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || !validTracestateKey(kv[0]) || !validTracestateValue(kv[1]) || keys[kv[0]] {
+			return "", false
+		}
+		keys[kv[0]] = true
+		entries = append(entries, e)
+	}
+	if len(entries) > tracestateMaxEntries {
+		return "", false
+	}
+	return strings.Join(entries, ","), true
+}
+
+// validTracestateKey reports whether k is a valid tracestate key, which is
+// either a simple key or a multi-tenant key of the form tenant@system.
+func validTracestateKey(k string) bool {
+	if i := strings.IndexByte(k, '@'); i >= 0 {
+		tenant, system := k[:i], k[i+1:]
+		return len(tenant) > 0 && len(tenant) <= 241 && len(system) > 0 && len(system) <= 14 &&
+			validTracestateKeyChars(tenant, true) && validTracestateKeyChars(system, false)
+	}
+	return len(k) > 0 && len(k) <= 256 && validTracestateKeyChars(k, false)
+}
+
+// validTracestateKeyChars reports whether s starts with a lowercase letter, or
+// a digit when digitFirst is true, followed by lowercase letters, digits, '_',
+// '-', '*' and '/'.
+func validTracestateKeyChars(s string, digitFirst bool) bool {
+	for i, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9':
+			if i == 0 && !digitFirst {
+				return false
+			}
+		case i > 0 && (c == '_' || c == '-' || c == '*' || c == '/'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validTracestateValue reports whether v is a valid tracestate value, made of
+// at most 256 printable ASCII characters other than ',' and '=', and not
+// ending with a space.
+func validTracestateValue(v string) bool {
+	if len(v) == 0 || len(v) > 256 || v[len(v)-1] == ' ' {
+		return false
+	}
+	for _, c := range v {
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+	return true
+}
+
+// tracestateDatadogPriority returns the sampling priority found in the "dd"
+// entry of the tracestate ts, if any.
+func tracestateDatadogPriority(ts string) (int, bool) {
+	for _, e := range strings.Split(ts, ",") {
+		if !strings.HasPrefix(e, "dd=") {
+			continue
+		}
+		for _, field := range strings.Split(strings.TrimPrefix(e, "dd="), ";") {
+			if strings.HasPrefix(field, "s:") {
+				p, err := strconv.Atoi(strings.TrimPrefix(field, "s:"))
+				return p, err == nil
+			}
+		}
+	}
+	return 0, false
+}
+
+// NewChainedPropagator returns a new propagator which injects span contexts using
+// all of the inject propagators, and extracts them using the extract propagators,
+// returning the first valid span context found. It allows using several formats
+// at once, for example while migrating between them:
+//
+//	p := tracer.NewChainedPropagator(
+//	    []tracer.Propagator{tracer.NewPropagator(nil), tracer.NewW3CPropagator()},
+//	    []tracer.Propagator{tracer.NewW3CPropagator(), tracer.NewPropagator(nil)},
+//	)
+//	tracer.Start(tracer.WithPropagator(p))
+func NewChainedPropagator(inject, extract []Propagator) Propagator {
+	return &chainedPropagator{inject: inject, extract: extract}
+}
+
+// chainedPropagator implements Propagator using several propagators.
+type chainedPropagator struct {
+	inject  []Propagator
+	extract []Propagator
+}
+
+// Inject implements Propagator. It stops at the first propagator returning
+// an error.
+func (p *chainedPropagator) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	for _, v := range p.inject {
+		if err := v.Inject(spanCtx, carrier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extract implements Propagator. It tries all propagators in order, returning
+// the first valid span context. When none is found, the first error other than
+// ErrSpanContextNotFound is returned, if any.
+func (p *chainedPropagator) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	var firstErr error
+	for _, v := range p.extract {
+		ctx, err := v.Extract(carrier)
+		if err == nil {
+			return ctx, nil
+		}
+		if firstErr == nil && err != ErrSpanContextNotFound {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, ErrSpanContextNotFound
+}
+
+// propagatorStyles maps the names of the propagation styles which can be used
+// with the DD_PROPAGATION_STYLE_INJECT and DD_PROPAGATION_STYLE_EXTRACT
+// environment variables to their propagators.
+var propagatorStyles = map[string]func() Propagator{
+	"datadog":          func() Propagator { return NewPropagator(nil) },
+	"b3":               NewB3Propagator,
+	"b3multi":          NewB3Propagator,
+	"b3 single header": NewB3SingleHeaderPropagator,
+	"tracecontext":     NewW3CPropagator,
+}
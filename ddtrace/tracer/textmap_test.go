@@ -2,8 +2,11 @@ package tracer
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"testing"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
@@ -168,3 +171,505 @@ func TestTextMapPropagatorInjectExtract(t *testing.T) {
 	assert.Equal(xctx.priority, ctx.priority)
 	assert.Equal(xctx.hasPriority, ctx.hasPriority)
 }
+
+func TestB3PropagatorInject(t *testing.T) {
+	tracer := newTracer()
+	defer tracer.Stop()
+	root := tracer.StartSpan("web.request").(*span)
+	ctx := root.Context().(*spanContext)
+	ctx.traceID, ctx.spanID = 0xabc, 0x1234
+
+	for _, tt := range []struct {
+		priority  int
+		multi     map[string]string
+		single    string
+		noContext bool
+	}{
+		{
+			priority: -100, // no priority
+			multi:    map[string]string{"x-b3-traceid": "0000000000000abc", "x-b3-spanid": "0000000000001234"},
+			single:   "0000000000000abc-0000000000001234",
+		},
+		{
+			priority: ext.PriorityAutoKeep,
+			multi:    map[string]string{"x-b3-traceid": "0000000000000abc", "x-b3-spanid": "0000000000001234", "x-b3-sampled": "1"},
+			single:   "0000000000000abc-0000000000001234-1",
+		},
+		{
+			priority: ext.PriorityUserReject,
+			multi:    map[string]string{"x-b3-traceid": "0000000000000abc", "x-b3-spanid": "0000000000001234", "x-b3-sampled": "0"},
+			single:   "0000000000000abc-0000000000001234-0",
+		},
+		{
+			priority: ext.PriorityUserKeep,
+			multi:    map[string]string{"x-b3-traceid": "0000000000000abc", "x-b3-spanid": "0000000000001234", "x-b3-flags": "1"},
+			single:   "0000000000000abc-0000000000001234-d",
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			assert := assert.New(t)
+			ctx.hasPriority = false
+			if tt.priority != -100 {
+				ctx.setSamplingPriority(tt.priority)
+			}
+
+			multi := TextMapCarrier{}
+			assert.NoError(NewB3Propagator().Inject(ctx, multi))
+			assert.Equal(tt.multi, map[string]string(multi))
+
+			single := TextMapCarrier{}
+			assert.NoError(NewB3SingleHeaderPropagator().Inject(ctx, single))
+			assert.Equal(map[string]string{"b3": tt.single}, map[string]string(single))
+		})
+	}
+}
+
+func TestB3PropagatorExtract(t *testing.T) {
+	for _, tt := range []struct {
+		in       TextMapCarrier
+		traceID  uint64
+		spanID   uint64
+		priority int // -100 for none
+		err      error
+	}{
+		{
+			in:       TextMapCarrier{"X-B3-TraceId": "0000000000000abc", "X-B3-SpanId": "1234"},
+			traceID:  0xabc,
+			spanID:   0x1234,
+			priority: -100,
+		},
+		{
+			in:       TextMapCarrier{"x-b3-traceid": "463ac35c9f6413ad48485a3953bb6124", "x-b3-spanid": "a2fb4a1d1a96d312", "x-b3-sampled": "1"},
+			traceID:  0x48485a3953bb6124,
+			spanID:   0xa2fb4a1d1a96d312,
+			priority: ext.PriorityAutoKeep,
+		},
+		{
+			in:       TextMapCarrier{"x-b3-traceid": "abc", "x-b3-spanid": "1234", "x-b3-sampled": "0", "x-b3-flags": "1"},
+			traceID:  0xabc,
+			spanID:   0x1234,
+			priority: ext.PriorityUserKeep,
+		},
+		{
+			in:       TextMapCarrier{"b3": "0000000000000abc-0000000000001234"},
+			traceID:  0xabc,
+			spanID:   0x1234,
+			priority: -100,
+		},
+		{
+			in:       TextMapCarrier{"b3": "463ac35c9f6413ad48485a3953bb6124-a2fb4a1d1a96d312-0-05e3ac9a4f6e3b90"},
+			traceID:  0x48485a3953bb6124,
+			spanID:   0xa2fb4a1d1a96d312,
+			priority: ext.PriorityAutoReject,
+		},
+		{
+			in:       TextMapCarrier{"b3": "abc-1234-d"},
+			traceID:  0xabc,
+			spanID:   0x1234,
+			priority: ext.PriorityUserKeep,
+		},
+		{
+			in:  TextMapCarrier{"b3": "0"},
+			err: ErrSpanContextNotFound,
+		},
+		{
+			in:  TextMapCarrier{"x-b3-traceid": "xyz", "x-b3-spanid": "1234"},
+			err: ErrSpanContextCorrupted,
+		},
+		{
+			in:  TextMapCarrier{"b3": "abc-1234-x"},
+			err: ErrSpanContextCorrupted,
+		},
+		{
+			in:  TextMapCarrier{"x-datadog-trace-id": "1", "x-datadog-parent-id": "2"},
+			err: ErrSpanContextNotFound,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			assert := assert.New(t)
+			sctx, err := NewB3Propagator().Extract(tt.in)
+			if tt.err != nil {
+				assert.Equal(tt.err, err)
+				return
+			}
+			assert.NoError(err)
+			ctx := sctx.(*spanContext)
+			assert.Equal(tt.traceID, ctx.traceID)
+			assert.Equal(tt.spanID, ctx.spanID)
+			if tt.priority == -100 {
+				assert.False(ctx.hasSamplingPriority())
+			} else {
+				assert.True(ctx.hasSamplingPriority())
+				assert.Equal(tt.priority, ctx.samplingPriority())
+			}
+		})
+	}
+}
+
+func TestB3PropagatorInjectExtract(t *testing.T) {
+	assert := assert.New(t)
+	tracer := newTracer(WithPropagator(NewB3Propagator()))
+	defer tracer.Stop()
+	root := tracer.StartSpan("web.request").(*span)
+	root.SetTag(ext.SamplingPriority, ext.PriorityAutoKeep)
+	ctx := root.Context().(*spanContext)
+
+	headers := HTTPHeadersCarrier(http.Header{})
+	assert.NoError(tracer.Inject(ctx, headers))
+	assert.Equal(fmt.Sprintf("%016x", ctx.traceID), http.Header(headers).Get("X-B3-TraceId"))
+
+	sctx, err := tracer.Extract(headers)
+	assert.NoError(err)
+	xctx := sctx.(*spanContext)
+	assert.Equal(ctx.traceID, xctx.traceID)
+	assert.Equal(ctx.spanID, xctx.spanID)
+	assert.Equal(ext.PriorityAutoKeep, xctx.samplingPriority())
+}
+
+func TestW3CPropagatorExtract(t *testing.T) {
+	const (
+		traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		high, low   = 0x4bf92f3577b34da6, 0xa3ce929d0e0e4736
+		spanID      = 0x00f067aa0ba902b7
+	)
+
+	t.Run("valid", func(t *testing.T) {
+		for _, tt := range []struct {
+			in         TextMapCarrier
+			priority   int
+			tracestate string
+		}{
+			{
+				in:       TextMapCarrier{"traceparent": traceparent},
+				priority: ext.PriorityAutoKeep,
+			},
+			{
+				in:       TextMapCarrier{"Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+				priority: ext.PriorityAutoReject,
+			},
+			{
+				// future versions may add fields
+				in:       TextMapCarrier{"traceparent": "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09-what-the-future-will-be-like"},
+				priority: ext.PriorityAutoKeep,
+			},
+			{
+				in:         TextMapCarrier{"traceparent": traceparent, "tracestate": "rojo=00f067aa0ba902b7, congo=t61rcWkgMzE"},
+				priority:   ext.PriorityAutoKeep,
+				tracestate: "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE",
+			},
+			{
+				in:         TextMapCarrier{"traceparent": traceparent, "tracestate": "dd=s:2,fw529a3039@dt=-4-m"},
+				priority:   ext.PriorityUserKeep,
+				tracestate: "dd=s:2,fw529a3039@dt=-4-m",
+			},
+			{
+				// the dd priority disagrees with the sampled flag
+				in:         TextMapCarrier{"traceparent": traceparent, "tracestate": "dd=s:-1"},
+				priority:   ext.PriorityAutoKeep,
+				tracestate: "dd=s:-1",
+			},
+			{
+				// invalid tracestate headers are discarded
+				in:       TextMapCarrier{"traceparent": traceparent, "tracestate": "rojo=1,rojo=2"},
+				priority: ext.PriorityAutoKeep,
+			},
+			{
+				in:       TextMapCarrier{"traceparent": traceparent, "tracestate": "Rojo=1"},
+				priority: ext.PriorityAutoKeep,
+			},
+			{
+				in:       TextMapCarrier{"traceparent": traceparent, "tracestate": "rojo"},
+				priority: ext.PriorityAutoKeep,
+			},
+			{
+				in:       TextMapCarrier{"traceparent": traceparent, "tracestate": "rojo=a,b"},
+				priority: ext.PriorityAutoKeep,
+			},
+			{
+				in:       TextMapCarrier{"traceparent": traceparent, "tracestate": strings.Repeat("a=1,", 32) + "b=1"},
+				priority: ext.PriorityAutoKeep,
+			},
+		} {
+			t.Run("", func(t *testing.T) {
+				assert := assert.New(t)
+				sctx, err := NewW3CPropagator().Extract(tt.in)
+				assert.NoError(err)
+				ctx := sctx.(*spanContext)
+				assert.Equal(uint64(high), ctx.traceIDHigh)
+				assert.Equal(uint64(low), ctx.traceID)
+				assert.Equal(uint64(spanID), ctx.spanID)
+				assert.Equal(tt.priority, ctx.samplingPriority())
+				assert.Equal(tt.tracestate, ctx.tracestate)
+			})
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, tt := range []string{
+			"",
+			"00",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",   // forbidden version
+			"0x-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",   // invalid version
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",   // uppercase trace ID
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00F067AA0BA902B7-01",   // uppercase parent ID
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",   // zero trace ID
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",   // zero parent ID
+			"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",    // short trace ID
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b-01",    // short parent ID
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0",    // short flags
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g",   // invalid flags
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-",  // trailing data with version 00
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.x", // trailing data with version 00
+			"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",   // invalid delimiters
+			"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.x", // future version, invalid delimiter
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01 xyz",
+		} {
+			t.Run(tt, func(t *testing.T) {
+				_, err := NewW3CPropagator().Extract(TextMapCarrier{"traceparent": tt})
+				assert.Equal(t, ErrSpanContextCorrupted, err)
+			})
+		}
+	})
+
+	t.Run("multiple", func(t *testing.T) {
+		h := http.Header{}
+		h.Add("traceparent", traceparent)
+		h.Add("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b8-01")
+		_, err := NewW3CPropagator().Extract(HTTPHeadersCarrier(h))
+		assert.Equal(t, ErrSpanContextCorrupted, err)
+	})
+
+	t.Run("not-found", func(t *testing.T) {
+		_, err := NewW3CPropagator().Extract(TextMapCarrier{"tracestate": "rojo=1"})
+		assert.Equal(t, ErrSpanContextNotFound, err)
+	})
+}
+
+func TestW3CPropagatorInjectExtract(t *testing.T) {
+	assert := assert.New(t)
+	tracer := newTracer(WithPropagator(NewW3CPropagator()))
+	defer tracer.Stop()
+
+	h := http.Header{}
+	h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.Add("tracestate", "dd=s:1,rojo=00f067aa0ba902b7")
+	h.Add("tracestate", "congo=t61rcWkgMzE")
+	sctx, err := tracer.Extract(HTTPHeadersCarrier(h))
+	assert.NoError(err)
+
+	child := tracer.StartSpan("child", ChildOf(sctx)).(*span)
+	child.SetTag(ext.SamplingPriority, ext.PriorityUserKeep)
+	out := http.Header{}
+	assert.NoError(tracer.Inject(child.Context(), HTTPHeadersCarrier(out)))
+	assert.Equal(fmt.Sprintf("00-4bf92f3577b34da6a3ce929d0e0e4736-%016x-01", child.SpanID), out.Get("traceparent"))
+	assert.Equal("dd=s:2,rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", out.Get("tracestate"))
+
+	// a trace started locally
+	root := tracer.StartSpan("root").(*span)
+	root.SetTag(ext.SamplingPriority, ext.PriorityAutoReject)
+	out = http.Header{}
+	assert.NoError(tracer.Inject(root.Context(), HTTPHeadersCarrier(out)))
+	assert.Equal(fmt.Sprintf("00-0000000000000000%016x-%016x-00", root.TraceID, root.SpanID), out.Get("traceparent"))
+	assert.Equal("dd=s:0", out.Get("tracestate"))
+}
+
+func TestW3CPropagatorInjectWithoutPriority(t *testing.T) {
+	tracer := newTracer(WithPropagator(NewW3CPropagator()))
+	defer tracer.Stop()
+
+	t.Run("sampled", func(t *testing.T) {
+		assert := assert.New(t)
+		root := tracer.StartSpan("root").(*span)
+		assert.False(root.context.hasSamplingPriority())
+		out := TextMapCarrier{}
+		assert.NoError(tracer.Inject(root.Context(), out))
+		assert.Equal(fmt.Sprintf("00-0000000000000000%016x-%016x-01", root.TraceID, root.SpanID), out["traceparent"])
+		assert.Equal("dd=s:1", out["tracestate"])
+	})
+
+	t.Run("not-sampled", func(t *testing.T) {
+		assert := assert.New(t)
+		root := tracer.StartSpan("root").(*span)
+		root.context.sampled = false
+		out := TextMapCarrier{}
+		assert.NoError(tracer.Inject(root.Context(), out))
+		assert.Equal(fmt.Sprintf("00-0000000000000000%016x-%016x-00", root.TraceID, root.SpanID), out["traceparent"])
+		assert.Equal("dd=s:0", out["tracestate"])
+	})
+}
+
+func TestChainedPropagator(t *testing.T) {
+	tracer := newTracer(WithPropagator(NewChainedPropagator(
+		[]Propagator{NewPropagator(nil), NewB3Propagator(), NewW3CPropagator()},
+		[]Propagator{NewW3CPropagator(), NewB3Propagator(), NewPropagator(nil)},
+	)))
+	defer tracer.Stop()
+	root := tracer.StartSpan("web.request").(*span)
+	root.SetTag(ext.SamplingPriority, ext.PriorityAutoKeep)
+	ctx := root.Context().(*spanContext)
+
+	t.Run("inject", func(t *testing.T) {
+		assert := assert.New(t)
+		headers := TextMapCarrier{}
+		assert.NoError(tracer.Inject(ctx, headers))
+		assert.Equal(strconv.FormatUint(ctx.traceID, 10), headers[DefaultTraceIDHeader])
+		assert.Equal(fmt.Sprintf("%016x", ctx.traceID), headers["x-b3-traceid"])
+		assert.Equal(fmt.Sprintf("00-%032x-%016x-01", ctx.traceID, ctx.spanID), headers["traceparent"])
+
+		assert.Equal(ErrInvalidSpanContext, tracer.Inject(&spanContext{}, headers))
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		for _, tt := range []struct {
+			in      TextMapCarrier
+			traceID uint64
+			err     error
+		}{
+			{in: TextMapCarrier{DefaultTraceIDHeader: "1", DefaultParentIDHeader: "2"}, traceID: 1},
+			{in: TextMapCarrier{"x-b3-traceid": "2", "x-b3-spanid": "2", DefaultTraceIDHeader: "1", DefaultParentIDHeader: "2"}, traceID: 2},
+			{in: TextMapCarrier{"traceparent": "00-00000000000000000000000000000003-0000000000000002-01", "x-b3-traceid": "2", "x-b3-spanid": "2"}, traceID: 3},
+			// a corrupted style is skipped when another one is valid
+			{in: TextMapCarrier{"traceparent": "00-xyz", DefaultTraceIDHeader: "1", DefaultParentIDHeader: "2"}, traceID: 1},
+			{in: TextMapCarrier{"traceparent": "00-xyz"}, err: ErrSpanContextCorrupted},
+			{in: TextMapCarrier{"other": "1"}, err: ErrSpanContextNotFound},
+		} {
+			t.Run("", func(t *testing.T) {
+				assert := assert.New(t)
+				sctx, err := tracer.Extract(tt.in)
+				if tt.err != nil {
+					assert.Equal(tt.err, err)
+					return
+				}
+				assert.NoError(err)
+				assert.Equal(tt.traceID, sctx.TraceID())
+			})
+		}
+	})
+
+	t.Run("carrier", func(t *testing.T) {
+		_, err := tracer.Extract("invalid")
+		assert.Equal(t, ErrInvalidCarrier, err)
+	})
+}
+
+func TestPropagationStyleEnv(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		c := newConfig()
+		assert.Nil(t, c.propagator)
+	})
+
+	t.Run("styles", func(t *testing.T) {
+		assert := assert.New(t)
+		os.Setenv("DD_PROPAGATION_STYLE_INJECT", "Datadog, tracecontext")
+		defer os.Unsetenv("DD_PROPAGATION_STYLE_INJECT")
+		os.Setenv("DD_PROPAGATION_STYLE_EXTRACT", "b3 single header,unknown")
+		defer os.Unsetenv("DD_PROPAGATION_STYLE_EXTRACT")
+		l := new(recordLogger)
+		c := newConfig(WithLogger(l))
+		p := c.propagator.(*chainedPropagator)
+		if assert.Len(p.inject, 2) {
+			assert.IsType(&propagator{}, p.inject[0])
+			assert.IsType(&propagatorW3C{}, p.inject[1])
+		}
+		assert.Equal([]Propagator{&propagatorB3{singleHeader: true}}, p.extract)
+		assert.Equal([]string{`WARN: DD_PROPAGATION_STYLE_EXTRACT: ignoring unknown propagation style "unknown"`}, l.Lines())
+	})
+
+	t.Run("extract-only", func(t *testing.T) {
+		assert := assert.New(t)
+		os.Setenv("DD_PROPAGATION_STYLE_EXTRACT", "b3")
+		defer os.Unsetenv("DD_PROPAGATION_STYLE_EXTRACT")
+		p := newConfig().propagator.(*chainedPropagator)
+		if assert.Len(p.inject, 1) {
+			assert.IsType(&propagator{}, p.inject[0])
+		}
+		assert.Equal([]Propagator{&propagatorB3{}}, p.extract)
+	})
+
+	t.Run("option", func(t *testing.T) {
+		os.Setenv("DD_PROPAGATION_STYLE_INJECT", "b3")
+		defer os.Unsetenv("DD_PROPAGATION_STYLE_INJECT")
+		c := newConfig(WithPropagator(NewW3CPropagator()))
+		assert.IsType(t, &propagatorW3C{}, c.propagator)
+	})
+}
+
+func TestPropagator128BitTraceIDs(t *testing.T) {
+	for name, p := range map[string]Propagator{
+		"datadog":          NewPropagator(nil),
+		"b3":               NewB3Propagator(),
+		"b3 single header": NewB3SingleHeaderPropagator(),
+		"tracecontext":     NewW3CPropagator(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			tracer := newTracer(WithPropagator(p), With128BitTraceIDs())
+			defer tracer.Stop()
+			root := tracer.StartSpan("web.request").(*span)
+			ctx := root.Context().(*spanContext)
+
+			headers := TextMapCarrier{}
+			assert.NoError(tracer.Inject(ctx, headers))
+			sctx, err := tracer.Extract(headers)
+			assert.NoError(err)
+			xctx := sctx.(*spanContext)
+			assert.Equal(ctx.traceIDHigh, xctx.traceIDHigh)
+			assert.Equal(ctx.traceID, xctx.traceID)
+			assert.Equal(ctx.TraceID128(), xctx.TraceID128())
+		})
+	}
+}
+
+func TestPropagatorTraceTags(t *testing.T) {
+	for in, want := range map[string]uint64{
+		"_dd.p.tid=640cfd8d00000000":             0x640cfd8d00000000,
+		"_dd.p.dm=-1,_dd.p.tid=640cfd8d0000abcd": 0x640cfd8d0000abcd,
+		"_dd.p.tid=640CFD8D00000000":             0,
+		"_dd.p.tid=640cfd8d":                     0,
+		"_dd.p.tid":                              0,
+		"":                                       0,
+	} {
+		sctx, err := NewPropagator(nil).Extract(TextMapCarrier{
+			DefaultTraceIDHeader:  "1",
+			DefaultParentIDHeader: "2",
+			traceTagsHeader:       in,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, want, sctx.(*spanContext).traceIDHigh, in)
+	}
+}
+
+func TestPropagatorOrigin(t *testing.T) {
+	assert := assert.New(t)
+	tracer, transport, stop := startTestTracer()
+	defer stop()
+
+	headers := http.Header{}
+	headers.Set(DefaultTraceIDHeader, "1")
+	headers.Set(DefaultParentIDHeader, "2")
+	headers.Set("X-Datadog-Origin", "synthetics")
+	sctx, err := tracer.Extract(HTTPHeadersCarrier(headers))
+	assert.NoError(err)
+	assert.Equal("synthetics", sctx.(*spanContext).origin)
+
+	root := tracer.StartSpan("web.request", ChildOf(sctx))
+	child := tracer.StartSpan("db.query", ChildOf(root.Context()))
+	carrier := TextMapCarrier(map[string]string{})
+	assert.NoError(tracer.Inject(child.Context(), carrier))
+	assert.Equal("synthetics", carrier[originHeader])
+	child.Finish()
+	root.Finish()
+	tracer.forceFlush()
+
+	traces := transport.Traces()
+	assert.Len(traces, 1)
+	assert.Len(traces[0], 2)
+	for _, s := range traces[0] {
+		assert.Equal("synthetics", s.Meta[originMetaKey])
+	}
+
+	// no origin is propagated when none was received
+	carrier = TextMapCarrier(map[string]string{})
+	assert.NoError(tracer.Inject(tracer.StartSpan("web.request").Context(), carrier))
+	assert.NotContains(carrier, originHeader)
+}
@@ -1,15 +1,18 @@
 package tracer
 
 import (
+	"context"
 	"errors"
-	"log"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 )
 
 var _ ddtrace.Tracer = (*tracer)(nil)
@@ -26,17 +29,71 @@ type tracer struct {
 	*config
 	*payload
 
-	flushAllReq    chan chan<- struct{}
+	flushAllReq    chan flushRequest
 	flushTracesReq chan struct{}
 	flushErrorsReq chan struct{}
 	exitReq        chan struct{}
 
+	// ctx is the context used when sending payloads. It is canceled when
+	// the deadline of a bounded stop passes, aborting any ongoing send.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// abandoned holds the number of traces which could not be sent when the
+	// tracer stopped. It is written by the worker before it exits.
+	abandoned int
+
+	// retries holds the payloads which failed to be sent and are retried on
+	// later flushes. It is only accessed by the worker.
+	retries retryBuffer
+
+	// disk holds the payloads spooled to disk, which are sent by a separate
+	// goroutine. It is nil when spooling is disabled.
+	disk *diskBuffer
+
+	// stats aggregates the stats of the finished spans when they are computed
+	// by the tracer rather than by the agent, and is nil otherwise. It is only
+	// accessed by the worker.
+	stats *concentrator
+
+	// statsSequence counts the stats payloads sent to the agent. It is only
+	// accessed by the worker.
+	statsSequence uint64
+
+	// sentTraces and spilledTraces count the traces sent to the agent and
+	// spooled to disk by the worker. They are only accessed by the worker.
+	sentTraces    int
+	spilledTraces int
+
 	payloadQueue chan []*span
 	errorBuffer  chan error
 
+	// errs holds the summaries of the errors received since they were last
+	// logged. It is only accessed by the worker, which logs it at most once
+	// per flush interval.
+	errs map[string]errorSummary
+
+	// prioritySampling holds an instance of the priority sampler.
+	prioritySampling *prioritySampler
+
+	// partialFlushes counts the number of trace chunks which were partially
+	// flushed since the last time the payload was flushed. It is accessed
+	// atomically.
+	partialFlushes uint64
+
 	// stopped is a channel that will be closed when the worker has exited.
 	stopped chan struct{}
 
+	// wg waits for all goroutines other than the worker to exit.
+	wg sync.WaitGroup
+
+	// health holds counters which are reported as health metrics.
+	health healthStats
+
+	// openSpans tracks the spans which are not yet finished in debug spans
+	// mode, and is nil otherwise.
+	openSpans *spanTracker
+
 	// syncPush is used for testing. When non-nil, it causes pushTrace to become
 	// a synchronous (blocking) operation, meaning that it will only return after
 	// the trace has been fully processed and added onto the payload.
@@ -44,27 +101,35 @@ type tracer struct {
 }
 
 const (
-	// flushInterval is the interval at which the payload contents will be flushed
-	// to the transport.
-	flushInterval = 2 * time.Second
+	// defaultFlushInterval is the default interval at which the payload contents
+	// will be flushed to the transport.
+	defaultFlushInterval = 2 * time.Second
 
 	// payloadMaxLimit is the maximum payload size allowed and should indicate the
 	// maximum size of the package that the agent can receive.
 	payloadMaxLimit = 9.5 * 1024 * 1024 // 9.5 MB
 
-	// payloadSizeLimit specifies the maximum allowed size of the payload before
-	// it will trigger a flush to the transport.
-	payloadSizeLimit = payloadMaxLimit / 2
+	// defaultPayloadSizeLimit specifies the default maximum allowed size of the
+	// payload before it will trigger a flush to the transport.
+	defaultPayloadSizeLimit = payloadMaxLimit / 2
 )
 
 // Start starts the tracer with the given set of options. It will stop and replace
 // any running tracer, meaning that calling it several times will result in a restart
-// of the tracer by replacing the current instance with a new one.
+// of the tracer by replacing the current instance with a new one. Tracing can be
+// disabled by setting the DD_TRACE_ENABLED environment variable to "false", in
+// which case Start installs a no-op tracer.
 func Start(opts ...StartOption) {
 	if internal.Testing {
 		return // mock tracer active
 	}
-	internal.SetGlobalTracer(newTracer(opts...))
+	c := newConfig(opts...)
+	if !c.enabled {
+		// disabled using DD_TRACE_ENABLED
+		internal.SetGlobalTracer(&internal.NoopTracer{})
+		return
+	}
+	internal.SetGlobalTracer(newTracerConfig(c))
 }
 
 // Stop stops the started tracer. Subsequent calls are valid but become no-op.
@@ -72,6 +137,34 @@ func Stop() {
 	internal.SetGlobalTracer(&internal.NoopTracer{})
 }
 
+// StopWithTimeout stops the started tracer like Stop, but gives up sending the
+// remaining traces to the agent once the given timeout has passed. It returns
+// the number of traces which were abandoned as a result, which is zero when all
+// traces were sent. It is meant for short-lived processes, which can not afford
+// to wait for the agent.
+func StopWithTimeout(timeout time.Duration) (abandoned int) {
+	if t, ok := internal.GetGlobalTracer().(*tracer); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		abandoned = t.stopContext(ctx)
+	}
+	internal.SetGlobalTracer(&internal.NoopTracer{})
+	return abandoned
+}
+
+// Flush synchronously sends the traces finished so far to the agent, without
+// waiting for the next periodic flush. It returns the error which occurred
+// while sending them, or the context's error if it is done first. Traces are
+// flushed in the background on a regular basis, so it only needs to be called
+// by short-lived processes, e.g. before a serverless function returns.
+// If the tracer is not started, calling this function is a no-op.
+func Flush(ctx context.Context) error {
+	if t, ok := internal.GetGlobalTracer().(*tracer); ok {
+		return t.flushContext(ctx)
+	}
+	return nil
+}
+
 // Span is an alias for ddtrace.Span. It is here to allow godoc to group methods returning
 // ddtrace.Span. It is recommended and is considered more correct to refer to this type as
 // ddtrace.Span instead.
@@ -97,39 +190,119 @@ func Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
 	return internal.GetGlobalTracer().Inject(ctx, carrier)
 }
 
+// KeepTrace marks the trace of the given span to be kept, regardless of the
+// decision of the sampler, even if it already ran. The priority is set on the
+// whole trace and is propagated to the services downstream. It is equivalent
+// to setting the ext.ManualKeep tag on the span.
+func KeepTrace(s Span) {
+	s.SetTag(ext.ManualKeep, true)
+}
+
+// DropTrace marks the trace of the given span to be dropped, regardless of the
+// decision of the sampler, even if it already ran. It is equivalent to setting
+// the ext.ManualDrop tag on the span.
+func DropTrace(s Span) {
+	s.SetTag(ext.ManualDrop, true)
+}
+
 const (
-	// payloadQueueSize is the buffer size of the trace channel.
-	payloadQueueSize = 1000
+	// defaultPayloadQueueSize is the default buffer size of the trace channel.
+	defaultPayloadQueueSize = 1000
 
-	// errorBufferSize is the buffer size of the error channel.
-	errorBufferSize = 200
+	// defaultErrorBufferSize is the default buffer size of the error channel.
+	defaultErrorBufferSize = 200
 )
 
 func newTracer(opts ...StartOption) *tracer {
-	c := new(config)
-	defaults(c)
-	for _, fn := range opts {
-		fn(c)
-	}
+	return newTracerConfig(newConfig(opts...))
+}
+
+// newTracerConfig returns a new tracer using the given configuration.
+func newTracerConfig(c *config) *tracer {
+	resolveAgentSocket(c)
 	if c.transport == nil {
-		c.transport = newTransport(c.agentAddr, c.httpRoundTripper)
+		var ht *httpTransport
+		if c.agentSocket != "" {
+			ht = newUDSTransport(c.agentSocket)
+		} else {
+			ht = newTransport(c.agentAddr, c.httpRoundTripper)
+		}
+		ht.client.Timeout = c.httpTimeout
+		if c.statsComputation {
+			// let the agent know that it should not compute stats itself
+			ht.headers[computedStatsHeader] = "yes"
+		}
+		c.transport = ht
 	}
 	if c.propagator == nil {
 		c.propagator = NewPropagator(nil)
 	}
+	if c.runtimeMetrics && c.dogstatsdAddr == "" {
+		c.dogstatsdAddr = defaultDogstatsdAddr
+	}
+	healthMetrics := c.statsd != nil
+	if !healthMetrics && c.dogstatsdAddr != "" {
+		client, err := newDogStatsd(c.dogstatsdAddr, statsTags(c))
+		if err != nil {
+			c.warnf("unable to connect to DogStatsD at %s: %v", c.dogstatsdAddr, err)
+		} else {
+			c.statsd, healthMetrics = client, true
+		}
+	}
+	if c.statsd == nil {
+		c.statsd = noopStatsd{}
+	}
+	globalconfig.SetAnalyticsRate(c.analyticsRate)
 	t := &tracer{
-		config:         c,
-		payload:        newPayload(),
-		flushAllReq:    make(chan chan<- struct{}),
-		flushTracesReq: make(chan struct{}, 1),
-		flushErrorsReq: make(chan struct{}, 1),
-		exitReq:        make(chan struct{}),
-		payloadQueue:   make(chan []*span, payloadQueueSize),
-		errorBuffer:    make(chan error, errorBufferSize),
-		stopped:        make(chan struct{}),
+		config:           c,
+		payload:          newPayload(),
+		flushAllReq:      make(chan flushRequest),
+		flushTracesReq:   make(chan struct{}, 1),
+		flushErrorsReq:   make(chan struct{}, 1),
+		exitReq:          make(chan struct{}),
+		payloadQueue:     make(chan []*span, c.payloadQueueSize),
+		errorBuffer:      make(chan error, c.errorBufferSize),
+		errs:             make(map[string]errorSummary),
+		stopped:          make(chan struct{}),
+		prioritySampling: newPrioritySampler(),
+		retries:          newRetryBuffer(c.retryBufferSize, c.retryMaxAge),
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	if c.statsComputation {
+		t.stats = newConcentrator(defaultStatsBucketSize)
+		t.stats.obfuscation = &t.config.obfuscation
+	}
+	if c.diskBufferDir != "" {
+		d, err := openDiskBuffer(c.diskBufferDir, c.diskBufferSize)
+		if err != nil {
+			c.warnf("unable to use disk buffer in %s: %v", c.diskBufferDir, err)
+		} else {
+			t.disk = d
+		}
 	}
 
 	go t.worker()
+	if c.debugAbandonedSpans {
+		t.openSpans = newSpanTracker()
+		interval := c.spanTimeout
+		if interval > openSpansMaxInterval {
+			interval = openSpansMaxInterval
+		}
+		t.wg.Add(1)
+		go t.reportOpenSpans(interval)
+	}
+	if t.disk != nil {
+		t.wg.Add(1)
+		go t.replayDisk()
+	}
+	if healthMetrics {
+		t.wg.Add(1)
+		go t.reportHealthMetrics(healthMetricsInterval)
+		if c.runtimeMetrics {
+			t.wg.Add(1)
+			go t.reportRuntimeMetrics(runtimeMetricsInterval)
+		}
+	}
 
 	return t
 }
@@ -138,7 +311,7 @@ func newTracer(opts ...StartOption) *tracer {
 // as periodically flushes traces to the transport.
 func (t *tracer) worker() {
 	defer close(t.stopped)
-	ticker := time.NewTicker(flushInterval)
+	ticker := time.NewTicker(t.config.flushInterval)
 	defer ticker.Stop()
 
 	for {
@@ -147,25 +320,68 @@ func (t *tracer) worker() {
 			t.pushPayload(trace)
 
 		case <-ticker.C:
-			t.flush()
+			t.flush(t.ctx)
 
-		case done := <-t.flushAllReq:
-			t.flush()
-			done <- struct{}{}
+		case req := <-t.flushAllReq:
+			t.drainQueue()
+			t.retries.resetBackoff()
+			ctx, cancel := t.sendContext(req.ctx)
+			t.flushStats(ctx, true)
+			req.done <- t.flush(ctx)
+			cancel()
 
 		case <-t.flushTracesReq:
-			t.flushTraces()
+			t.flushTraces(t.ctx)
 
 		case <-t.flushErrorsReq:
-			t.flushErrors()
+			// only aggregate, so that errors are logged at most once per interval
+			t.aggregateErrors()
 
 		case <-t.exitReq:
-			t.flush()
+			t.drainQueue()
+			t.retries.resetBackoff()
+			pending, sent, spilled := t.payload.itemCount()+t.retries.count, t.sentTraces, t.spilledTraces
+			t.flushTraces(t.ctx)
+			t.flushStats(t.ctx, true)
+			for t.disk != nil && t.retries.len() > 0 {
+				// keep the remaining traces for the next process
+				t.spill(t.retries.pop())
+			}
+			t.abandoned = pending - (t.sentTraces - sent) - (t.spilledTraces - spilled)
+			t.flushErrors()
 			return
 		}
 	}
 }
 
+// drainQueue pushes the traces found in the payload queue onto the payload,
+// so that they are part of the next flush.
+func (t *tracer) drainQueue() {
+	for {
+		select {
+		case trace := <-t.payloadQueue:
+			t.pushPayload(trace)
+		default:
+			return
+		}
+	}
+}
+
+// sendContext returns a context derived from ctx which is also canceled when
+// the tracer's context is. The returned cancel function must be called once
+// the context is no longer used.
+func (t *tracer) sendContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-t.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 func (t *tracer) pushTrace(trace []*span) {
 	select {
 	case <-t.stopped:
@@ -174,7 +390,9 @@ func (t *tracer) pushTrace(trace []*span) {
 	}
 	select {
 	case t.payloadQueue <- trace:
+		atomic.AddUint64(&t.health.tracesEnqueued, 1)
 	default:
+		atomic.AddUint64(&t.health.tracesDroppedQueueFull, 1)
 		t.pushError(&dataLossError{
 			context: errors.New("payload queue full, dropping trace"),
 			count:   len(trace),
@@ -186,6 +404,12 @@ func (t *tracer) pushTrace(trace []*span) {
 	}
 }
 
+// pushPartialTrace pushes a chunk of a trace which has not yet completed.
+func (t *tracer) pushPartialTrace(chunk []*span) {
+	atomic.AddUint64(&t.partialFlushes, 1)
+	t.pushTrace(chunk)
+}
+
 func (t *tracer) pushError(err error) {
 	select {
 	case <-t.stopped:
@@ -214,6 +438,7 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 	for _, fn := range options {
 		fn(&opts)
 	}
+	atomic.AddUint64(&t.health.spansStarted, 1)
 	var startTime int64
 	if opts.StartTime.IsZero() {
 		startTime = now()
@@ -253,10 +478,18 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 		}
 	}
 	span.context = newSpanContext(span, context)
+	if t.openSpans != nil {
+		t.openSpans.add(span, spanStartLocation())
+	}
+	if origin := span.context.origin; origin != "" {
+		span.Meta[originMetaKey] = origin
+	}
+	if context == nil && t.config.traceID128 {
+		span.context.traceIDHigh = generateTraceIDHigh()
+	}
 	if context == nil || context.span == nil {
 		// this is either a global root span or a process-level root span
 		span.SetTag(ext.Pid, strconv.Itoa(os.Getpid()))
-		t.sample(span)
 	}
 	// add tags from options
 	for k, v := range opts.Tags {
@@ -266,18 +499,50 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 	for k, v := range t.config.globalTags {
 		span.SetTag(k, v)
 	}
+	if context == nil || context.span == nil {
+		span.topLevel = true
+	} else {
+		context.span.RLock()
+		span.topLevel = context.span.Service != span.Service
+		context.span.RUnlock()
+	}
+	if context == nil || context.span == nil {
+		// sample root spans only after all tags were set, so that the
+		// priority sampler can take into account the service and env.
+		t.sample(span)
+	}
 	return span
 }
 
 // Stop stops the tracer.
 func (t *tracer) Stop() {
+	t.stopContext(context.Background())
+}
+
+// stopContext stops the tracer, sending the remaining traces to the agent
+// until ctx is done. It returns the number of traces which were abandoned.
+func (t *tracer) stopContext(ctx context.Context) (abandoned int) {
 	select {
 	case <-t.stopped:
-		return
+		return 0
 	default:
-		t.exitReq <- struct{}{}
-		<-t.stopped
 	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// abort the ongoing send, if any, so that the worker exits
+			t.cancel()
+		case <-done:
+		}
+	}()
+	t.exitReq <- struct{}{}
+	<-t.stopped
+	t.cancel()
+	t.wg.Wait()
+	t.config.statsd.Close()
+	return t.abandoned
 }
 
 // Inject uses the configured or default TextMap Propagator.
@@ -290,48 +555,327 @@ func (t *tracer) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
 	return t.config.propagator.Extract(carrier)
 }
 
-// flushTraces will push any currently buffered traces to the server.
-func (t *tracer) flushTraces() {
+// flushTraces will push any currently buffered traces to the server, giving
+// up once ctx is done. Payloads which previously failed to be sent are retried
+// first, unless the retry backoff has not yet elapsed, in which case the buffered
+// traces are queued behind them. It returns the error which occurred while
+// sending the traces.
+func (t *tracer) flushTraces(ctx context.Context) error {
+	now := time.Now()
+	if expired := t.retries.expire(now); len(expired) > 0 {
+		t.evict(expired, dropReasonRetryExpired, errors.New("retry buffer max age reached"))
+	}
+	var err error
+	if t.retries.len() > 0 && t.retries.ready(now) {
+		err = t.sendRetries(ctx, now)
+	}
 	if t.payload.itemCount() == 0 {
+		return err
+	}
+	partial := atomic.SwapUint64(&t.partialFlushes, 0)
+	t.config.statsd.Count(metricPartialFlushes, int64(partial), nil, 1)
+	if t.retries.len() > 0 {
+		// keep the payloads in order
+		t.retryLater(t.payload, err, now)
+		t.payload = newPayload()
+		return err
+	}
+	t.debugf("Sending payload: size: %d traces: %d partial: %d", t.payload.size(), t.payload.itemCount(), partial)
+	if err = t.sendPayload(ctx, t.payload); err != nil {
+		t.retryOrDrop(t.payload, err, now)
+		t.payload = newPayload()
+		return err
+	}
+	t.payload.reset()
+	return nil
+}
+
+// sendRetries sends the payloads of the retry buffer, oldest first, stopping at
+// the first one which fails to be sent and should be retried.
+func (t *tracer) sendRetries(ctx context.Context, now time.Time) error {
+	for t.retries.len() > 0 {
+		p := t.retries.peek()
+		t.debugf("Retrying payload: size: %d traces: %d", p.size(), p.itemCount())
+		err := t.sendPayload(ctx, p)
+		if err != nil && retryable(err) {
+			t.retries.backoff(now, retryAfter(err))
+			return err
+		}
+		t.retries.pop()
+		if err != nil {
+			t.dropPayload(p, err)
+		}
+	}
+	t.retries.resetBackoff()
+	return nil
+}
+
+// sendPayload sends p to the agent, reporting metrics about the flush.
+func (t *tracer) sendPayload(ctx context.Context, p *payload) error {
+	size, count := p.size(), p.itemCount()
+	stats := t.config.statsd
+	stats.Count(metricFlushTraces, int64(count), nil, 1)
+	stats.Count(metricFlushBytes, int64(size), nil, 1)
+	start := time.Now()
+	rc, err := t.config.transport.send(ctx, p)
+	stats.Timing(metricFlushDuration, time.Since(start), nil, 1)
+	if err != nil {
+		stats.Count(metricFlushErrors, 1, nil, 1)
+		return err
+	}
+	t.sentTraces += count
+	if t.disk != nil {
+		// the agent is available, send the spooled payloads too
+		t.disk.notify()
+	}
+	if !t.config.prioritySampling {
+		rc.Close()
+	} else if err := t.prioritySampling.readRatesJSON(rc); err != nil {
+		t.debugf("Unable to read sampling rates from agent response: %v", err)
+	}
+	return nil
+}
+
+// retryOrDrop keeps the payload p, which failed to be sent because of err, in
+// the retry buffer or on disk if it should be retried, and drops it otherwise.
+func (t *tracer) retryOrDrop(p *payload, err error, now time.Time) {
+	if !retryable(err) || (!t.retries.enabled() && t.disk == nil) {
+		t.dropPayload(p, err)
 		return
 	}
-	size, count := t.payload.size(), t.payload.itemCount()
-	if t.config.debug {
-		log.Printf("Sending payload: size: %d traces: %d\n", size, count)
+	t.retries.backoff(now, retryAfter(err))
+	t.retryLater(p, err, now)
+}
+
+// retryLater adds p to the retry buffer, or spools it to disk when retries are
+// disabled. err is the error which occurred when sending p or, when p was not
+// sent, when sending the previous payloads.
+func (t *tracer) retryLater(p *payload, err error, now time.Time) {
+	if !t.retries.enabled() && t.disk == nil {
+		t.dropPayload(p, err)
+		return
 	}
-	err := t.config.transport.send(t.payload)
 	if err != nil {
+		t.pushError(&sendRetryError{context: err, count: p.itemCount()})
+	}
+	if !t.retries.enabled() {
+		t.spill(p)
+		return
+	}
+	if evicted := t.retries.push(p, now); len(evicted) > 0 {
+		t.evict(evicted, dropReasonRetryFull, errors.New("retry buffer full"))
+	}
+}
+
+// evict handles the payloads removed from the retry buffer for the given reason
+// and err, spooling them to disk if enabled and dropping them otherwise.
+func (t *tracer) evict(ps []*payload, reason string, err error) {
+	if t.disk != nil {
+		for _, p := range ps {
+			t.spill(p)
+		}
+		return
+	}
+	n := traceCount(ps)
+	t.config.statsd.Count(metricTracesDropped, int64(n), []string{reason}, 1)
+	t.pushError(&dataLossError{context: err, count: n})
+}
+
+// spill spools p to disk, dropping it if it can not be written. The traces of
+// the oldest spooled payloads are dropped when the disk buffer is full.
+func (t *tracer) spill(p *payload) {
+	count := p.itemCount()
+	t.debugf("Spooling payload to disk: size: %d traces: %d", p.size(), count)
+	evicted, err := t.disk.write(p)
+	if evicted > 0 {
+		t.config.statsd.Count(metricTracesDropped, int64(evicted), []string{dropReasonDiskFull}, 1)
+		t.pushError(&dataLossError{context: errors.New("disk buffer full"), count: evicted})
+	}
+	if err != nil {
+		reason := dropReasonDiskError
+		if err == errSegmentTooLarge {
+			reason = dropReasonDiskFull
+		}
+		t.config.statsd.Count(metricTracesDropped, int64(count), []string{reason}, 1)
 		t.pushError(&dataLossError{context: err, count: count})
+		return
+	}
+	t.spilledTraces += count
+}
+
+// replayDisk sends the payloads spooled to disk to the agent, oldest first,
+// until the tracer stops. After a failure, it waits for the backoff to elapse
+// before trying again, unless the worker manages to send traces in between.
+func (t *tracer) replayDisk() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(t.config.flushInterval)
+	defer ticker.Stop()
+	var b retryBackoff
+	for {
+		select {
+		case <-ticker.C:
+			if !b.ready(time.Now()) {
+				continue
+			}
+		case <-t.disk.wake:
+			b.resetBackoff()
+		case <-t.stopped:
+			return
+		}
+		if err := t.sendSpooled(t.ctx); err != nil {
+			b.backoff(time.Now(), retryAfter(err))
+		} else {
+			b.resetBackoff()
+		}
 	}
-	t.payload.reset()
 }
 
-// flushErrors will process log messages that were queued
+// sendSpooled sends the payloads of the disk buffer, oldest first, stopping at
+// the first one which fails to be sent and should be retried.
+func (t *tracer) sendSpooled(ctx context.Context) error {
+	for {
+		s, ok := t.disk.oldest()
+		if !ok {
+			return nil
+		}
+		p, err := t.disk.read(s)
+		if err != nil {
+			t.config.statsd.Count(metricTracesDropped, int64(s.count), []string{dropReasonDiskError}, 1)
+			t.pushError(&dataLossError{context: err, count: s.count})
+			continue
+		}
+		t.debugf("Sending spooled payload: size: %d traces: %d", p.size(), p.itemCount())
+		rc, err := t.config.transport.send(ctx, p)
+		if err != nil && retryable(err) {
+			t.disk.release(s.seq)
+			return err
+		}
+		t.disk.remove(s.seq)
+		if err != nil {
+			t.dropPayload(p, err)
+			continue
+		}
+		rc.Close()
+	}
+}
+
+// dropPayload drops the payload p, which failed to be sent because of err.
+func (t *tracer) dropPayload(p *payload, err error) {
+	count := p.itemCount()
+	t.config.statsd.Count(metricTracesDropped, int64(count), []string{dropReasonSendFailed}, 1)
+	t.pushError(&dataLossError{context: err, count: count})
+}
+
+// aggregateErrors drains the error buffer, summarizing its errors until they
+// are logged by flushErrors.
+func (t *tracer) aggregateErrors() {
+	if t.errs == nil {
+		t.errs = make(map[string]errorSummary)
+	}
+	aggregateErrorsInto(t.errs, t.errorBuffer)
+}
+
+// flushErrors will log the summaries of all the errors that were queued.
 func (t *tracer) flushErrors() {
-	logErrors(t.errorBuffer)
+	t.aggregateErrors()
+	if len(t.errs) == 0 {
+		return
+	}
+	logErrors(t.config.logger, t.errs)
+	t.errs = make(map[string]errorSummary)
 }
 
-func (t *tracer) flush() {
-	t.flushTraces()
+// flush sends the buffered traces and the complete stats buckets, and logs
+// the queued errors. It returns the error which occurred while sending the
+// traces.
+func (t *tracer) flush(ctx context.Context) error {
+	err := t.flushTraces(ctx)
+	t.flushStats(ctx, false)
 	t.flushErrors()
+	return err
+}
+
+// flushStats sends the stats buckets which are complete, or all of them when
+// all is true, to the agent, if the tracer computes stats.
+func (t *tracer) flushStats(ctx context.Context, all bool) {
+	if t.stats == nil {
+		return
+	}
+	buckets := t.stats.flush(time.Now(), all)
+	if len(buckets) == 0 {
+		return
+	}
+	t.statsSequence++
+	p := &statsPayload{
+		Env:           t.config.globalTag(ext.Environment),
+		Version:       t.config.globalTag(ext.Version),
+		Service:       t.config.serviceName,
+		Lang:          "go",
+		TracerVersion: tracerVersion,
+		Sequence:      t.statsSequence,
+		Stats:         buckets,
+	}
+	t.debugf("Sending stats: buckets: %d", len(buckets))
+	if err := t.config.transport.sendStats(ctx, p); err != nil {
+		t.pushError(&statsError{context: err})
+	}
+}
+
+// flushRequest is a request for the worker to flush all of its data.
+type flushRequest struct {
+	ctx  context.Context // done when the flush should be given up
+	done chan<- error    // receives the result of the flush
+}
+
+// flushContext synchronously flushes all data to the agent, returning the
+// error which occurred while sending the traces, or ctx's error if it is done
+// first.
+func (t *tracer) flushContext(ctx context.Context) error {
+	done := make(chan error, 1)
+	select {
+	case t.flushAllReq <- flushRequest{ctx: ctx, done: done}:
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // forceFlush forces a flush of data (traces and services) to the agent.
 // Flushes are done by a background task on a regular basis, so you never
 // need to call this manually, mostly useful for testing and debugging.
 func (t *tracer) forceFlush() {
-	done := make(chan struct{})
-	t.flushAllReq <- done
-	<-done
+	t.flushContext(context.Background())
 }
 
 // pushPayload pushes the trace onto the payload. If the payload becomes
 // larger than the threshold as a result, it sends a flush request.
 func (t *tracer) pushPayload(trace []*span) {
-	if err := t.payload.push(trace); err != nil {
-		t.pushError(&traceEncodingError{context: err})
+	if t.stats != nil {
+		// computed before the processors, so that the traces and spans which
+		// they drop are still counted
+		t.stats.add(trace)
+	}
+	if trace = t.processTrace(trace); trace != nil {
+		t.config.obfuscation.obfuscate(trace)
 	}
-	if t.payload.size() > payloadSizeLimit {
+	if trace != nil && !trace[0].context.isSampled() {
+		// only pushed to compute stats
+		trace = nil
+	}
+	if trace != nil {
+		if err := t.payload.push(trace); err != nil {
+			t.config.statsd.Count(metricTracesDropped, 1, []string{dropReasonEncoding}, 1)
+			t.pushError(&traceEncodingError{context: err})
+		}
+	}
+	if t.payload.size() > t.config.payloadSizeLimit {
 		// getting large
 		select {
 		case t.flushTracesReq <- struct{}{}:
@@ -345,26 +889,72 @@ func (t *tracer) pushPayload(trace []*span) {
 	}
 }
 
-// sampleRateMetricKey is the metric key holding the applied sample rate. Has to be the same as the Agent.
-const sampleRateMetricKey = "_sample_rate"
+const (
+	// sampleRateMetricKey is the metric key holding the applied sample rate. Has to be the same as the Agent.
+	sampleRateMetricKey = "_sample_rate"
+
+	// samplingPriorityRateKey is the metric key holding the rate used by the priority
+	// sampler to make its decision. Has to be the same as the Agent.
+	samplingPriorityRateKey = "_dd.agent_psr"
+)
 
-// Sample samples a span with the internal sampler.
+// Sample samples a span with the internal sampler. When priority sampling is
+// enabled, spans which pass the client-side sampler are additionally assigned
+// a sampling priority using the rates received from the agent, unless one was
+// already set. Traces with an auto-reject priority are still sent to the agent,
+// so that it can compute accurate statistics.
 func (t *tracer) sample(span *span) {
+	if _, ok := span.context.trace.userPriority(); ok {
+		// the user already decided whether to keep the trace
+		return
+	}
 	sampler := t.config.sampler
 	sampled := sampler.Sample(span)
 	span.context.sampled = sampled
+	if _, ok := sampler.(*RulesSampler); ok && t.sampleByRule(span, sampled) {
+		return
+	}
 	if !sampled {
 		return
 	}
+	span.Lock()
+	defer span.Unlock()
+	if span.finished {
+		// we don't touch finished span as they might be flushing
+		return
+	}
 	if rs, ok := sampler.(RateSampler); ok && rs.Rate() < 1 {
 		// the span was sampled using a rate sampler which wasn't all permissive,
 		// so we make note of the sampling rate.
-		span.Lock()
-		defer span.Unlock()
-		if span.finished {
-			// we don't touch finished span as they might be flushing
-			return
-		}
 		span.Metrics[sampleRateMetricKey] = rs.Rate()
 	}
+	if !t.config.prioritySampling || span.context.hasSamplingPriority() {
+		// priority sampling is disabled or a decision was already made,
+		// either by the user or by an upstream service.
+		return
+	}
+	t.prioritySampling.apply(span)
+}
+
+// sampleByRule sets the sampling priority of span, which was sampled by a
+// RulesSampler, when a rule matched it. The decision of the rule was made by
+// the user, so it is propagated as a user priority and takes precedence over
+// the rates of the agent. It reports whether a rule matched.
+func (t *tracer) sampleByRule(span *span, sampled bool) bool {
+	span.Lock()
+	defer span.Unlock()
+	if _, ok := span.Metrics[ruleSampleRateMetricKey]; !ok {
+		return false
+	}
+	if span.finished || span.context.hasSamplingPriority() {
+		// the span is flushing, or a decision was already made, either by
+		// the user or by an upstream service.
+		return true
+	}
+	if sampled {
+		span.setTagNumeric(ext.SamplingPriority, ext.PriorityUserKeep)
+	} else {
+		span.setTagNumeric(ext.SamplingPriority, ext.PriorityUserReject)
+	}
+	return true
 }
@@ -1,12 +1,17 @@
 package tracer
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -362,6 +367,222 @@ func TestTracerSampler(t *testing.T) {
 	assert.True(ok)
 }
 
+func TestTracerPrioritySampler(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"rate_by_service":{
+				"service:,env:":0.1,
+				"service:my-service,env:":0.2,
+				"service:my-service,env:default":0.2,
+				"service:my-service,env:other":0.3
+			}}`))
+	}))
+	addr := srv.Listener.Addr().String()
+
+	tr, _, stop := startTestTracer(
+		withTransport(newHTTPTransport(addr, defaultRoundTripper)),
+		WithPrioritySampling(),
+	)
+	defer stop()
+
+	s := tr.newRootSpan("pylons.request", "pylons", "/")
+	assert.Equal(1., s.Metrics[samplingPriorityRateKey])
+	assert.EqualValues(ext.PriorityAutoKeep, s.Metrics[samplingPriorityKey])
+	s.Finish()
+
+	tr.forceFlush()
+
+	for i, tt := range []struct {
+		service, env string
+		rate         float64
+	}{
+		{"pylons", "", 0.1},
+		{"my-service", "", 0.2},
+		{"my-service", "default", 0.2},
+		{"my-service", "other", 0.3},
+		{"other-service", "other", 0.1},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			s := tr.StartSpan("name", ServiceName(tt.service), Tag(ext.Environment, tt.env)).(*span)
+			assert.Equal(tt.rate, s.Metrics[samplingPriorityRateKey])
+			prio, ok := s.Metrics[samplingPriorityKey]
+			assert.True(ok)
+			assert.Contains([]float64{ext.PriorityAutoReject, ext.PriorityAutoKeep}, prio)
+			assert.EqualValues(prio, s.context.samplingPriority())
+
+			child := tr.StartSpan("child", ChildOf(s.Context())).(*span)
+			assert.Equal(prio, child.Metrics[samplingPriorityKey])
+			_, ok = child.Metrics[samplingPriorityRateKey]
+			assert.False(ok, "only root spans should hold the rate")
+		})
+	}
+
+	t.Run("user", func(t *testing.T) {
+		s := tr.StartSpan("name", Tag(ext.SamplingPriority, ext.PriorityUserKeep)).(*span)
+		assert.EqualValues(ext.PriorityUserKeep, s.Metrics[samplingPriorityKey])
+		_, ok := s.Metrics[samplingPriorityRateKey]
+		assert.False(ok, "user decisions should not be overridden")
+	})
+
+	t.Run("propagated", func(t *testing.T) {
+		ctx, err := NewPropagator(nil).Extract(TextMapCarrier{
+			DefaultTraceIDHeader:  "1",
+			DefaultParentIDHeader: "2",
+			DefaultPriorityHeader: "-1",
+		})
+		assert.NoError(err)
+		s := tr.StartSpan("name", ChildOf(ctx)).(*span)
+		assert.EqualValues(ext.PriorityUserReject, s.Metrics[samplingPriorityKey])
+		_, ok := s.Metrics[samplingPriorityRateKey]
+		assert.False(ok)
+	})
+}
+
+func TestTracer128BitTraceIDs(t *testing.T) {
+	t.Run("enabled", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(With128BitTraceIDs())
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		rctx, cctx := root.context, child.context
+		assert.NotZero(rctx.traceIDHigh)
+		assert.Equal(rctx.traceIDHigh, cctx.traceIDHigh)
+		assert.InDelta(time.Now().Unix(), int64(rctx.traceIDHigh>>32), 5)
+		assert.Equal(fmt.Sprintf("%016x%016x", rctx.traceIDHigh, root.TraceID), cctx.TraceID128())
+		assert.Equal(root.TraceID, child.TraceID)
+
+		child.Finish()
+		root.Finish()
+		tracer.forceFlush()
+		traces := transport.Traces()
+		if assert.Len(traces, 1) && assert.Len(traces[0], 2) {
+			assert.Equal(fmt.Sprintf("%016x", rctx.traceIDHigh), traces[0][0].Meta[traceIDHighMetaKey])
+			assert.NotContains(traces[0][1].Meta, traceIDHighMetaKey)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer()
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		assert.Zero(root.context.traceIDHigh)
+		assert.Equal(fmt.Sprintf("%032x", root.TraceID), root.context.TraceID128())
+		root.Finish()
+		tracer.forceFlush()
+		traces := transport.Traces()
+		if assert.Len(traces, 1) {
+			assert.NotContains(traces[0][0].Meta, traceIDHighMetaKey)
+		}
+	})
+}
+
+func TestTracerPrioritySamplerRejected(t *testing.T) {
+	assert := assert.New(t)
+	tracer, transport, stop := startTestTracer(WithPrioritySampling())
+	defer stop()
+
+	err := tracer.prioritySampling.readRatesJSON(
+		ioutil.NopCloser(strings.NewReader(`{"rate_by_service":{"service:,env:":0}}`)),
+	)
+	assert.NoError(err)
+
+	root := tracer.newRootSpan("pylons.request", "pylons", "/")
+	child := tracer.newChildSpan("redis.command", root)
+	child.Finish()
+	root.Finish()
+	tracer.forceFlush()
+
+	// auto-rejected traces are still sent to the agent
+	traces := transport.Traces()
+	assert.Len(traces, 1)
+	assert.Len(traces[0], 2)
+	for _, s := range traces[0] {
+		assert.EqualValues(ext.PriorityAutoReject, s.Metrics[samplingPriorityKey])
+	}
+}
+
+func TestTracerManualPriority(t *testing.T) {
+	t.Run("keep", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(WithSampler(NewRateSampler(0)))
+		defer stop()
+
+		root := tracer.StartSpan("http.request").(*span)
+		assert.False(root.context.sampled)
+		child := tracer.StartSpan("db.query", ChildOf(root.Context()))
+		other := tracer.StartSpan("cache", ChildOf(root.Context()))
+		KeepTrace(child)
+
+		// the priority is propagated from any span of the trace
+		carrier := TextMapCarrier(map[string]string{})
+		assert.NoError(tracer.Inject(other.Context(), carrier))
+		assert.Equal("2", carrier[DefaultPriorityHeader])
+
+		other.Finish()
+		child.Finish()
+		root.Finish()
+		tracer.forceFlush()
+
+		traces := transport.Traces()
+		assert.Len(traces, 1)
+		assert.Len(traces[0], 3)
+		for _, s := range traces[0] {
+			assert.EqualValues(ext.PriorityUserKeep, s.Metrics[samplingPriorityKey])
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer()
+		defer stop()
+
+		root := tracer.StartSpan("http.request")
+		child := tracer.StartSpan("db.query", ChildOf(root.Context()))
+		child.SetTag(ext.ManualDrop, true)
+		child.Finish()
+		root.Finish()
+		tracer.forceFlush()
+
+		assert.Len(transport.Traces(), 0)
+	})
+
+	t.Run("start", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer(WithSampler(NewRateSampler(0)))
+		defer stop()
+
+		root := tracer.StartSpan("http.request", Tag(ext.ManualKeep, true)).(*span)
+		assert.EqualValues(ext.PriorityUserKeep, root.context.samplingPriority())
+		root.Finish()
+		tracer.forceFlush()
+
+		assert.Len(transport.Traces(), 1)
+	})
+
+	t.Run("false", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, stop := startTestTracer()
+		defer stop()
+
+		root := tracer.StartSpan("http.request").(*span)
+		for _, v := range []interface{}{false, "false", 0, nil} {
+			root.SetTag(ext.ManualDrop, v)
+		}
+		_, ok := root.context.trace.userPriority()
+		assert.False(ok)
+		assert.NotContains(root.Meta, ext.ManualDrop)
+		root.Finish()
+		tracer.forceFlush()
+
+		assert.Len(transport.Traces(), 1)
+	})
+}
+
 func TestTracerEdgeSampler(t *testing.T) {
 	assert := assert.New(t)
 
@@ -378,7 +599,7 @@ func TestTracerEdgeSampler(t *testing.T) {
 	)
 	defer stop()
 
-	count := payloadQueueSize / 3
+	count := defaultPayloadQueueSize / 3
 
 	for i := 0; i < count; i++ {
 		span0 := tracer0.newRootSpan("pylons.request", "pylons", "/")
@@ -561,7 +782,7 @@ func TestTracerRace(t *testing.T) {
 	tracer, transport, stop := startTestTracer()
 	defer stop()
 
-	total := payloadQueueSize / 3
+	total := defaultPayloadQueueSize / 3
 	var wg sync.WaitGroup
 	wg.Add(total)
 
@@ -670,7 +891,7 @@ func TestWorker(t *testing.T) {
 	tracer, transport, stop := startTestTracer()
 	defer stop()
 
-	n := payloadQueueSize * 10 // put more traces than the chan size, on purpose
+	n := defaultPayloadQueueSize * 10 // put more traces than the chan size, on purpose
 	for i := 0; i < n; i++ {
 		root := tracer.newRootSpan("pylons.request", "pylons", "/")
 		child := tracer.newChildSpan("redis.command", root)
@@ -680,7 +901,7 @@ func TestWorker(t *testing.T) {
 
 	now := time.Now()
 	count := 0
-	for time.Now().Before(now.Add(time.Minute)) && count < payloadQueueSize {
+	for time.Now().Before(now.Add(time.Minute)) && count < defaultPayloadQueueSize {
 		nbTraces := len(transport.Traces())
 		if nbTraces > 0 {
 			t.Logf("popped %d traces", nbTraces)
@@ -691,16 +912,133 @@ func TestWorker(t *testing.T) {
 	// here we just check that we have "enough traces". In practice, lots of them
 	// are dropped, it's another interesting side-effect of this test: it does
 	// trigger error messages (which are repeated, so it aggregates them etc.)
-	if count < payloadQueueSize {
+	if count < defaultPayloadQueueSize {
 		assert.Fail(fmt.Sprintf("timeout, not enough traces in buffer (%d/%d)", count, n))
 	}
 }
 
+func TestTracerFlush(t *testing.T) {
+	t.Run("queued", func(t *testing.T) {
+		assert := assert.New(t)
+		transport := newDummyTransport()
+		tracer := newTracer(withTransport(transport))
+		internal.SetGlobalTracer(tracer)
+		defer internal.SetGlobalTracer(&internal.NoopTracer{})
+
+		for i := 0; i < 3; i++ {
+			tracer.StartSpan("op").Finish()
+		}
+		assert.NoError(Flush(context.Background()))
+		assert.Len(transport.Traces(), 3)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		assert := assert.New(t)
+		srv, unblock := newBlockingServer()
+		defer unblock()
+		tracer := newTracer(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		internal.SetGlobalTracer(tracer)
+		defer internal.SetGlobalTracer(&internal.NoopTracer{})
+
+		tracer.StartSpan("op").Finish()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		assert.Equal(context.DeadlineExceeded, tracer.flushContext(ctx))
+		assert.True(time.Since(start) < 500*time.Millisecond)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		tracer := newTracer(withTransport(newDummyTransport()))
+		tracer.Stop()
+		assert.NoError(t, tracer.flushContext(context.Background()))
+	})
+}
+
+func TestTracerStopWithTimeout(t *testing.T) {
+	t.Run("sent", func(t *testing.T) {
+		assert := assert.New(t)
+		transport := newDummyTransport()
+		tracer := newTracer(withTransport(transport))
+		internal.SetGlobalTracer(tracer)
+
+		tracer.StartSpan("op").Finish()
+		assert.Zero(StopWithTimeout(time.Second))
+		assert.Len(transport.Traces(), 1)
+		assert.Equal(&internal.NoopTracer{}, internal.GetGlobalTracer())
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		assert := assert.New(t)
+		srv, unblock := newBlockingServer()
+		defer unblock()
+		tracer := newTracer(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		internal.SetGlobalTracer(tracer)
+
+		for i := 0; i < 3; i++ {
+			tracer.StartSpan("op").Finish()
+		}
+		start := time.Now()
+		assert.Equal(3, StopWithTimeout(50*time.Millisecond))
+		assert.True(time.Since(start) < 500*time.Millisecond)
+		assert.Zero(StopWithTimeout(50 * time.Millisecond))
+	})
+}
+
+// newBlockingServer returns a test server which does not respond to requests
+// until unblock is called.
+func newBlockingServer() (srv *httptest.Server, unblock func()) {
+	block := make(chan struct{})
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	return srv, func() {
+		close(block)
+		srv.Close()
+	}
+}
+
+func TestTracerPayloadQueueSize(t *testing.T) {
+	// burst pushes n traces while the worker is blocked processing the first
+	// one, returning the number of traces dropped because the queue was full.
+	burst := func(n int, opts ...StartOption) uint64 {
+		block := make(chan struct{})
+		blocked := make(chan struct{}, 1)
+		tracer := newTracer(append(opts,
+			withTransport(newDummyTransport()),
+			WithSpanProcessor(func(*FinishedTrace) bool {
+				select {
+				case blocked <- struct{}{}:
+					<-block
+				default:
+				}
+				return true
+			}),
+		)...)
+		defer tracer.Stop()
+		defer close(block)
+
+		tracer.pushTrace([]*span{newBasicSpan("first")})
+		<-blocked
+		for i := 0; i < n; i++ {
+			tracer.pushTrace([]*span{newBasicSpan("burst")})
+		}
+		return atomic.LoadUint64(&tracer.health.tracesDroppedQueueFull)
+	}
+	n := 3 * defaultPayloadQueueSize
+	assert.EqualValues(t, n-defaultPayloadQueueSize, burst(n))
+	assert.Zero(t, burst(n, WithPayloadQueueSize(n)))
+}
+
 func newTracerChannels() *tracer {
 	return &tracer{
+		config:         newConfig(),
 		payload:        newPayload(),
-		payloadQueue:   make(chan []*span, payloadQueueSize),
-		errorBuffer:    make(chan error, errorBufferSize),
+		payloadQueue:   make(chan []*span, defaultPayloadQueueSize),
+		errorBuffer:    make(chan error, defaultErrorBufferSize),
 		flushTracesReq: make(chan struct{}, 1),
 		flushErrorsReq: make(chan struct{}, 1),
 	}
@@ -709,7 +1047,7 @@ func newTracerChannels() *tracer {
 func TestPushPayload(t *testing.T) {
 	tracer := newTracerChannels()
 	s := newBasicSpan("3MB")
-	s.Meta["key"] = strings.Repeat("X", payloadSizeLimit/2+10)
+	s.Meta["key"] = strings.Repeat("X", defaultPayloadSizeLimit/2+10)
 
 	// half payload size reached, we have 1 item, no flush request
 	tracer.pushPayload([]*span{s})
@@ -746,11 +1084,11 @@ func TestPushTrace(t *testing.T) {
 	t0 := <-tracer.payloadQueue
 	assert.Equal(trace, t0)
 
-	many := payloadQueueSize + 2
+	many := defaultPayloadQueueSize + 2
 	for i := 0; i < many; i++ {
 		tracer.pushTrace(make([]*span, i))
 	}
-	assert.Len(tracer.payloadQueue, payloadQueueSize)
+	assert.Len(tracer.payloadQueue, defaultPayloadQueueSize)
 	assert.Len(tracer.errorBuffer, 2)
 }
 
@@ -768,7 +1106,7 @@ func TestPushErr(t *testing.T) {
 	pushed := <-tracer.errorBuffer
 	assert.Equal(err, pushed)
 
-	many := errorBufferSize/2 + 1
+	many := defaultErrorBufferSize/2 + 1
 	for i := 0; i < many; i++ {
 		tracer.pushError(fmt.Errorf("err %d", i))
 	}
@@ -828,23 +1166,39 @@ func startTestTracer(opts ...StartOption) (*tracer, *dummyTransport, func()) {
 type dummyTransport struct {
 	sync.RWMutex
 	traces spanLists
+	stats  []*statsPayload
 }
 
 func newDummyTransport() *dummyTransport {
 	return &dummyTransport{traces: spanLists{}}
 }
 
-func (t *dummyTransport) send(p *payload) error {
+func (t *dummyTransport) send(_ context.Context, p *payload) (io.ReadCloser, error) {
 	traces, err := decode(p)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	t.Lock()
 	t.traces = append(t.traces, traces...)
 	t.Unlock()
+	ok := ioutil.NopCloser(strings.NewReader("OK"))
+	return ok, nil
+}
+
+func (t *dummyTransport) sendStats(_ context.Context, p *statsPayload) error {
+	t.Lock()
+	t.stats = append(t.stats, p)
+	t.Unlock()
 	return nil
 }
 
+// Stats returns the stats payloads sent so far.
+func (t *dummyTransport) Stats() []*statsPayload {
+	t.Lock()
+	defer t.Unlock()
+	return t.stats
+}
+
 func decode(p *payload) (spanLists, error) {
 	var traces spanLists
 	err := msgp.Decode(p, &traces)
@@ -1,13 +1,17 @@
 package tracer
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -33,17 +37,32 @@ var (
 	}
 )
 
+// defaultSocketAPM specifies the socket path to use for connecting to the trace
+// agent when it is found to exist. It is a variable to allow changing it in tests.
+var defaultSocketAPM = "/var/run/datadog/apm.socket"
+
 const (
 	defaultHostname    = "localhost"
 	defaultPort        = "8126"
 	defaultAddress     = defaultHostname + ":" + defaultPort
-	defaultHTTPTimeout = time.Second             // defines the current timeout before giving up with the send process
+	defaultHTTPTimeout = time.Second             // defines the default timeout before giving up with the send process
 	traceCountHeader   = "X-Datadog-Trace-Count" // header containing the number of traces in the payload
+
+	// computedStatsHeader is the header telling the agent that the stats of
+	// the traces were computed by the tracer.
+	computedStatsHeader = "Datadog-Client-Computed-Stats"
 )
 
 // Transport is an interface for span submission to the agent.
 type transport interface {
-	send(p *payload) error
+	// send sends the payload p to the agent using the transport set up.
+	// It returns a non-nil response body when no error occurred.
+	// The request is canceled when ctx is done.
+	send(ctx context.Context, p *payload) (body io.ReadCloser, err error)
+
+	// sendStats sends the stats payload p to the agent using the transport set up.
+	// The request is canceled when ctx is done.
+	sendStats(ctx context.Context, p *statsPayload) error
 }
 
 // newTransport returns a new Transport implementation that sends traces to a
@@ -56,7 +75,7 @@ type transport interface {
 // running on a non-default port, if it's located on another machine, or when
 // otherwise needing to customize the transport layer, for instance when using
 // a unix domain socket.
-func newTransport(addr string, roundTripper http.RoundTripper) transport {
+func newTransport(addr string, roundTripper http.RoundTripper) *httpTransport {
 	if roundTripper == nil {
 		roundTripper = defaultRoundTripper
 	}
@@ -68,14 +87,64 @@ func newDefaultTransport() transport {
 	return newHTTPTransport(defaultAddress, defaultRoundTripper)
 }
 
+// newUDSTransport returns a new transport which sends traces to a trace agent
+// listening on the Unix Domain Socket found at the given path.
+func newUDSTransport(socketPath string) *httpTransport {
+	return newHTTPTransportHost(udsHost(socketPath), udsRoundTripper(socketPath))
+}
+
+// udsRoundTripper returns an http.RoundTripper which dials the Unix Domain Socket
+// found at socketPath, regardless of the address found in the request.
+func udsRoundTripper(socketPath string) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// udsHost returns a placeholder host to be used in the URLs of requests sent
+// over the Unix Domain Socket at the given path. The host is not used for
+// dialing, but it helps identifying the socket in logs and errors.
+func udsHost(socketPath string) string {
+	return "UDS_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, socketPath)
+}
+
 type httpTransport struct {
-	traceURL string            // the delivery URL for traces
-	client   *http.Client      // the HTTP client used in the POST
-	headers  map[string]string // the Transport headers
+	traceURL       string            // the delivery URL for traces
+	legacyTraceURL string            // the delivery URL for traces when using older agents
+	statsURL       string            // the delivery URL for stats
+	client         *http.Client      // the HTTP client used in the POST
+	headers        map[string]string // the Transport headers
+
+	// compatibilityMode is set to true when the agent was found to not support
+	// the traceURL endpoint, in which case legacyTraceURL is used from then on.
+	// It is guarded by mu, as payloads can be sent concurrently, for example
+	// when replaying the ones spooled to disk.
+	mu                sync.RWMutex
+	compatibilityMode bool
 }
 
 // newHTTPTransport returns an httpTransport for the given endpoint
 func newHTTPTransport(addr string, roundTripper http.RoundTripper) *httpTransport {
+	return newHTTPTransportHost(resolveAddr(addr), roundTripper)
+}
+
+// newHTTPTransportHost returns an httpTransport which sends requests to the given
+// host as is, using roundTripper.
+func newHTTPTransportHost(host string, roundTripper http.RoundTripper) *httpTransport {
 	// initialize the default EncoderPool with Encoder headers
 	defaultHeaders := map[string]string{
 		"Datadog-Meta-Lang":             "go",
@@ -85,7 +154,9 @@ func newHTTPTransport(addr string, roundTripper http.RoundTripper) *httpTranspor
 		"Content-Type":                  "application/msgpack",
 	}
 	return &httpTransport{
-		traceURL: fmt.Sprintf("http://%s/v0.3/traces", resolveAddr(addr)),
+		traceURL:       fmt.Sprintf("http://%s/v0.4/traces", host),
+		legacyTraceURL: fmt.Sprintf("http://%s/v0.3/traces", host),
+		statsURL:       fmt.Sprintf("http://%s/v0.6/stats", host),
 		client: &http.Client{
 			Transport: roundTripper,
 			Timeout:   defaultHTTPTimeout,
@@ -94,36 +165,125 @@ func newHTTPTransport(addr string, roundTripper http.RoundTripper) *httpTranspor
 	}
 }
 
-func (t *httpTransport) send(p *payload) error {
-	// prepare the client and send the payload
-	req, err := http.NewRequest("POST", t.traceURL, p)
+func (t *httpTransport) send(ctx context.Context, p *payload) (body io.ReadCloser, err error) {
+	t.mu.RLock()
+	legacy := t.compatibilityMode
+	t.mu.RUnlock()
+	url := t.traceURL
+	if legacy {
+		url = t.legacyTraceURL
+	}
+	response, err := t.post(ctx, url, p)
+	if err != nil {
+		return nil, err
+	}
+	if code := response.StatusCode; !legacy && (code == 404 || code == 415) {
+		// the agent does not know about this version of the API; downgrade
+		// to the legacy endpoint and use it for all subsequent requests.
+		response.Body.Close()
+		t.mu.Lock()
+		t.compatibilityMode = true
+		t.mu.Unlock()
+		p.rewind()
+		response, err = t.post(ctx, t.legacyTraceURL, p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := checkResponse(response); err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+func (t *httpTransport) sendStats(ctx context.Context, p *statsPayload) error {
+	req, err := http.NewRequest("POST", t.statsURL, bytes.NewReader(p.encode()))
 	if err != nil {
 		return fmt.Errorf("cannot create http request: %v", err)
 	}
+	req = req.WithContext(ctx)
 	for header, value := range t.headers {
 		req.Header.Set(header, value)
 	}
-	req.Header.Set(traceCountHeader, strconv.Itoa(p.itemCount()))
-	req.Header.Set("Content-Length", strconv.Itoa(p.size()))
 	response, err := t.client.Do(req)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-	if code := response.StatusCode; code >= 400 {
-		// error, check the body for context information and
-		// return a nice error.
-		msg := make([]byte, 1000)
-		n, _ := response.Body.Read(msg)
-		txt := http.StatusText(code)
-		if n > 0 {
-			return fmt.Errorf("%s (Status: %s)", msg[:n], txt)
-		}
-		return fmt.Errorf("%s", txt)
+	if err := checkResponse(response); err != nil {
+		return err
 	}
+	response.Body.Close()
 	return nil
 }
 
+// checkResponse returns an *agentError if the agent responded with an error
+// status, in which case the response body is closed.
+func checkResponse(response *http.Response) error {
+	code := response.StatusCode
+	if code < 400 {
+		return nil
+	}
+	// error, check the body for context information and
+	// return a nice error.
+	msg := make([]byte, 1000)
+	n, _ := response.Body.Read(msg)
+	response.Body.Close()
+	return &agentError{
+		status:     code,
+		msg:        string(msg[:n]),
+		retryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// agentError is returned by send when the agent responds with an error status.
+type agentError struct {
+	status     int           // HTTP status code
+	msg        string        // beginning of the response body
+	retryAfter time.Duration // delay requested using the Retry-After header
+}
+
+func (e *agentError) Error() string {
+	txt := http.StatusText(e.status)
+	if e.msg != "" {
+		return fmt.Sprintf("%s (Status: %s)", e.msg, txt)
+	}
+	return txt
+}
+
+// parseRetryAfter returns the delay specified by the value of a Retry-After
+// header, which is either a number of seconds or an HTTP date, or zero if the
+// value is empty or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// post sends the payload p to the given URL and returns the agent's response.
+func (t *httpTransport) post(ctx context.Context, url string, p *payload) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, p)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create http request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	for header, value := range t.headers {
+		req.Header.Set(header, value)
+	}
+	req.Header.Set(traceCountHeader, strconv.Itoa(p.itemCount()))
+	req.Header.Set("Content-Length", strconv.Itoa(p.size()))
+	return t.client.Do(req)
+}
+
 // resolveAddr resolves the given agent address and fills in any missing host
 // and port using the defaults. Some environment variable settings will
 // take precedence over configuration.
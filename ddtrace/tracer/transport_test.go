@@ -1,18 +1,23 @@
 package tracer
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/tinylib/msgp/msgp"
 )
 
 // integration indicates if the test suite should run integration tests.
@@ -94,7 +99,7 @@ func TestTracesAgentIntegration(t *testing.T) {
 		transport := newHTTPTransport(defaultAddress, defaultRoundTripper)
 		p, err := encode(tc.payload)
 		assert.NoError(err)
-		err = transport.send(p)
+		_, err = transport.send(context.Background(), p)
 		assert.NoError(err)
 	}
 }
@@ -146,7 +151,7 @@ func TestTransportResponseError(t *testing.T) {
 	addr := ln.Addr().String()
 	log.Println(addr)
 	transport := newHTTPTransport(addr, defaultRoundTripper)
-	err = transport.send(newPayload())
+	_, err = transport.send(context.Background(), newPayload())
 	want := fmt.Sprintf("%s (Status: Bad Request)", strings.Repeat("X", 1000))
 	assert.Equal(want, err.Error())
 }
@@ -173,7 +178,7 @@ func TestTraceCountHeader(t *testing.T) {
 		transport := newHTTPTransport(host, defaultRoundTripper)
 		p, err := encode(tc.payload)
 		assert.NoError(err)
-		err = transport.send(p)
+		_, err = transport.send(context.Background(), p)
 		assert.NoError(err)
 	}
 
@@ -206,9 +211,141 @@ func TestCustomTransport(t *testing.T) {
 	transport := newHTTPTransport(host, customRoundTripper)
 	p, err := encode(getTestTrace(1, 1))
 	assert.NoError(err)
-	err = transport.send(p)
+	_, err = transport.send(context.Background(), p)
 	assert.NoError(err)
 
 	// make sure our custom round tripper was used
 	assert.Len(customRoundTripper.reqs, 1)
 }
+
+// newVersionedAgent returns a fake agent which only knows how to handle traces
+// on the given API version (e.g. "v0.3"), responding with the given status code
+// on any other version. It records the paths of all the requests it receives.
+func newVersionedAgent(version string, code int) (*httptest.Server, *[]string) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path != "/"+version+"/traces" {
+			w.WriteHeader(code)
+			return
+		}
+		var traces spanLists
+		if err := msgp.Decode(r.Body, &traces); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"rate_by_service":{}}`))
+	}))
+	return srv, &paths
+}
+
+func TestTransportVersions(t *testing.T) {
+	t.Run("v0.4", func(t *testing.T) {
+		assert := assert.New(t)
+		srv, paths := newVersionedAgent("v0.4", http.StatusNotFound)
+		defer srv.Close()
+
+		transport := newHTTPTransport(srv.Listener.Addr().String(), defaultRoundTripper)
+		for i := 0; i < 2; i++ {
+			p, err := encode(getTestTrace(2, 2))
+			assert.NoError(err)
+			body, err := transport.send(context.Background(), p)
+			assert.NoError(err)
+			body.Close()
+		}
+		assert.False(transport.compatibilityMode)
+		assert.Equal([]string{"/v0.4/traces", "/v0.4/traces"}, *paths)
+	})
+
+	for _, code := range []int{http.StatusNotFound, http.StatusUnsupportedMediaType} {
+		t.Run("v0.3/"+strconv.Itoa(code), func(t *testing.T) {
+			assert := assert.New(t)
+			srv, paths := newVersionedAgent("v0.3", code)
+			defer srv.Close()
+
+			transport := newHTTPTransport(srv.Listener.Addr().String(), defaultRoundTripper)
+			for i := 0; i < 2; i++ {
+				p, err := encode(getTestTrace(2, 2))
+				assert.NoError(err)
+				body, err := transport.send(context.Background(), p)
+				assert.NoError(err)
+				body.Close()
+			}
+			assert.True(transport.compatibilityMode)
+			// the downgrade is remembered after the first attempt
+			assert.Equal([]string{"/v0.4/traces", "/v0.3/traces", "/v0.3/traces"}, *paths)
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		assert := assert.New(t)
+		srv, paths := newVersionedAgent("v0.4", http.StatusNotFound)
+		defer srv.Close()
+
+		transport := newHTTPTransport(srv.Listener.Addr().String(), defaultRoundTripper)
+		transport.traceURL = srv.URL + "/v0.5/traces"
+		p, err := encode(getTestTrace(1, 1))
+		assert.NoError(err)
+		_, err = transport.send(context.Background(), p)
+		assert.Error(err, "legacy endpoint is not supported either")
+		assert.True(transport.compatibilityMode)
+		assert.Equal([]string{"/v0.5/traces", "/v0.3/traces"}, *paths)
+	})
+}
+
+// newUDSAgent starts a fake agent listening on a Unix Domain Socket in a temporary
+// directory and returns the path of the socket. Calling the returned function
+// shuts down the agent.
+func newUDSAgent(t *testing.T, h http.Handler) (string, func()) {
+	dir, err := ioutil.TempDir("", "uds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socketPath := filepath.Join(dir, "apm.socket")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(h)
+	srv.Listener = ln
+	srv.Start()
+	return socketPath, func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestTransportUDS(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		hits int
+		host string
+	)
+	socketPath, cleanup := newUDSAgent(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		host = r.Host
+		assert.Equal("/v0.4/traces", r.URL.Path)
+		mockDatadogAPIHandler{t: t}.ServeHTTP(w, r)
+	}))
+	defer cleanup()
+
+	transport := newUDSTransport(socketPath)
+	p, err := encode(getTestTrace(1, 1))
+	assert.NoError(err)
+	body, err := transport.send(context.Background(), p)
+	assert.NoError(err)
+	body.Close()
+	assert.Equal(1, hits)
+	assert.Equal(udsHost(socketPath), host)
+}
+
+func TestUDSHost(t *testing.T) {
+	assert.Equal(t, "UDS__var_run_datadog_apm_socket", udsHost("/var/run/datadog/apm.socket"))
+	assert.Equal(t, "UDS__tmp_my-agent_sock", udsHost("/tmp/my-agent.sock"))
+}
@@ -0,0 +1,31 @@
+// Package globalconfig holds the configuration of the tracer which is shared
+// with the integrations, such as the trace analytics sample rate.
+package globalconfig
+
+import (
+	"math"
+	"sync"
+)
+
+var cfg = &config{analyticsRate: math.NaN()}
+
+type config struct {
+	mu            sync.RWMutex
+	analyticsRate float64
+}
+
+// AnalyticsRate returns the sample rate at which the spans of integrations are
+// marked as APM events by default, or NaN if analytics are not enabled.
+func AnalyticsRate() float64 {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.analyticsRate
+}
+
+// SetAnalyticsRate sets the sample rate at which the spans of integrations are
+// marked as APM events by default. NaN disables analytics.
+func SetAnalyticsRate(rate float64) {
+	cfg.mu.Lock()
+	cfg.analyticsRate = rate
+	cfg.mu.Unlock()
+}
@@ -0,0 +1,15 @@
+package globalconfig
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyticsRate(t *testing.T) {
+	assert.True(t, math.IsNaN(AnalyticsRate()))
+	SetAnalyticsRate(0.4)
+	defer SetAnalyticsRate(math.NaN())
+	assert.Equal(t, 0.4, AnalyticsRate())
+}
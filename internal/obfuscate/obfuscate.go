@@ -0,0 +1,162 @@
+package obfuscate
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// redisKeepArgs holds the Redis commands whose arguments are keys, fields,
+// indexes or options, and are kept as is.
+var redisKeepArgs = map[string]bool{
+	"DBSIZE": true, "DECR": true, "DECRBY": true, "DEL": true, "DISCARD": true,
+	"EXEC": true, "EXISTS": true, "EXPIRE": true, "FLUSHALL": true, "FLUSHDB": true,
+	"GET": true, "HDEL": true, "HEXISTS": true, "HGET": true, "HGETALL": true,
+	"HKEYS": true, "HLEN": true, "HMGET": true, "HSCAN": true, "HVALS": true,
+	"INCR": true, "INCRBY": true, "INFO": true, "KEYS": true, "LINDEX": true,
+	"LLEN": true, "LPOP": true, "LRANGE": true, "MGET": true, "MULTI": true,
+	"PERSIST": true, "PEXPIRE": true, "PING": true, "PTTL": true, "QUIT": true,
+	"RENAME": true, "RPOP": true, "SCAN": true, "SCARD": true, "SELECT": true,
+	"SMEMBERS": true, "SPOP": true, "SSCAN": true, "TIME": true, "TTL": true,
+	"TYPE": true, "UNLINK": true, "UNWATCH": true, "WATCH": true, "ZCARD": true,
+	"ZRANGE": true, "ZRANGEBYSCORE": true, "ZREVRANGE": true, "ZSCAN": true,
+}
+
+// Redis returns the given Redis commands, one per line, with the values of
+// their arguments replaced by "?". Command names and keys are kept, as well
+// as all the arguments of commands which do not take values, such as GET.
+// The arguments of AUTH are always replaced.
+func Redis(cmd string) string {
+	lines := strings.Split(cmd, "\n")
+	for i, line := range lines {
+		lines[i] = redisCommand(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redisCommand obfuscates the single Redis command cmd.
+func redisCommand(cmd string) string {
+	args := strings.Fields(cmd)
+	if len(args) < 2 {
+		return cmd
+	}
+	name := strings.ToUpper(args[0])
+	switch {
+	case name == "AUTH":
+		return args[0] + " ?"
+	case redisKeepArgs[name]:
+		return cmd
+	case name == "MSET" || name == "MSETNX":
+		// key value [key value ...]
+		for i := 2; i < len(args); i += 2 {
+			args[i] = "?"
+		}
+	case name == "HSET" || name == "HSETNX" || name == "HMSET":
+		// key field value [field value ...]
+		for i := 3; i < len(args); i += 2 {
+			args[i] = "?"
+		}
+	default:
+		// keep the key only
+		if len(args) > 2 {
+			args = append(args[:2], "?")
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// JSON returns the given JSON body with all of its values replaced by "?",
+// keeping its keys and structure. Bodies made of several JSON values, such
+// as newline delimited JSON, are supported. When the body is invalid, for
+// example because it was truncated, the invalid part is replaced by "...".
+func JSON(body string) string {
+	var b bytes.Buffer
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	for i := 0; ; i++ {
+		if i > 0 && dec.More() {
+			b.WriteByte('\n')
+		}
+		err := writeJSONValue(&b, dec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			b.WriteString("...")
+			break
+		}
+	}
+	return b.String()
+}
+
+// writeJSONValue writes the next value read from dec to b, replacing all
+// scalar values by "?".
+func writeJSONValue(b *bytes.Buffer, dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok {
+		b.WriteString(`"?"`)
+		return nil
+	}
+	switch d {
+	case '{':
+		b.WriteByte('{')
+		for i := 0; dec.More(); i++ {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			s, _ := key.(string)
+			b.WriteString(strconv.Quote(s))
+			b.WriteByte(':')
+			if err := writeJSONValue(b, dec); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case '[':
+		b.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeJSONValue(b, dec); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	}
+	// consume the closing delimiter
+	_, err = dec.Token()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// QueryString returns the given URL with the values of its query string
+// parameters replaced by "?". The fragment, if any, is removed.
+func QueryString(url string) string {
+	if i := strings.IndexByte(url, '#'); i >= 0 {
+		url = url[:i]
+	}
+	i := strings.IndexByte(url, '?')
+	if i < 0 || i == len(url)-1 {
+		return url
+	}
+	params := strings.Split(url[i+1:], "&")
+	for j, p := range params {
+		if k := strings.IndexByte(p, '='); k >= 0 {
+			params[j] = p[:k] + "=?"
+		}
+	}
+	return url[:i+1] + strings.Join(params, "&")
+}
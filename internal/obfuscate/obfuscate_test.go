@@ -0,0 +1,54 @@
+package obfuscate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedis(t *testing.T) {
+	for in, out := range map[string]string{
+		"GET key":                     "GET key",
+		"SET key value":               "SET key ?",
+		"SETEX key 10 secret value":   "SETEX key ?",
+		"set key value":               "set key ?",
+		"AUTH password":               "AUTH ?",
+		"AUTH user password":          "AUTH ?",
+		"MSET k1 v1 k2 v2":            "MSET k1 ? k2 ?",
+		"HSET h f1 v1 f2 v2":          "HSET h f1 ? f2 ?",
+		"HMGET h f1 f2":               "HMGET h f1 f2",
+		"LRANGE list 0 -1":            "LRANGE list 0 -1",
+		"PING":                        "PING",
+		"SET k v\nGET k\nAUTH secret": "SET k ?\nGET k\nAUTH ?",
+		"":                            "",
+	} {
+		assert.Equal(t, out, Redis(in), in)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	for in, out := range map[string]string{
+		`{"query":{"match":{"title":"secret","n":1}}}`: `{"query":{"match":{"title":"?","n":"?"}}}`,
+		`{"a":[1,"b",{"c":null}],"d":true,"e":{}}`:     `{"a":["?","?",{"c":"?"}],"d":"?","e":{}}`,
+		"{\"index\":{}}\n{\"user\":\"x\"}\n":           "{\"index\":{}}\n{\"user\":\"?\"}",
+		`{"query":{"match":{"title":"trunc`:            `{"query":{"match":{"title":...`,
+		`{"query":{"match":{"title":"x"}`:              `{"query":{"match":{"title":"?"}...`,
+		`"scalar"`:                                     `"?"`,
+		`not json`:                                     `...`,
+		``:                                             ``,
+	} {
+		assert.Equal(t, out, JSON(in), in)
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	for in, out := range map[string]string{
+		"/users":                                 "/users",
+		"/users?":                                "/users?",
+		"/users?id=42&token=abc":                 "/users?id=?&token=?",
+		"https://example.com/a?q=x&flag&b=#frag": "https://example.com/a?q=?&flag&b=?",
+		"https://example.com/a#access_token=secret": "https://example.com/a",
+	} {
+		assert.Equal(t, out, QueryString(in), in)
+	}
+}
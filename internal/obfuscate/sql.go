@@ -0,0 +1,342 @@
+// Package obfuscate implements obfuscators which remove sensitive values from
+// the raw data that integrations record on spans, such as SQL queries, Redis
+// commands, JSON bodies and URL query strings.
+package obfuscate
+
+import (
+	"strings"
+)
+
+// Dialect specifies the SQL dialect of the queries given to NormalizeSQL, which
+// determines how quotes, comments and some literals are tokenized.
+type Dialect int
+
+const (
+	// DialectDefault tokenizes queries in a way which suits most databases:
+	// double quotes and backticks delimit identifiers, and backslashes escape
+	// quotes in string literals.
+	DialectDefault Dialect = iota
+
+	// DialectMySQL tokenizes MySQL queries: double quotes delimit string
+	// literals and "#" starts a comment.
+	DialectMySQL
+
+	// DialectPostgres tokenizes PostgreSQL queries: dollar-quoted strings are
+	// supported and backslashes only escape quotes in E'...' string literals.
+	DialectPostgres
+
+	// DialectCQL tokenizes Cassandra queries: dollar-quoted strings, UUID
+	// literals and "//" comments are supported.
+	DialectCQL
+)
+
+// SQL returns the given SQL query with its string and numeric literals replaced
+// by "?". Identifiers, keywords, operators and comments are kept as is.
+func SQL(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	t := tokenizer{s: query}
+	for {
+		tok, ok := t.next()
+		if !ok {
+			break
+		}
+		switch tok.kind {
+		case tokenString, tokenNumber:
+			b.WriteByte('?')
+		default:
+			b.WriteString(tok.text)
+		}
+	}
+	return b.String()
+}
+
+// NormalizeSQL returns the given query normalized so that it can be used as a
+// resource name: string, numeric and boolean literals are replaced by "?", the
+// lists of values of IN clauses are collapsed into a single "?", comments are
+// removed and consecutive whitespace is replaced by a single space.
+func NormalizeSQL(query string, d Dialect) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	t := tokenizer{s: query, dialect: d}
+	space := false // whether a space should be written before the next token
+	var prev token // the previous token which is not a space or comment
+	for {
+		tok, ok := t.next()
+		if !ok {
+			break
+		}
+		switch tok.kind {
+		case tokenSpace, tokenComment:
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		switch {
+		case tok.kind == tokenString || tok.kind == tokenNumber || isBoolean(tok):
+			b.WriteByte('?')
+		case tok.text == "(" && prev.kind == tokenIdent && strings.EqualFold(prev.text, "IN") && t.skipValueList():
+			b.WriteString("(?)")
+		default:
+			b.WriteString(tok.text)
+		}
+		prev = tok
+	}
+	return b.String()
+}
+
+// isBoolean reports whether tok is a boolean literal.
+func isBoolean(tok token) bool {
+	return tok.kind == tokenIdent && (strings.EqualFold(tok.text, "TRUE") || strings.EqualFold(tok.text, "FALSE"))
+}
+
+// tokenKind specifies the kind of a token.
+type tokenKind int
+
+const (
+	tokenOther       tokenKind = iota // operators and punctuation
+	tokenSpace                        // whitespace
+	tokenComment                      // comments
+	tokenIdent                        // keywords and identifiers, quoted or not
+	tokenString                       // string literals
+	tokenNumber                       // numeric literals
+	tokenPlaceholder                  // bind parameters, such as ? or $1
+)
+
+// token is a token of a SQL query.
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenizer splits SQL queries into tokens.
+type tokenizer struct {
+	s       string
+	i       int
+	dialect Dialect
+}
+
+// next returns the next token of the query, or false when there are none left.
+func (t *tokenizer) next() (token, bool) {
+	s, i := t.s, t.i
+	if i >= len(s) {
+		return token{}, false
+	}
+	kind, j := t.scan(i)
+	t.i = j
+	return token{kind: kind, text: s[i:j]}, true
+}
+
+// scan returns the kind and end of the token starting at t.s[i].
+func (t *tokenizer) scan(i int) (tokenKind, int) {
+	s := t.s
+	c := s[i]
+	switch {
+	case isSpace(c):
+		j := i + 1
+		for j < len(s) && isSpace(s[j]) {
+			j++
+		}
+		return tokenSpace, j
+	case c == '\'':
+		// string literal, possibly with escaped quotes
+		return tokenString, skipQuoted(s, i, '\'', t.dialect != DialectPostgres && t.dialect != DialectCQL)
+	case c == '"' && t.dialect == DialectMySQL:
+		return tokenString, skipQuoted(s, i, '"', true)
+	case c == '"' || c == '`':
+		// quoted identifier
+		return tokenIdent, skipQuoted(s, i, c, false)
+	case strings.HasPrefix(s[i:], "--"),
+		c == '#' && t.dialect == DialectMySQL,
+		strings.HasPrefix(s[i:], "//") && t.dialect == DialectCQL:
+		j := strings.IndexByte(s[i:], '\n')
+		if j < 0 {
+			return tokenComment, len(s)
+		}
+		return tokenComment, i + j
+	case strings.HasPrefix(s[i:], "/*"):
+		j := strings.Index(s[i+2:], "*/")
+		if j < 0 {
+			return tokenComment, len(s)
+		}
+		return tokenComment, i + j + 4
+	case c == '?':
+		return tokenPlaceholder, i + 1
+	case c == '$' && (t.dialect == DialectPostgres || t.dialect == DialectCQL):
+		if j, ok := skipDollarQuoted(s, i); ok {
+			return tokenString, j
+		}
+		if i+1 < len(s) && isDigit(s[i+1]) {
+			j := i + 1
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			return tokenPlaceholder, j
+		}
+	}
+	if t.dialect == DialectCQL && isUUID(s[i:]) {
+		return tokenString, i + uuidLen
+	}
+	switch {
+	case isDigit(c) && (i == 0 || !isIdentChar(s[i-1])):
+		return tokenNumber, skipNumber(s, i)
+	case isIdentChar(c):
+		// consume the whole identifier, so that digits inside it are kept
+		j := i
+		for j < len(s) && isIdentChar(s[j]) {
+			j++
+		}
+		if j == i+1 && j < len(s) && s[j] == '\'' && strings.IndexByte("bBeEnNxX", c) >= 0 {
+			// prefixed string literal, such as X'CAFE' or E'it\'s'
+			return tokenString, skipQuoted(s, j, '\'', t.dialect != DialectPostgres || c == 'e' || c == 'E')
+		}
+		return tokenIdent, j
+	}
+	return tokenOther, i + 1
+}
+
+// skipValueList is called after the opening parenthesis of an IN clause. If
+// the parenthesis starts a list made only of literals and placeholders, it
+// moves the tokenizer past the list's closing parenthesis and returns true.
+func (t *tokenizer) skipValueList() bool {
+	start := t.i
+	for {
+		tok, ok := t.next()
+		if !ok {
+			break
+		}
+		switch tok.kind {
+		case tokenString, tokenNumber, tokenPlaceholder, tokenSpace, tokenComment:
+			continue
+		case tokenIdent:
+			if isBoolean(tok) || strings.EqualFold(tok.text, "NULL") {
+				continue
+			}
+		case tokenOther:
+			switch tok.text {
+			case ",", "-", "+":
+				continue
+			case ")":
+				return true
+			}
+		}
+		break
+	}
+	t.i = start
+	return false
+}
+
+// skipQuoted returns the index following the quoted token starting at s[i],
+// which is delimited by the quote q. Quotes are escaped by doubling them or,
+// when backslash is true, using a backslash.
+func skipQuoted(s string, i int, q byte, backslash bool) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			if backslash {
+				j++
+			}
+		case q:
+			if j+1 < len(s) && s[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// skipDollarQuoted returns the index following the dollar-quoted string
+// starting at s[i], such as $$it's$$ or $tag$it's$tag$, and true, or false
+// if s[i] does not start a dollar-quoted string.
+func skipDollarQuoted(s string, i int) (int, bool) {
+	j := i + 1
+	for j < len(s) && s[j] != '$' {
+		if c := s[j]; !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (j > i+1 && isDigit(c))) {
+			return 0, false
+		}
+		j++
+	}
+	if j >= len(s) {
+		return 0, false
+	}
+	delim := s[i : j+1]
+	k := strings.Index(s[j+1:], delim)
+	if k < 0 {
+		return len(s), true
+	}
+	return j + 1 + k + len(delim), true
+}
+
+// skipNumber returns the index following the numeric literal starting at s[i],
+// which can be an integer, a decimal, or a number using the hex or exponent
+// notations.
+func skipNumber(s string, i int) int {
+	if strings.HasPrefix(s[i:], "0x") || strings.HasPrefix(s[i:], "0X") {
+		i += 2
+		for i < len(s) && isHexDigit(s[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(s) && (isDigit(s[i]) || s[i] == '.') {
+		i++
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for i = j; i < len(s) && isDigit(s[i]); i++ {
+			}
+		}
+	}
+	return i
+}
+
+// uuidLen is the length of a UUID in its canonical form.
+const uuidLen = 36
+
+// isUUID reports whether s starts with a UUID, such as
+// 123e4567-e89b-12d3-a456-426655440000, which is not followed by an
+// identifier character.
+func isUUID(s string) bool {
+	if len(s) < uuidLen || (len(s) > uuidLen && isIdentChar(s[uuidLen])) {
+		return false
+	}
+	for i := 0; i < uuidLen; i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// isIdentChar reports whether c can be part of an unquoted identifier or
+// keyword. Non-ASCII characters are considered identifier characters.
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || isDigit(c) ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
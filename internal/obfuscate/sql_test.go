@@ -0,0 +1,58 @@
+package obfuscate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQL(t *testing.T) {
+	for in, out := range map[string]string{
+		"SELECT * FROM users WHERE id = 42":                          "SELECT * FROM users WHERE id = ?",
+		"SELECT * FROM users WHERE name = 'O''Brien' AND age > 18.5": "SELECT * FROM users WHERE name = ? AND age > ?",
+		`SELECT * FROM users WHERE name = 'it\'s'`:                   "SELECT * FROM users WHERE name = ?",
+		`SELECT "table1"."col2" FROM "table1" WHERE x = -1e10`:       `SELECT "table1"."col2" FROM "table1" WHERE x = -?`,
+		"SELECT `t1`.`c2` FROM t1 WHERE v2 = 0xFF LIMIT 10":          "SELECT `t1`.`c2` FROM t1 WHERE v2 = ? LIMIT ?",
+		"INSERT INTO t (a, b) VALUES ($1, $2)":                       "INSERT INTO t (a, b) VALUES ($1, $2)",
+		"UPDATE t SET a = 'x' -- it's a comment\nWHERE b = 2":        "UPDATE t SET a = ? -- it's a comment\nWHERE b = ?",
+		"SELECT /* it's 1 */ 1":                                      "SELECT /* it's 1 */ ?",
+		"SELECT * FROM t WHERE a = 'unterminated":                    "SELECT * FROM t WHERE a = ?",
+		"SELECT * FROM t WHERE ts > 2019-01-01 AND a IN (1, 2, 3)":   "SELECT * FROM t WHERE ts > ?-?-? AND a IN (?, ?, ?)",
+		"SELECT col1, ❤2 FROM données WHERE name = 'é'":              "SELECT col1, ❤2 FROM données WHERE name = ?",
+		"": "",
+	} {
+		assert.Equal(t, out, SQL(in), in)
+	}
+}
+
+func TestNormalizeSQL(t *testing.T) {
+	for _, tt := range []struct {
+		dialect Dialect
+		in, out string
+	}{
+		{DialectDefault, "SELECT * FROM users WHERE id = 42", "SELECT * FROM users WHERE id = ?"},
+		{DialectDefault, "SELECT *\n  FROM users\tWHERE  active = TRUE ", "SELECT * FROM users WHERE active = ?"},
+		{DialectDefault, "SELECT * FROM t WHERE a IN (1, 2, 3) AND b NOT IN ('x','y')", "SELECT * FROM t WHERE a IN (?) AND b NOT IN (?)"},
+		{DialectDefault, "SELECT * FROM t WHERE a in(?, ?, -1, NULL)", "SELECT * FROM t WHERE a in(?)"},
+		{DialectDefault, "SELECT * FROM t WHERE a IN (SELECT b FROM u WHERE c = 1)", "SELECT * FROM t WHERE a IN (SELECT b FROM u WHERE c = ?)"},
+		{DialectDefault, "SELECT * FROM t WHERE a IN (1, b)", "SELECT * FROM t WHERE a IN (?, b)"},
+		{DialectDefault, "SELECT /* it's 1 */ 1 -- one\nFROM dual", "SELECT ? FROM dual"},
+		{DialectDefault, "/* leading */ SELECT a/*x*/FROM t", "SELECT a FROM t"},
+		{DialectDefault, "SELECT x'CAFE', N'name'", "SELECT ?, ?"},
+		{DialectMySQL, `SELECT * FROM users WHERE name = "O\"Brien" # comment`, "SELECT * FROM users WHERE name = ?"},
+		{DialectMySQL, "SELECT `t1`.`c2` FROM t1 WHERE v2 = 0xFF LIMIT 10, 20", "SELECT `t1`.`c2` FROM t1 WHERE v2 = ? LIMIT ?, ?"},
+		{DialectMySQL, `SELECT 'it\'s', 'a"b'`, "SELECT ?, ?"},
+		{DialectPostgres, `SELECT "table1"."col2" FROM "table1" WHERE x = $1 AND y IN ($2, $3)`, `SELECT "table1"."col2" FROM "table1" WHERE x = $1 AND y IN (?)`},
+		{DialectPostgres, `SELECT 'C:\' AS path, E'it\'s' AS s`, "SELECT ? AS path, ? AS s"},
+		{DialectPostgres, "SELECT $$it's$$, $tag$a $$ b$tag$, a#b FROM t", "SELECT ?, ?, a#b FROM t"},
+		{DialectPostgres, "SELECT '2019-01-01'::date, 1.5e-3::float", "SELECT ?::date, ?::float"},
+		{DialectCQL, "SELECT * FROM ks.users WHERE id = 123e4567-e89b-12d3-a456-426655440000", "SELECT * FROM ks.users WHERE id = ?"},
+		{DialectCQL, "SELECT * FROM users WHERE id = f81d4fae-7dec-11d0-a765-00a0c91e6bf6 // by id", "SELECT * FROM users WHERE id = ?"},
+		{DialectCQL, "INSERT INTO t (a, b, c) VALUES ('O''Brien', 0xcafe, $$x$$) USING TTL 86400", "INSERT INTO t (a, b, c) VALUES (?, ?, ?) USING TTL ?"},
+		{DialectCQL, "SELECT * FROM t WHERE k IN ? AND c IN (:a, :b)", "SELECT * FROM t WHERE k IN ? AND c IN (:a, :b)"},
+		{DialectDefault, "", ""},
+		{DialectDefault, "  -- only a comment", ""},
+	} {
+		assert.Equal(t, tt.out, NormalizeSQL(tt.in, tt.dialect), tt.in)
+	}
+}